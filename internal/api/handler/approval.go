@@ -0,0 +1,285 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/your-org/app-market/internal/service"
+)
+
+type ApprovalHandler struct {
+	service     *service.ApprovalService
+	taskService *service.TaskService
+	audit       *service.AuditService
+}
+
+func NewApprovalHandler(s *service.ApprovalService, ts *service.TaskService, audit *service.AuditService) *ApprovalHandler {
+	return &ApprovalHandler{service: s, taskService: ts, audit: audit}
+}
+
+type ApprovalPolicyRequest struct {
+	ClusterID      *uint    `json:"cluster_id" example:"1"` // Omit to apply the policy on every cluster
+	TargetType     string   `json:"target_type" binding:"required,oneof=chart namespace" example:"namespace"`
+	Target         string   `json:"target" binding:"required" example:"prod"` // Chart ID or namespace, "prod-*" matches a prefix
+	Description    string   `json:"description" example:"Production deployments need a reviewer"`
+	Approvers      []string `json:"approvers" example:"alice,bob"` // Usernames
+	ApproverGroups []string `json:"approver_groups" example:"1"`   // Group IDs
+}
+
+type ApproveRequest struct {
+	UserValues map[string]interface{} `json:"user_values"` // Replaces the requested values if set
+	Comment    string                 `json:"comment" example:"Looks good"`
+}
+
+type RejectRequest struct {
+	Reason string `json:"reason" binding:"required" example:"Use the shared database instead"`
+}
+
+// ListPolicies godoc
+// @Summary      List Approval Policies
+// @Description  List the charts and namespaces whose deployments and instance changes need approval
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {array}   model.ApprovalPolicy
+// @Failure      500  {object}  map[string]string
+// @Router       /admin/approval-policies [get]
+func (h *ApprovalHandler) ListPolicies(c *gin.Context) {
+	policies, err := h.service.ListPolicies()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list approval policies"})
+		return
+	}
+	c.JSON(http.StatusOK, policies)
+}
+
+// CreatePolicy godoc
+// @Summary      Create Approval Policy
+// @Description  Require approval for deployments, upgrades, rollbacks, re-syncs and uninstalls of a chart or in a namespace, on one cluster or on all of them
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request  body  ApprovalPolicyRequest  true  "Policy"
+// @Success      201  {object}  model.ApprovalPolicy
+// @Failure      400  {object}  map[string]string
+// @Router       /admin/approval-policies [post]
+func (h *ApprovalHandler) CreatePolicy(c *gin.Context) {
+	var req ApprovalPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	policy, err := h.service.CreatePolicy(policyInput(req))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	recordAudit(h.audit, c, "approval_policy.create", "approval_policy", fmt.Sprintf("%d", policy.ID), nil, policy)
+
+	c.JSON(http.StatusCreated, policy)
+}
+
+// UpdatePolicy godoc
+// @Summary      Update Approval Policy
+// @Description  Replace the target and approvers of an approval policy
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id       path  int                    true  "Policy ID"
+// @Param        request  body  ApprovalPolicyRequest  true  "Policy"
+// @Success      200  {object}  model.ApprovalPolicy
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /admin/approval-policies/{id} [put]
+func (h *ApprovalHandler) UpdatePolicy(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	var req ApprovalPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	before, err := h.service.GetPolicy(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	policy, err := h.service.UpdatePolicy(uint(id), policyInput(req))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	recordAudit(h.audit, c, "approval_policy.update", "approval_policy", c.Param("id"), before, policy)
+
+	c.JSON(http.StatusOK, policy)
+}
+
+func policyInput(req ApprovalPolicyRequest) service.PolicyInput {
+	return service.PolicyInput{
+		ClusterID:      req.ClusterID,
+		TargetType:     req.TargetType,
+		Target:         req.Target,
+		Description:    req.Description,
+		Approvers:      req.Approvers,
+		ApproverGroups: req.ApproverGroups,
+	}
+}
+
+// DeletePolicy godoc
+// @Summary      Delete Approval Policy
+// @Description  Remove an approval policy. Tasks already waiting still need a decision.
+// @Tags         admin
+// @Security     BearerAuth
+// @Param        id   path  int  true  "Policy ID"
+// @Success      204
+// @Failure      404  {object}  map[string]string
+// @Router       /admin/approval-policies/{id} [delete]
+func (h *ApprovalHandler) DeletePolicy(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	before, err := h.service.GetPolicy(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.service.DeletePolicy(uint(id)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	recordAudit(h.audit, c, "approval_policy.delete", "approval_policy", c.Param("id"), before, nil)
+
+	c.Status(http.StatusNoContent)
+}
+
+// ListApprovals godoc
+// @Summary      List Approvals
+// @Description  List the tasks the current user may approve or reject, with the requested task
+// @Tags         approvals
+// @Produce      json
+// @Security     BearerAuth
+// @Param        status  query  string  false  "pending (default), approved, rejected or cancelled"
+// @Success      200  {array}   model.Approval
+// @Failure      500  {object}  map[string]string
+// @Router       /api/approvals [get]
+func (h *ApprovalHandler) ListApprovals(c *gin.Context) {
+	userID := c.MustGet("userID").(string)
+
+	approvals, err := h.service.ListApprovals(userID, c.GetString("role"), c.Query("status"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, approvals)
+}
+
+// Approve godoc
+// @Summary      Approve Task
+// @Description  Release a task to the task workers, optionally replacing the values of a deployment or upgrade. The task is validated again before it is queued.
+// @Tags         approvals
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id       path  int             true   "Approval ID"
+// @Param        request  body  ApproveRequest  false  "Edited values and comment"
+// @Success      200  {object}  model.Approval
+// @Failure      400  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /api/approvals/{id}/approve [post]
+func (h *ApprovalHandler) Approve(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	var req ApproveRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	userID := c.MustGet("userID").(string)
+	approval, err := h.taskService.ApproveTask(uint(id), userID, c.GetString("role"), req.UserValues, req.Comment)
+	if err != nil {
+		respondApprovalError(c, err)
+		return
+	}
+	recordAudit(h.audit, c, "approval.approve", "approval", c.Param("id"), nil, approval)
+
+	c.JSON(http.StatusOK, approval)
+}
+
+// Reject godoc
+// @Summary      Reject Task
+// @Description  Reject a task waiting for approval. The task ends as rejected with the reason as its result.
+// @Tags         approvals
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id       path  int            true  "Approval ID"
+// @Param        request  body  RejectRequest  true  "Reason"
+// @Success      200  {object}  model.Approval
+// @Failure      400  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Router       /api/approvals/{id}/reject [post]
+func (h *ApprovalHandler) Reject(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	var req RejectRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := c.MustGet("userID").(string)
+	approval, err := h.taskService.RejectTask(uint(id), userID, c.GetString("role"), req.Reason)
+	if err != nil {
+		respondApprovalError(c, err)
+		return
+	}
+	recordAudit(h.audit, c, "approval.reject", "approval", c.Param("id"), nil, approval)
+
+	c.JSON(http.StatusOK, approval)
+}
+
+func respondApprovalError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrNotApprover):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrApprovalClosed), errors.Is(err, service.ErrUninstallQueued):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrValuesNotEditable):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrApprovalNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		respondDeployError(c, err)
+	}
+}
@@ -0,0 +1,155 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/your-org/app-market/internal/helm"
+	"github.com/your-org/app-market/internal/model"
+	"github.com/your-org/app-market/internal/service"
+)
+
+type AuditHandler struct {
+	service *service.AuditService
+}
+
+func NewAuditHandler(s *service.AuditService) *AuditHandler {
+	return &AuditHandler{service: s}
+}
+
+// recordAudit appends an entry for an action the caller just performed. The
+// action already happened, so a failure to record it is logged rather than
+// returned to the client.
+func recordAudit(audit *service.AuditService, c *gin.Context, action, targetType, targetID string, before, after interface{}) {
+	_, err := audit.Record(service.AuditRecord{
+		ActorID:    c.GetString("userID"),
+		ActorRole:  c.GetString("role"),
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		Before:     before,
+		After:      after,
+		IP:         c.ClientIP(),
+		UserAgent:  c.Request.UserAgent(),
+		Method:     c.Request.Method,
+		Path:       c.Request.URL.Path,
+	})
+	if err != nil {
+		log.Printf("Failed to record audit entry %s %s/%s: %v", action, targetType, targetID, err)
+	}
+}
+
+// auditValues stands in for Helm values in audit snapshots, which may hold
+// credentials: only the value paths and a keyed digest of the values are
+// recorded
+func auditValues(audit *service.AuditService, values map[string]interface{}) gin.H {
+	if values == nil {
+		return nil
+	}
+	keys := make([]string, 0, len(values))
+	for key := range helm.FlattenValues(values) {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return gin.H{"keys": keys, "hmac": audit.ValuesDigest(values)}
+}
+
+// auditInstance is an instance snapshot with its values redacted
+type auditInstance struct {
+	*model.AppInstance
+	AppliedValues gin.H `json:"applied_values"`
+	UserValues    gin.H `json:"user_values"`
+}
+
+func instanceSnapshot(audit *service.AuditService, instance *model.AppInstance) *auditInstance {
+	if instance == nil {
+		return nil
+	}
+	return &auditInstance{
+		AppInstance:   instance,
+		AppliedValues: auditValues(audit, instance.AppliedValues),
+		UserValues:    auditValues(audit, instance.UserValues),
+	}
+}
+
+// auditDeployRequest and auditUpgradeRequest are requests with their user
+// values redacted
+type auditDeployRequest struct {
+	service.DeployRequest
+	UserValues gin.H `json:"user_values"`
+}
+
+type auditUpgradeRequest struct {
+	service.UpgradeRequest
+	UserValues gin.H `json:"user_values"`
+}
+
+// ListAudit godoc
+// @Summary      List Audit Log
+// @Description  Query the audit log of administrative and deploy actions, newest first
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Param        actor_id     query  string  false  "Username of the actor"
+// @Param        action       query  string  false  "Action, e.g. chart.publish; chart.* matches a prefix"
+// @Param        target_type  query  string  false  "Target type (user, chart, chart_version, repo, release, instance, cluster, group, namespace_grant, quota, approval_policy, approval, task, webhook, drift)"
+// @Param        target_id    query  string  false  "Target ID"
+// @Param        from         query  string  false  "Created at or after (RFC3339 or YYYY-MM-DD)"
+// @Param        to           query  string  false  "Created before (RFC3339 or YYYY-MM-DD)"
+// @Param        page         query  int     false  "Page number"
+// @Param        limit        query  int     false  "Page size"
+// @Success      200  {object}  service.AuditListOutput
+// @Failure      400  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /admin/audit [get]
+func (h *AuditHandler) ListAudit(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+
+	filter := service.AuditFilter{
+		ActorID:    c.Query("actor_id"),
+		Action:     c.Query("action"),
+		TargetType: c.Query("target_type"),
+		TargetID:   c.Query("target_id"),
+		Page:       page,
+		Limit:      limit,
+	}
+
+	var err error
+	if filter.From, err = parseTimeQuery(c, "from"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if filter.To, err = parseTimeQuery(c, "to"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := h.service.ListAudit(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// VerifyAudit godoc
+// @Summary      Verify Audit Log
+// @Description  Recompute the hash chain of the audit log and report the first entry that was modified, removed or inserted out of order
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  service.AuditVerification
+// @Failure      500  {object}  map[string]string
+// @Router       /admin/audit/verify [get]
+func (h *AuditHandler) VerifyAudit(c *gin.Context) {
+	result, err := h.service.Verify()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
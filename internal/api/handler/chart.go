@@ -2,6 +2,7 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -17,10 +18,11 @@ import (
 
 type ChartHandler struct {
 	service *service.ChartService
+	audit   *service.AuditService
 }
 
-func NewChartHandler(s *service.ChartService) *ChartHandler {
-	return &ChartHandler{service: s}
+func NewChartHandler(s *service.ChartService, audit *service.AuditService) *ChartHandler {
+	return &ChartHandler{service: s, audit: audit}
 }
 
 type UpdateConfigRequest struct {
@@ -28,6 +30,9 @@ type UpdateConfigRequest struct {
 	RequiredKeys  []string               `json:"required_keys"`
 	VisibleKeys   []string               `json:"visible_keys"`
 	FixedKeys     []string               `json:"fixed_keys"`
+	ValuesPolicy  string                 `json:"values_policy" binding:"omitempty,oneof=reject strip"`
+	Constraints   map[string]interface{} `json:"constraints"`
+	FormFields    map[string]interface{} `json:"form_fields"`
 	Description   string                 `json:"description"`
 }
 
@@ -50,17 +55,46 @@ func (h *ChartHandler) UpdateConfig(c *gin.Context) {
 		RequiredKeys:  model.StringArray(req.RequiredKeys),
 		VisibleKeys:   model.StringArray(req.VisibleKeys),
 		FixedKeys:     model.StringArray(req.FixedKeys),
+		ValuesPolicy:  req.ValuesPolicy,
+		Constraints:   model.JSONMap(req.Constraints),
+		FormFields:    model.JSONMap(req.FormFields),
 		Description:   req.Description,
 	}
 
+	if err := validateChartConfig(req.ValuesPolicy, req.Constraints); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	before, err := h.service.GetMetadata(chartID, version)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get configuration"})
+		return
+	}
+	if before.ID == 0 {
+		before = nil
+	}
+
 	if err := h.service.SaveMetadata(meta); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save configuration"})
 		return
 	}
+	recordAudit(h.audit, c, "chart.config.update", "chart_version", chartID+"/"+version, before, meta)
 
 	c.JSON(http.StatusOK, gin.H{"status": "updated"})
 }
 
+// validateChartConfig rejects a values policy or constraints that would only
+// fail once somebody deploys the chart
+func validateChartConfig(valuesPolicy string, constraints map[string]interface{}) error {
+	if valuesPolicy != "" && valuesPolicy != "reject" && valuesPolicy != "strip" {
+		return fmt.Errorf("invalid values_policy %q: must be reject or strip", valuesPolicy)
+	}
+	// Make sure the constraints compile before they can break deployments
+	_, err := helm.ValidateConstraints(map[string]interface{}{}, constraints)
+	return err
+}
+
 // GetConfig retrieves the admin configuration for a chart
 // GET /admin/charts/:id/versions/:version/config
 func (h *ChartHandler) GetConfig(c *gin.Context) {
@@ -76,6 +110,36 @@ func (h *ChartHandler) GetConfig(c *gin.Context) {
 	c.JSON(http.StatusOK, meta)
 }
 
+// GetDeployForm returns the typed deploy form descriptor for a chart version.
+// Only admins get forms of unpublished charts.
+// GET /api/charts/:id/versions/:version/form
+func (h *ChartHandler) GetDeployForm(c *gin.Context) {
+	chartID := c.Param("id")
+	version := c.Param("version")
+
+	fields, err := h.service.GetDeployForm(chartID, version, c.GetString("role") == "admin")
+	if err != nil {
+		respondChartLookupError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"chart_id": chartID,
+		"version":  version,
+		"fields":   fields,
+	})
+}
+
+// respondChartLookupError reports a missing chart or version as 404 and any
+// other failure as 500
+func respondChartLookupError(c *gin.Context, err error) {
+	if errors.Is(err, service.ErrChartNotFound) || errors.Is(err, service.ErrChartVersionNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
+
 type PublishChartRequest struct {
 	Published bool `json:"published"`
 }
@@ -127,10 +191,21 @@ func (h *ChartHandler) UpdatePublishStatus(c *gin.Context) {
 		return
 	}
 
+	before, err := h.service.GetChart(uint(chartID))
+	if err != nil {
+		respondChartLookupError(c, err)
+		return
+	}
+
 	if err := h.service.UpdatePublishStatus(uint(chartID), req.Published); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update publish status"})
 		return
 	}
+	action := "chart.unpublish"
+	if req.Published {
+		action = "chart.publish"
+	}
+	h.recordChart(c, action, before)
 
 	c.JSON(http.StatusOK, gin.H{"status": "updated"})
 }
@@ -171,10 +246,17 @@ func (h *ChartHandler) UpdateChart(c *gin.Context) {
 		updates["home"] = req.Home
 	}
 
+	before, err := h.service.GetChart(uint(chartID))
+	if err != nil {
+		respondChartLookupError(c, err)
+		return
+	}
+
 	if err := h.service.UpdateChart(uint(chartID), updates); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update chart"})
 		return
 	}
+	h.recordChart(c, "chart.update", before)
 
 	c.JSON(http.StatusOK, gin.H{"status": "updated"})
 }
@@ -199,6 +281,7 @@ func (h *ChartHandler) CreateChart(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create chart"})
 		return
 	}
+	recordAudit(h.audit, c, "chart.create", "chart", fmt.Sprintf("%d", chart.ID), nil, chart)
 
 	c.JSON(http.StatusCreated, chart)
 }
@@ -228,6 +311,7 @@ func (h *ChartHandler) CreateChartVersion(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create chart version"})
 		return
 	}
+	recordAudit(h.audit, c, "chart.version.create", "chart_version", fmt.Sprintf("%d/%s", version.ChartID, version.Version), nil, version)
 
 	c.JSON(http.StatusCreated, version)
 }
@@ -302,6 +386,7 @@ func (h *ChartHandler) UploadChart(c *gin.Context) {
 		AppVersion:    chartInfo.AppVersion,
 		LocalPath:     targetPath, // Store absolute or relative path? Relative is better for portability.
 		DefaultValues: chartInfo.DefaultValues,
+		ValuesSchema:  chartInfo.ValuesSchema,
 		Published:     true, // Auto publish uploaded charts? Or let user decide? Let's default to true for convenience.
 	}
 
@@ -312,6 +397,7 @@ func (h *ChartHandler) UploadChart(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save chart metadata: " + err.Error()})
 		return
 	}
+	recordAudit(h.audit, c, "chart.upload", "chart", fmt.Sprintf("%d", chart.ID), nil, gin.H{"chart": chart, "version": version})
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Chart uploaded successfully",
@@ -377,6 +463,9 @@ func (h *ChartHandler) OnboardChart(c *gin.Context) {
 		RequiredKeys  []string               `json:"required_keys"`
 		VisibleKeys   []string               `json:"visible_keys"`
 		FixedKeys     []string               `json:"fixed_keys"`
+		ValuesPolicy  string                 `json:"values_policy"`
+		Constraints   map[string]interface{} `json:"constraints"`
+		FormFields    map[string]interface{} `json:"form_fields"`
 	}
 
 	var meta OnboardMetadata
@@ -384,6 +473,10 @@ func (h *ChartHandler) OnboardChart(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid metadata JSON: " + err.Error()})
 		return
 	}
+	if err := validateChartConfig(meta.ValuesPolicy, meta.Constraints); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	// 3. Save file permanently
 	tempDir := os.TempDir()
@@ -425,6 +518,7 @@ func (h *ChartHandler) OnboardChart(c *gin.Context) {
 		AppVersion:    chartInfo.AppVersion,
 		LocalPath:     targetPath,
 		DefaultValues: chartInfo.DefaultValues, // Raw defaults from chart
+		ValuesSchema:  chartInfo.ValuesSchema,
 		Published:     meta.Published,
 	}
 
@@ -434,6 +528,7 @@ func (h *ChartHandler) OnboardChart(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create chart: " + err.Error()})
 		return
 	}
+	recordAudit(h.audit, c, "chart.onboard", "chart", fmt.Sprintf("%d", chart.ID), nil, gin.H{"chart": chart, "version": version})
 
 	// 5. Save Admin Metadata
 	chartMeta := &model.ChartMetadata{
@@ -444,6 +539,9 @@ func (h *ChartHandler) OnboardChart(c *gin.Context) {
 		RequiredKeys:  model.StringArray(meta.RequiredKeys),
 		VisibleKeys:   model.StringArray(meta.VisibleKeys),
 		FixedKeys:     model.StringArray(meta.FixedKeys),
+		ValuesPolicy:  meta.ValuesPolicy,
+		Constraints:   model.JSONMap(meta.Constraints),
+		FormFields:    model.JSONMap(meta.FormFields),
 	}
 
 	if err := h.service.SaveMetadata(chartMeta); err != nil {
@@ -454,6 +552,7 @@ func (h *ChartHandler) OnboardChart(c *gin.Context) {
 		})
 		return
 	}
+	recordAudit(h.audit, c, "chart.config.update", "chart_version", chartMeta.ChartID+"/"+chartMeta.Version, nil, chartMeta)
 
 	c.JSON(http.StatusCreated, gin.H{
 		"message": "Chart onboarded successfully",
@@ -495,11 +594,27 @@ func (h *ChartHandler) DeleteChart(c *gin.Context) {
 		return
 	}
 
+	before, err := h.service.GetChart(uint(chartID))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	err = h.service.DeleteChart(uint(chartID))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	recordAudit(h.audit, c, "chart.delete", "chart", c.Param("id"), before, nil)
 
 	c.JSON(http.StatusOK, gin.H{"message": "Chart deleted successfully"})
 }
+
+// recordChart records a change to a chart with its state before and after
+func (h *ChartHandler) recordChart(c *gin.Context, action string, before *model.Chart) {
+	after, err := h.service.GetChart(before.ID)
+	if err != nil {
+		after = nil
+	}
+	recordAudit(h.audit, c, action, "chart", fmt.Sprintf("%d", before.ID), before, after)
+}
@@ -0,0 +1,230 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/your-org/app-market/internal/service"
+)
+
+type ClusterHandler struct {
+	service *service.ClusterService
+	audit   *service.AuditService
+}
+
+func NewClusterHandler(s *service.ClusterService, audit *service.AuditService) *ClusterHandler {
+	return &ClusterHandler{service: s, audit: audit}
+}
+
+type CreateClusterRequest struct {
+	Name        string `json:"name" binding:"required" example:"prod-eu"`
+	Description string `json:"description" example:"Production cluster in eu-west-1"`
+	Mode        string `json:"mode" binding:"omitempty,oneof=kubeconfig in_cluster" example:"kubeconfig"`
+	Kubeconfig  string `json:"kubeconfig"`                // Kubeconfig content, required in kubeconfig mode
+	Context     string `json:"context" example:"prod-eu"` // Kubeconfig context, empty for the current one
+	IsDefault   *bool  `json:"is_default" example:"false"`
+}
+
+type UpdateClusterRequest struct {
+	Name        string `json:"name" example:"prod-eu"`
+	Description string `json:"description" example:"Production cluster in eu-west-1"`
+	Mode        string `json:"mode" binding:"omitempty,oneof=kubeconfig in_cluster" example:"kubeconfig"`
+	Kubeconfig  string `json:"kubeconfig"` // Replaces the stored kubeconfig if set
+	Context     string `json:"context" example:"prod-eu"`
+	IsDefault   *bool  `json:"is_default" example:"true"`
+}
+
+type TestClusterResponse struct {
+	Version string `json:"version" example:"v1.28.2"`
+}
+
+// ListClusters godoc
+// @Summary      List Clusters
+// @Description  List the registered Kubernetes clusters
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {array}   model.Cluster
+// @Failure      500  {object}  map[string]string
+// @Router       /admin/clusters [get]
+func (h *ClusterHandler) ListClusters(c *gin.Context) {
+	clusters, err := h.service.ListClusters()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list clusters"})
+		return
+	}
+	c.JSON(http.StatusOK, clusters)
+}
+
+// CreateCluster godoc
+// @Summary      Register Cluster
+// @Description  Register a cluster by kubeconfig content or the in-cluster service account. The kubeconfig is stored encrypted.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request  body  CreateClusterRequest  true  "Cluster"
+// @Success      201  {object}  model.Cluster
+// @Failure      400  {object}  map[string]string
+// @Router       /admin/clusters [post]
+func (h *ClusterHandler) CreateCluster(c *gin.Context) {
+	var req CreateClusterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	cluster, err := h.service.CreateCluster(service.ClusterInput{
+		Name:        req.Name,
+		Description: req.Description,
+		Mode:        req.Mode,
+		Kubeconfig:  req.Kubeconfig,
+		Context:     req.Context,
+		IsDefault:   req.IsDefault,
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	recordAudit(h.audit, c, "cluster.create", "cluster", fmt.Sprintf("%d", cluster.ID), nil, cluster)
+
+	c.JSON(http.StatusCreated, cluster)
+}
+
+// GetCluster godoc
+// @Summary      Get Cluster
+// @Description  Get a registered cluster
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path  int  true  "Cluster ID"
+// @Success      200  {object}  model.Cluster
+// @Failure      404  {object}  map[string]string
+// @Router       /admin/clusters/{id} [get]
+func (h *ClusterHandler) GetCluster(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	cluster, err := h.service.GetCluster(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, cluster)
+}
+
+// UpdateCluster godoc
+// @Summary      Update Cluster
+// @Description  Update a cluster. Omitted fields keep their value; a new kubeconfig replaces the stored one.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id       path  int                   true  "Cluster ID"
+// @Param        request  body  UpdateClusterRequest  true  "Changes"
+// @Success      200  {object}  model.Cluster
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /admin/clusters/{id} [put]
+func (h *ClusterHandler) UpdateCluster(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	var req UpdateClusterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	before, err := h.service.GetCluster(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	cluster, err := h.service.UpdateCluster(uint(id), service.ClusterInput{
+		Name:        req.Name,
+		Description: req.Description,
+		Mode:        req.Mode,
+		Kubeconfig:  req.Kubeconfig,
+		Context:     req.Context,
+		IsDefault:   req.IsDefault,
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// The kubeconfig itself is never recorded, only that it was replaced
+	action := "cluster.update"
+	if req.Kubeconfig != "" {
+		action = "cluster.kubeconfig.update"
+	}
+	recordAudit(h.audit, c, action, "cluster", c.Param("id"), before, cluster)
+
+	c.JSON(http.StatusOK, cluster)
+}
+
+// DeleteCluster godoc
+// @Summary      Delete Cluster
+// @Description  Remove a cluster. Clusters that still have instances cannot be removed.
+// @Tags         admin
+// @Security     BearerAuth
+// @Param        id   path  int  true  "Cluster ID"
+// @Success      204
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /admin/clusters/{id} [delete]
+func (h *ClusterHandler) DeleteCluster(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	before, err := h.service.GetCluster(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.service.DeleteCluster(uint(id)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	recordAudit(h.audit, c, "cluster.delete", "cluster", c.Param("id"), before, nil)
+
+	c.Status(http.StatusNoContent)
+}
+
+// TestCluster godoc
+// @Summary      Test Cluster
+// @Description  Connect to a cluster and return its Kubernetes version
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path  int  true  "Cluster ID"
+// @Success      200  {object}  TestClusterResponse
+// @Failure      502  {object}  map[string]string
+// @Router       /admin/clusters/{id}/test [post]
+func (h *ClusterHandler) TestCluster(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	version, err := h.service.TestCluster(uint(id))
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, TestClusterResponse{Version: version})
+}
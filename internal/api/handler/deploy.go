@@ -1,11 +1,13 @@
 package handler
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/your-org/app-market/internal/helm"
 	"github.com/your-org/app-market/internal/model"
 	"github.com/your-org/app-market/internal/service"
 )
@@ -13,22 +15,48 @@ import (
 type DeployHandler struct {
 	service     *service.DeployService
 	taskService *service.TaskService
+	audit       *service.AuditService
 }
 
-func NewDeployHandler(s *service.DeployService, ts *service.TaskService) *DeployHandler {
+func NewDeployHandler(s *service.DeployService, ts *service.TaskService, audit *service.AuditService) *DeployHandler {
 	return &DeployHandler{
 		service:     s,
 		taskService: ts,
+		audit:       audit,
 	}
 }
 
 type DeployRequest struct {
 	ChartID     string                 `json:"chart_id" binding:"required" example:"nginx"`
 	Version     string                 `json:"version" binding:"required" example:"1.0.0"`
+	ClusterID   uint                   `json:"cluster_id" example:"1"` // Target cluster, the default cluster if omitted
 	ReleaseName string                 `json:"release_name" binding:"required" example:"my-nginx"`
 	Namespace   string                 `json:"namespace" binding:"required" example:"default"`
 	UserValues  map[string]interface{} `json:"user_values"`
 	IsQuickMode bool                   `json:"is_quick_mode" example:"true"`
+	Wait        bool                   `json:"wait" example:"false"`   // Wait until all resources are ready
+	Atomic      bool                   `json:"atomic" example:"false"` // Uninstall again if not ready in time, implies wait
+}
+
+type UpgradeInstanceRequest struct {
+	Version     string                 `json:"version" example:"1.1.0"`
+	UserValues  map[string]interface{} `json:"user_values"`           // Omit to keep the current overrides
+	Reset       bool                   `json:"reset" example:"false"` // Reset to the defaults when user_values is omitted
+	IsQuickMode bool                   `json:"is_quick_mode" example:"false"`
+	Wait        bool                   `json:"wait" example:"false"`   // Wait until all resources are ready
+	Atomic      bool                   `json:"atomic" example:"false"` // Roll back if not ready in time, implies wait
+}
+
+type RollbackInstanceRequest struct {
+	Revision int `json:"revision" example:"2"` // 0 rolls back to the previous revision
+}
+
+type AdoptInstanceRequest struct {
+	ClusterID   uint   `json:"cluster_id" example:"1"` // 0 is the market's own cluster
+	Namespace   string `json:"namespace" binding:"required" example:"default"`
+	ReleaseName string `json:"release_name" binding:"required" example:"my-nginx"`
+	UserID      string `json:"user_id" binding:"required" example:"alice"` // Username of the owner of the adopted instance
+	ChartID     string `json:"chart_id" example:"3"`                       // Catalog chart to link, matched by name if empty
 }
 
 type TaskResponse struct {
@@ -37,9 +65,17 @@ type TaskResponse struct {
 	Status  string `json:"status"`
 }
 
+// taskMessage tells whether a task was queued or is held for approval
+func taskMessage(action string, task *model.Task) string {
+	if task.Status == "awaiting_approval" {
+		return action + " awaiting approval"
+	}
+	return action + " queued"
+}
+
 // Deploy godoc
 // @Summary      Deploy Application
-// @Description  Queues a deployment task for a specific chart
+// @Description  Queues a deployment task for a specific chart. Deployments covered by an approval policy wait in awaiting_approval until an approver releases them.
 // @Tags         deploy
 // @Accept       json
 // @Produce      json
@@ -47,6 +83,8 @@ type TaskResponse struct {
 // @Param        request body DeployRequest true "Deployment Parameters"
 // @Success      202  {object}  TaskResponse
 // @Failure      400  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
 // @Failure      500  {object}  map[string]string
 // @Router       /api/deploy [post]
 func (h *DeployHandler) Deploy(c *gin.Context) {
@@ -62,10 +100,19 @@ func (h *DeployHandler) Deploy(c *gin.Context) {
 		UserID:      userID,
 		ChartID:     req.ChartID,
 		Version:     req.Version,
+		ClusterID:   req.ClusterID,
 		ReleaseName: req.ReleaseName,
 		Namespace:   req.Namespace,
 		UserValues:  req.UserValues,
 		IsQuickMode: req.IsQuickMode,
+		Wait:        req.Wait,
+		Atomic:      req.Atomic,
+	}
+
+	// Reject invalid values up front instead of failing inside the task
+	if err := h.service.ValidateDeploy(svcReq, c.GetString("role") == "admin"); err != nil {
+		respondDeployError(c, err)
+		return
 	}
 
 	// Enqueue Task
@@ -74,20 +121,295 @@ func (h *DeployHandler) Deploy(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue deployment: " + err.Error()})
 		return
 	}
+	release := fmt.Sprintf("%d/%s/%s", svcReq.ClusterID, svcReq.Namespace, svcReq.ReleaseName)
+	recordAudit(h.audit, c, "instance.deploy", "release", release, nil, gin.H{
+		"task_id": task.ID,
+		"status":  task.Status,
+		"request": auditDeployRequest{DeployRequest: svcReq, UserValues: auditValues(h.audit, svcReq.UserValues)},
+	})
 
 	c.JSON(http.StatusAccepted, TaskResponse{
-		Message: "Deployment queued",
+		Message: taskMessage("Deployment", task),
 		TaskID:  task.ID,
 		Status:  task.Status,
 	})
 }
 
+// PreviewDeploy godoc
+// @Summary      Preview Deployment
+// @Description  Runs the deploy pipeline as a client-side dry run and returns the merged values, rendered manifests and NOTES
+// @Tags         deploy
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request body DeployRequest true "Deployment Parameters"
+// @Success      200  {object}  service.PreviewResult
+// @Failure      400  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /api/deploy/preview [post]
+func (h *DeployHandler) PreviewDeploy(c *gin.Context) {
+	userID := c.MustGet("userID").(string)
+
+	var req DeployRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := h.service.Preview(c.Request.Context(), service.DeployRequest{
+		UserID:      userID,
+		ChartID:     req.ChartID,
+		Version:     req.Version,
+		ClusterID:   req.ClusterID,
+		ReleaseName: req.ReleaseName,
+		Namespace:   req.Namespace,
+		UserValues:  req.UserValues,
+		IsQuickMode: req.IsQuickMode,
+	}, c.GetString("role") == "admin")
+	if err != nil {
+		respondDeployError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// respondDeployError reports a failed deployment request. Rejected values are
+// a client error listing the offending keys, a forbidden namespace or
+// exceeded quota is 403, a missing chart version, cluster or instance is 404
+// and anything else, such as a failed chart download, is a server error.
+func respondDeployError(c *gin.Context, err error) {
+	var verr *helm.ValidationError
+	var quotaErr *service.QuotaExceededError
+	switch {
+	case errors.As(err, &verr):
+		c.JSON(http.StatusBadRequest, gin.H{"error": verr.Message, "fields": verr.Fields})
+	case errors.As(err, &quotaErr):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error(), "quota": quotaErr})
+	case errors.Is(err, service.ErrNamespaceForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrChartNotFound),
+		errors.Is(err, service.ErrChartVersionNotFound),
+		errors.Is(err, service.ErrClusterNotFound),
+		errors.Is(err, service.ErrInstanceNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// UpgradeInstance godoc
+// @Summary      Upgrade Instance
+// @Description  Queues an in-place upgrade or reconfiguration of a deployed instance. Without user_values the instance keeps its current overrides unless reset is set.
+// @Tags         deploy
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id      path  int                     true  "Instance ID"
+// @Param        request body  UpgradeInstanceRequest  true  "Upgrade Parameters"
+// @Success      202  {object}  TaskResponse
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /api/instances/{id} [put]
+func (h *DeployHandler) UpgradeInstance(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	var req UpgradeInstanceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := c.MustGet("userID").(string)
+
+	// Make sure the instance exists and belongs to the caller before queueing
+	instance, err := h.service.GetInstance(fmt.Sprintf("%d", id), userID)
+	if err != nil {
+		respondDeployError(c, err)
+		return
+	}
+
+	svcReq := service.UpgradeRequest{
+		UserID:      userID,
+		InstanceID:  uint(id),
+		Version:     req.Version,
+		UserValues:  req.UserValues,
+		Reset:       req.Reset,
+		IsQuickMode: req.IsQuickMode,
+		Wait:        req.Wait,
+		Atomic:      req.Atomic,
+	}
+
+	if err := h.service.ValidateUpgrade(svcReq); err != nil {
+		respondDeployError(c, err)
+		return
+	}
+
+	task, err := h.taskService.EnqueueUpgrade(userID, svcReq)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue upgrade: " + err.Error()})
+		return
+	}
+	recordAudit(h.audit, c, "instance.upgrade", "instance", c.Param("id"), instanceSnapshot(h.audit, instance), gin.H{
+		"task_id": task.ID,
+		"request": auditUpgradeRequest{UpgradeRequest: svcReq, UserValues: auditValues(h.audit, svcReq.UserValues)},
+	})
+
+	c.JSON(http.StatusAccepted, TaskResponse{
+		Message: taskMessage("Upgrade", task),
+		TaskID:  task.ID,
+		Status:  task.Status,
+	})
+}
+
+// RollbackInstance godoc
+// @Summary      Rollback Instance
+// @Description  Queues a rollback of an instance to an earlier Helm revision
+// @Tags         deploy
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id      path  int                      true  "Instance ID"
+// @Param        request body  RollbackInstanceRequest  false "Target revision"
+// @Success      202  {object}  TaskResponse
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /api/instances/{id}/rollback [post]
+func (h *DeployHandler) RollbackInstance(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	var req RollbackInstanceRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	userID := c.MustGet("userID").(string)
+
+	instance, err := h.service.GetInstance(fmt.Sprintf("%d", id), userID)
+	if err != nil {
+		respondDeployError(c, err)
+		return
+	}
+
+	task, err := h.taskService.EnqueueRollback(userID, service.RollbackRequest{
+		UserID:     userID,
+		InstanceID: uint(id),
+		Revision:   req.Revision,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue rollback: " + err.Error()})
+		return
+	}
+	recordAudit(h.audit, c, "instance.rollback", "instance", c.Param("id"), instanceSnapshot(h.audit, instance), gin.H{"task_id": task.ID, "revision": req.Revision})
+
+	c.JSON(http.StatusAccepted, TaskResponse{
+		Message: taskMessage("Rollback", task),
+		TaskID:  task.ID,
+		Status:  task.Status,
+	})
+}
+
+// DiffInstance godoc
+// @Summary      Diff Instance Changes
+// @Description  Compares an instance's applied values and deployed manifests with a proposed configuration
+// @Tags         deploy
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id      path  int                     true  "Instance ID"
+// @Param        request body  UpgradeInstanceRequest  true  "Proposed configuration"
+// @Success      200  {object}  service.DiffResult
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /api/instances/{id}/diff [post]
+func (h *DeployHandler) DiffInstance(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	var req UpgradeInstanceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := c.MustGet("userID").(string)
+
+	if _, err := h.service.GetInstance(fmt.Sprintf("%d", id), userID); err != nil {
+		respondDeployError(c, err)
+		return
+	}
+
+	result, err := h.service.Diff(c.Request.Context(), service.UpgradeRequest{
+		UserID:      userID,
+		InstanceID:  uint(id),
+		Version:     req.Version,
+		UserValues:  req.UserValues,
+		Reset:       req.Reset,
+		IsQuickMode: req.IsQuickMode,
+	})
+	if err != nil {
+		respondDeployError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// ListRevisions godoc
+// @Summary      List Instance Revisions
+// @Description  Get the revision history of an instance, newest first
+// @Tags         deploy
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      int  true  "Instance ID"
+// @Success      200  {array}   model.InstanceRevision
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /api/instances/{id}/revisions [get]
+func (h *DeployHandler) ListRevisions(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	userID := c.MustGet("userID").(string)
+
+	revisions, err := h.service.ListRevisions(fmt.Sprintf("%d", id), userID)
+	if err != nil {
+		respondDeployError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, revisions)
+}
+
 // ListInstances godoc
 // @Summary      List Instances
-// @Description  Get all deployed instances for the current user
+// @Description  Get the instances of the current user and all instances in namespaces assigned to them
 // @Tags         deploy
 // @Produce      json
 // @Security     BearerAuth
+// @Param        scope  query  string  false  "all (default) or own to only list the user's own instances"
 // @Success      200  {array}   model.AppInstance
 // @Failure      500  {object}  map[string]string
 // @Router       /api/instances [get]
@@ -95,7 +417,7 @@ func (h *DeployHandler) ListInstances(c *gin.Context) {
 	// ... implementation
 	userID := c.MustGet("userID").(string)
 
-	instances, err := h.service.ListInstances(userID)
+	instances, err := h.service.ListInstances(userID, c.Query("scope") == "own")
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list instances"})
 		return
@@ -108,6 +430,74 @@ func (h *DeployHandler) ListInstances(c *gin.Context) {
 	c.JSON(http.StatusOK, instances)
 }
 
+// GetInstance godoc
+// @Summary      Get Instance
+// @Description  Get an instance with its live Helm release: revision, status, NOTES, owned Kubernetes objects and access endpoints
+// @Tags         deploy
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      int  true  "Instance ID"
+// @Success      200  {object}  service.InstanceDetail
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /api/instances/{id} [get]
+func (h *DeployHandler) GetInstance(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	userID := c.MustGet("userID").(string)
+
+	detail, err := h.service.GetInstanceDetail(c.Request.Context(), fmt.Sprintf("%d", id), userID)
+	if err != nil {
+		respondDeployError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, detail)
+}
+
+// AdoptInstance godoc
+// @Summary      Adopt Release
+// @Description  Create an instance for a Helm release installed outside the market. The chart is matched to the catalog by name and version, or the instance is recorded as unmatched.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request  body      AdoptInstanceRequest  true  "Release and owner"
+// @Success      201      {object}  service.AdoptResult
+// @Failure      400      {object}  map[string]string
+// @Router       /admin/instances/adopt [post]
+func (h *DeployHandler) AdoptInstance(c *gin.Context) {
+	var req AdoptInstanceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := h.service.Adopt(c.Request.Context(), service.AdoptRequest{
+		ClusterID:   req.ClusterID,
+		Namespace:   req.Namespace,
+		ReleaseName: req.ReleaseName,
+		UserID:      req.UserID,
+		ChartID:     req.ChartID,
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	recordAudit(h.audit, c, "instance.adopt", "instance", fmt.Sprintf("%d", result.Instance.ID), nil, gin.H{
+		"instance":        instanceSnapshot(h.audit, result.Instance),
+		"chart_matched":   result.ChartMatched,
+		"version_matched": result.VersionMatched,
+	})
+
+	c.JSON(http.StatusCreated, result)
+}
+
 // GetTaskStatus godoc
 // @Summary      Get Task Status
 // @Description  Check the status of an asynchronous task
@@ -147,14 +537,17 @@ func (h *DeployHandler) GetTaskStatus(c *gin.Context) {
 
 // DeleteInstance godoc
 // @Summary      Delete Instance
-// @Description  Delete a deployed instance from Kubernetes and database
+// @Description  Queues the removal of a deployed instance from Kubernetes and database
 // @Tags         deploy
 // @Produce      json
 // @Security     BearerAuth
-// @Param        id   path      int  true  "Instance ID"
-// @Success      200  {object}  map[string]string
+// @Param        id            path   int   true   "Instance ID"
+// @Param        keep_history  query  bool  false  "Keep Helm release history and instance revisions"
+// @Success      202  {object}  TaskResponse
+// @Failure      400  {object}  map[string]string
 // @Failure      404  {object}  map[string]string
-// @Failure      403  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
 // @Router       /api/instances/{id} [delete]
 func (h *DeployHandler) DeleteInstance(c *gin.Context) {
 	idStr := c.Param("id")
@@ -164,13 +557,33 @@ func (h *DeployHandler) DeleteInstance(c *gin.Context) {
 		return
 	}
 
+	keepHistory, _ := strconv.ParseBool(c.DefaultQuery("keep_history", "false"))
 	userID := c.MustGet("userID").(string)
 
-	err = h.service.DeleteInstance(fmt.Sprintf("%d", id), userID)
+	instance, err := h.service.GetInstance(fmt.Sprintf("%d", id), userID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondDeployError(c, err)
+		return
+	}
+
+	task, err := h.taskService.EnqueueUninstall(userID, service.UninstallRequest{
+		UserID:      userID,
+		InstanceID:  uint(id),
+		KeepHistory: keepHistory,
+	})
+	if err != nil {
+		if errors.Is(err, service.ErrUninstallQueued) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue uninstall: " + err.Error()})
 		return
 	}
+	recordAudit(h.audit, c, "instance.delete", "instance", idStr, instanceSnapshot(h.audit, instance), gin.H{"task_id": task.ID, "keep_history": keepHistory})
 
-	c.JSON(http.StatusOK, gin.H{"message": "Instance deleted successfully"})
+	c.JSON(http.StatusAccepted, TaskResponse{
+		Message: taskMessage("Uninstall", task),
+		TaskID:  task.ID,
+		Status:  task.Status,
+	})
 }
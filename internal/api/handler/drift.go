@@ -0,0 +1,197 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/your-org/app-market/internal/model"
+	"github.com/your-org/app-market/internal/service"
+)
+
+type DriftHandler struct {
+	service *service.DriftService
+	audit   *service.AuditService
+}
+
+func NewDriftHandler(s *service.DriftService, audit *service.AuditService) *DriftHandler {
+	return &DriftHandler{service: s, audit: audit}
+}
+
+// driftSnapshot identifies a drift item in the audit log. Details are left
+// out, the values diff they hold may contain credentials.
+func driftSnapshot(item *model.DriftItem) gin.H {
+	return gin.H{
+		"type":         item.Type,
+		"cluster_id":   item.ClusterID,
+		"namespace":    item.Namespace,
+		"release_name": item.ReleaseName,
+		"instance_id":  item.InstanceID,
+	}
+}
+
+type AdoptDriftRequest struct {
+	UserID  string `json:"user_id" example:"alice"` // Username of the owner of the adopted instance, required for unmanaged releases
+	ChartID string `json:"chart_id" example:"3"`    // Chart the release was installed from, if known
+}
+
+// ListDrift godoc
+// @Summary      List Drift
+// @Description  List mismatches between instances and Helm releases: unmanaged releases, missing releases and values drift
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Param        type            query  string  false  "Drift type (unmanaged_release, missing_release, values_drift)"
+// @Param        include_ignored query  bool    false  "Include ignored unmanaged releases"
+// @Success      200  {array}   model.DriftItem
+// @Failure      500  {object}  map[string]string
+// @Router       /admin/drift [get]
+func (h *DriftHandler) ListDrift(c *gin.Context) {
+	includeIgnored, _ := strconv.ParseBool(c.Query("include_ignored"))
+
+	items, err := h.service.ListDrift(c.Query("type"), includeIgnored)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list drift"})
+		return
+	}
+	c.JSON(http.StatusOK, items)
+}
+
+// ScanDrift godoc
+// @Summary      Scan For Drift
+// @Description  Compare releases and instances now instead of waiting for the next reconciler run
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {array}   model.DriftItem
+// @Failure      500  {object}  map[string]string
+// @Router       /admin/drift/scan [post]
+func (h *DriftHandler) ScanDrift(c *gin.Context) {
+	if err := h.service.Scan(c.Request.Context()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.ListDrift(c)
+}
+
+// AdoptDrift godoc
+// @Summary      Adopt Drift
+// @Description  Accept the cluster state: create an instance for an unmanaged release, or take over the live values of a drifted instance
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id       path  int                true   "Drift item ID"
+// @Param        request  body  AdoptDriftRequest  false  "Owner and chart of an unmanaged release"
+// @Success      200  {object}  model.AppInstance
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /admin/drift/{id}/adopt [post]
+func (h *DriftHandler) AdoptDrift(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	var req AdoptDriftRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	item, err := h.service.GetItem(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	instance, err := h.service.Adopt(c.Request.Context(), uint(id), service.AdoptInput{UserID: req.UserID, ChartID: req.ChartID})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	recordAudit(h.audit, c, "drift.adopt", "drift", c.Param("id"), driftSnapshot(item), instanceSnapshot(h.audit, instance))
+
+	c.JSON(http.StatusOK, instance)
+}
+
+// ResyncDrift godoc
+// @Summary      Re-sync Drift
+// @Description  Push the recorded configuration back to the cluster: reinstall a missing release or re-apply drifted values
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path  int  true  "Drift item ID"
+// @Success      202  {object}  TaskResponse
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /admin/drift/{id}/resync [post]
+func (h *DriftHandler) ResyncDrift(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	userID := c.MustGet("userID").(string)
+
+	item, err := h.service.GetItem(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	task, err := h.service.Resync(uint(id), userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	recordAudit(h.audit, c, "drift.resync", "drift", c.Param("id"), driftSnapshot(item), gin.H{"task_id": task.ID})
+
+	c.JSON(http.StatusAccepted, TaskResponse{
+		Message: taskMessage("Re-sync", task),
+		TaskID:  task.ID,
+		Status:  task.Status,
+	})
+}
+
+// ForgetDrift godoc
+// @Summary      Forget Drift
+// @Description  Drop the drift from the market without touching the cluster: delete the instance of a missing release, or ignore an unmanaged release
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path  int  true  "Drift item ID"
+// @Success      204
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /admin/drift/{id}/forget [post]
+func (h *DriftHandler) ForgetDrift(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	item, err := h.service.GetItem(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.service.Forget(uint(id)); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, service.ErrDriftAction) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	recordAudit(h.audit, c, "drift.forget", "drift", c.Param("id"), driftSnapshot(item), nil)
+
+	c.Status(http.StatusNoContent)
+}
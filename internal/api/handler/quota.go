@@ -0,0 +1,170 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/your-org/app-market/internal/service"
+)
+
+type QuotaHandler struct {
+	service *service.QuotaService
+	audit   *service.AuditService
+}
+
+func NewQuotaHandler(s *service.QuotaService, audit *service.AuditService) *QuotaHandler {
+	return &QuotaHandler{service: s, audit: audit}
+}
+
+type SetQuotaRequest struct {
+	SubjectType  string `json:"subject_type" binding:"required,oneof=user group namespace chart" example:"user"`
+	SubjectID    string `json:"subject_id" binding:"required" example:"alice"` // Username, group ID, namespace or chart ID
+	MaxInstances int    `json:"max_instances" binding:"min=0" example:"10"`    // 0 is unlimited
+	CPU          string `json:"cpu" example:"4"`                               // Budget for CPU requests, empty is unlimited
+	Memory       string `json:"memory" example:"8Gi"`                          // Budget for memory requests, empty is unlimited
+}
+
+// MyUsage godoc
+// @Summary      My Quota Usage
+// @Description  Compare the current user's instances and resource requests with the quotas of the user, their groups and the namespaces they deploy into
+// @Tags         deploy
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {array}   service.QuotaUsage
+// @Failure      500  {object}  map[string]string
+// @Router       /api/quotas/usage [get]
+func (h *QuotaHandler) MyUsage(c *gin.Context) {
+	userID := c.MustGet("userID").(string)
+
+	usages, err := h.service.UserUsage(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, usages)
+}
+
+// SubjectUsage godoc
+// @Summary      Quota Usage
+// @Description  Compare the usage of a user, group, namespace or chart with its quota
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Param        subject_type  query  string  true  "user, group, namespace or chart"
+// @Param        subject_id    query  string  true  "Username, group ID, namespace or chart ID"
+// @Param        cluster_id    query  int     false  "Cluster of a namespace subject, 0 for the local cluster"
+// @Success      200  {object}  service.QuotaUsage
+// @Failure      400  {object}  map[string]string
+// @Router       /admin/quotas/usage [get]
+func (h *QuotaHandler) SubjectUsage(c *gin.Context) {
+	subjectType, subjectID := c.Query("subject_type"), c.Query("subject_id")
+	if subjectType == "" || subjectID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "subject_type and subject_id are required"})
+		return
+	}
+
+	var clusterID uint64
+	if v := c.Query("cluster_id"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cluster ID"})
+			return
+		}
+		clusterID = id
+	}
+
+	usage, err := h.service.SubjectUsage(subjectType, subjectID, uint(clusterID))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, usage)
+}
+
+// ListQuotas godoc
+// @Summary      List Quotas
+// @Description  List the quotas set for users, groups, namespaces and charts. Users and namespaces without one use the configured defaults.
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Param        subject_type  query  string  false  "user, group, namespace or chart"
+// @Success      200  {array}   model.Quota
+// @Failure      500  {object}  map[string]string
+// @Router       /admin/quotas [get]
+func (h *QuotaHandler) ListQuotas(c *gin.Context) {
+	quotas, err := h.service.ListQuotas(c.Query("subject_type"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list quotas"})
+		return
+	}
+	c.JSON(http.StatusOK, quotas)
+}
+
+// SetQuota godoc
+// @Summary      Set Quota
+// @Description  Create or replace the quota of a user, group, namespace or chart
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request  body  SetQuotaRequest  true  "Quota"
+// @Success      200  {object}  model.Quota
+// @Failure      400  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /admin/quotas [put]
+func (h *QuotaHandler) SetQuota(c *gin.Context) {
+	var req SetQuotaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	before, err := h.service.GetQuota(req.SubjectType, req.SubjectID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	quota, err := h.service.SetQuota(service.QuotaInput{
+		SubjectType:  req.SubjectType,
+		SubjectID:    req.SubjectID,
+		MaxInstances: req.MaxInstances,
+		CPU:          req.CPU,
+		Memory:       req.Memory,
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	recordAudit(h.audit, c, "quota.set", "quota", fmt.Sprintf("%d", quota.ID), before, quota)
+
+	c.JSON(http.StatusOK, quota)
+}
+
+// DeleteQuota godoc
+// @Summary      Delete Quota
+// @Description  Remove a quota. Users and namespaces fall back to the configured defaults.
+// @Tags         admin
+// @Security     BearerAuth
+// @Param        id   path  int  true  "Quota ID"
+// @Success      204
+// @Failure      404  {object}  map[string]string
+// @Router       /admin/quotas/{id} [delete]
+func (h *QuotaHandler) DeleteQuota(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	before, err := h.service.DeleteQuota(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	recordAudit(h.audit, c, "quota.delete", "quota", c.Param("id"), before, nil)
+
+	c.Status(http.StatusNoContent)
+}
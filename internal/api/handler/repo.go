@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -11,26 +12,66 @@ import (
 
 type RepoHandler struct {
 	service *service.SyncService
+	audit   *service.AuditService
 }
 
-func NewRepoHandler(s *service.SyncService) *RepoHandler {
-	return &RepoHandler{service: s}
+func NewRepoHandler(s *service.SyncService, audit *service.AuditService) *RepoHandler {
+	return &RepoHandler{service: s, audit: audit}
 }
 
 type AddRepoRequest struct {
-	Name string `json:"name" binding:"required" example:"bitnami"`
-	URL  string `json:"url" binding:"required" example:"https://charts.bitnami.com/bitnami"`
+	Name         string `json:"name" binding:"required" example:"bitnami"`
+	URL          string `json:"url" binding:"required" example:"https://charts.bitnami.com/bitnami"`
+	SyncInterval *int   `json:"sync_interval" example:"3600"` // Seconds between scheduled syncs, 0 or empty syncs only on request
+	RepoCredentials
+}
+
+type UpdateRepoRequest struct {
+	Name         string `json:"name" example:"bitnami"`
+	URL          string `json:"url" example:"https://charts.bitnami.com/bitnami"`
+	SyncInterval *int   `json:"sync_interval" example:"3600"` // Seconds between scheduled syncs, 0 disables them
+	RepoCredentials
+}
+
+// RepoCredentials are the auth and TLS settings of a repo. Secrets are stored
+// encrypted and never returned.
+type RepoCredentials struct {
+	AuthType           string `json:"auth_type" binding:"omitempty,oneof=none basic bearer" example:"basic"` // Replaces the current credentials if set
+	Username           string `json:"username" example:"robot"`
+	Password           string `json:"password"`
+	BearerToken        string `json:"bearer_token"`
+	CACert             string `json:"ca_cert"`     // PEM, trusted in addition to the system roots
+	ClientCert         string `json:"client_cert"` // PEM, set together with client_key for mutual TLS
+	ClientKey          string `json:"client_key"`  // PEM
+	InsecureSkipVerify *bool  `json:"insecure_skip_verify" example:"false"`
+}
+
+func repoInput(name, url string, syncInterval *int, creds RepoCredentials) service.RepoInput {
+	return service.RepoInput{
+		Name:               name,
+		URL:                url,
+		SyncInterval:       syncInterval,
+		AuthType:           creds.AuthType,
+		Username:           creds.Username,
+		Password:           creds.Password,
+		BearerToken:        creds.BearerToken,
+		CACert:             creds.CACert,
+		ClientCert:         creds.ClientCert,
+		ClientKey:          creds.ClientKey,
+		InsecureSkipVerify: creds.InsecureSkipVerify,
+	}
 }
 
 // AddRepo godoc
 // @Summary      Add Chart Repository
-// @Description  Register a new Helm chart repository
+// @Description  Register a new Helm chart repository, optionally with basic auth, a bearer token, a client certificate or a custom CA
 // @Tags         repo
 // @Accept       json
 // @Produce      json
 // @Param        request body AddRepoRequest true "Repo Details"
 // @Success      201  {object}  map[string]string
 // @Failure      400  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
 // @Failure      500  {object}  map[string]string
 // @Router       /admin/repos [post]
 func (h *RepoHandler) AddRepo(c *gin.Context) {
@@ -40,14 +81,29 @@ func (h *RepoHandler) AddRepo(c *gin.Context) {
 		return
 	}
 
-	if err := h.service.AddRepo(req.Name, req.URL); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add repo"})
+	repo, err := h.service.AddRepo(repoInput(req.Name, req.URL, req.SyncInterval, req.RepoCredentials))
+	if err != nil {
+		respondRepoSaveError(c, err, "Failed to add repo")
 		return
 	}
+	recordAudit(h.audit, c, "repo.add", "repo", strconv.FormatUint(uint64(repo.ID), 10), nil, repo)
 
 	c.JSON(http.StatusCreated, gin.H{"status": "created"})
 }
 
+// respondRepoSaveError reports invalid settings as 400 and a taken name as 409,
+// and hides the details of any other failure
+func respondRepoSaveError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, service.ErrInvalidRepo):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrRepoExists):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
 // ListRepos godoc
 // @Summary      List Repositories
 // @Description  Get all registered chart repositories
@@ -68,13 +124,57 @@ func (h *RepoHandler) ListRepos(c *gin.Context) {
 	c.JSON(http.StatusOK, repos)
 }
 
+// UpdateRepo godoc
+// @Summary      Update Repository
+// @Description  Update a repository's name, URL, sync interval, credentials or TLS settings. Omitted fields keep their value.
+// @Tags         repo
+// @Accept       json
+// @Produce      json
+// @Param        id       path  int                true  "Repo ID"
+// @Param        request  body  UpdateRepoRequest  true  "Changes"
+// @Success      200  {object}  model.ChartRepo
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /admin/repos/{id} [put]
+func (h *RepoHandler) UpdateRepo(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	var req UpdateRepoRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	before, err := h.service.GetRepo(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	repo, err := h.service.UpdateRepo(uint(id), repoInput(req.Name, req.URL, req.SyncInterval, req.RepoCredentials))
+	if err != nil {
+		respondRepoSaveError(c, err, "Failed to update repo")
+		return
+	}
+	recordAudit(h.audit, c, "repo.update", "repo", c.Param("id"), before, repo)
+
+	c.JSON(http.StatusOK, repo)
+}
+
 // SyncRepo godoc
 // @Summary      Sync Repository
-// @Description  Trigger index.yaml synchronization for a repo
+// @Description  Trigger index.yaml synchronization for a repo. An index unchanged since the last sync (same ETag, Last-Modified or generated timestamp) is skipped unless force is set.
 // @Tags         repo
 // @Produce      json
-// @Param        id   path      int  true  "Repo ID"
-// @Success      200  {object}  map[string]string
+// @Param        id     path   int   true   "Repo ID"
+// @Param        force  query  bool  false  "Sync even if the index is unchanged"
+// @Success      200  {object}  service.SyncResult
 // @Failure      400  {object}  map[string]string
 // @Failure      500  {object}  map[string]string
 // @Router       /admin/repos/{id}/sync [post]
@@ -86,10 +186,15 @@ func (h *RepoHandler) SyncRepo(c *gin.Context) {
 		return
 	}
 
-	if err := h.service.SyncRepo(uint(id)); err != nil {
+	force, _ := strconv.ParseBool(c.DefaultQuery("force", "false"))
+
+	result, err := h.service.SyncRepo(c.Request.Context(), uint(id), force)
+	if err != nil {
+		recordAudit(h.audit, c, "repo.sync", "repo", idStr, nil, gin.H{"force": force, "error": err.Error()})
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	recordAudit(h.audit, c, "repo.sync", "repo", idStr, nil, result)
 
-	c.JSON(http.StatusOK, gin.H{"status": "synced"})
+	c.JSON(http.StatusOK, result)
 }
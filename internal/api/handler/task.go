@@ -0,0 +1,291 @@
+package handler
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+	"github.com/your-org/app-market/internal/service"
+)
+
+// eventPollInterval bounds how long a stream waits for events written by
+// workers in other processes, which cannot signal this one directly
+const eventPollInterval = time.Second
+
+type TaskHandler struct {
+	service *service.TaskService
+	audit   *service.AuditService
+}
+
+func NewTaskHandler(s *service.TaskService, audit *service.AuditService) *TaskHandler {
+	return &TaskHandler{service: s, audit: audit}
+}
+
+// ListTasks godoc
+// @Summary      List My Tasks
+// @Description  List the caller's tasks, newest first by default
+// @Tags         deploy
+// @Produce      json
+// @Security     BearerAuth
+// @Param        status       query  string  false  "Status (pending, running, completed, failed, cancelled)"
+// @Param        type         query  string  false  "Type (deploy, upgrade, rollback, uninstall)"
+// @Param        chart_id     query  string  false  "Chart ID"
+// @Param        instance_id  query  int     false  "Instance ID"
+// @Param        from         query  string  false  "Created at or after (RFC3339 or YYYY-MM-DD)"
+// @Param        to           query  string  false  "Created before (RFC3339 or YYYY-MM-DD)"
+// @Param        sort         query  string  false  "Sort field (id, created_at, updated_at, status, type)"
+// @Param        order        query  string  false  "Sort order (asc, desc)"
+// @Param        page         query  int     false  "Page number"
+// @Param        limit        query  int     false  "Page size"
+// @Success      200  {object}  service.TaskListOutput
+// @Failure      400  {object}  map[string]string
+// @Router       /api/tasks [get]
+func (h *TaskHandler) ListTasks(c *gin.Context) {
+	filter, err := parseTaskFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	filter.UserID = c.MustGet("userID").(string)
+
+	h.listTasks(c, filter)
+}
+
+// AdminListTasks godoc
+// @Summary      List All Tasks
+// @Description  List tasks of all users, newest first by default
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Param        user_id      query  string  false  "Username"
+// @Param        status       query  string  false  "Status (pending, running, completed, failed, cancelled)"
+// @Param        type         query  string  false  "Type (deploy, upgrade, rollback, uninstall)"
+// @Param        chart_id     query  string  false  "Chart ID"
+// @Param        instance_id  query  int     false  "Instance ID"
+// @Param        from         query  string  false  "Created at or after (RFC3339 or YYYY-MM-DD)"
+// @Param        to           query  string  false  "Created before (RFC3339 or YYYY-MM-DD)"
+// @Param        sort         query  string  false  "Sort field (id, created_at, updated_at, status, type)"
+// @Param        order        query  string  false  "Sort order (asc, desc)"
+// @Param        page         query  int     false  "Page number"
+// @Param        limit        query  int     false  "Page size"
+// @Success      200  {object}  service.TaskListOutput
+// @Failure      400  {object}  map[string]string
+// @Router       /admin/tasks [get]
+func (h *TaskHandler) AdminListTasks(c *gin.Context) {
+	filter, err := parseTaskFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	filter.UserID = c.Query("user_id")
+
+	h.listTasks(c, filter)
+}
+
+func (h *TaskHandler) listTasks(c *gin.Context, filter service.TaskFilter) {
+	result, err := h.service.ListTasks(filter)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// parseTaskFilter reads the filters shared by the task list endpoints
+func parseTaskFilter(c *gin.Context) (service.TaskFilter, error) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+	filter := service.TaskFilter{
+		Status:  c.Query("status"),
+		Type:    c.Query("type"),
+		ChartID: c.Query("chart_id"),
+		Sort:    c.Query("sort"),
+		Order:   c.Query("order"),
+		Page:    page,
+		Limit:   limit,
+	}
+
+	if v := c.Query("instance_id"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return filter, fmt.Errorf("invalid instance_id")
+		}
+		filter.InstanceID = uint(id)
+	}
+
+	var err error
+	if filter.From, err = parseTimeQuery(c, "from"); err != nil {
+		return filter, err
+	}
+	if filter.To, err = parseTimeQuery(c, "to"); err != nil {
+		return filter, err
+	}
+	return filter, nil
+}
+
+// parseTimeQuery parses an RFC3339 timestamp or a plain date from the query
+func parseTimeQuery(c *gin.Context, name string) (*time.Time, error) {
+	v := c.Query(name)
+	if v == "" {
+		return nil, nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+		if t, err := time.Parse(layout, v); err == nil {
+			return &t, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid %s: expected RFC3339 or YYYY-MM-DD", name)
+}
+
+// PoolStats godoc
+// @Summary      Task Worker Pool
+// @Description  Get worker pool utilisation and queue depth
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  service.PoolStats
+// @Failure      500  {object}  map[string]string
+// @Router       /admin/tasks/pool [get]
+func (h *TaskHandler) PoolStats(c *gin.Context) {
+	stats, err := h.service.PoolStats()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get pool stats"})
+		return
+	}
+	c.JSON(http.StatusOK, stats)
+}
+
+// CancelTask godoc
+// @Summary      Cancel Task
+// @Description  Cancel a pending or running task
+// @Tags         deploy
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      int  true  "Task ID"
+// @Success      200  {object}  model.Task
+// @Failure      400  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Router       /api/tasks/{id}/cancel [post]
+func (h *TaskHandler) CancelTask(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	task, err := h.service.GetTask(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+		return
+	}
+
+	// Owners can cancel their own tasks, admins can cancel any task
+	userID := c.MustGet("userID").(string)
+	if task.UserID != userID && c.GetString("role") != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+		return
+	}
+
+	before := task.Status
+	task, err = h.service.CancelTask(uint(id))
+	if err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	// The payload may carry user values, so only the task's state is recorded
+	recordAudit(h.audit, c, "task.cancel", "task", c.Param("id"),
+		gin.H{"type": task.Type, "status": before, "user_id": task.UserID},
+		gin.H{"type": task.Type, "status": task.Status, "cancel_requested": task.CancelRequested})
+
+	c.JSON(http.StatusOK, task)
+}
+
+// Events godoc
+// @Summary      Stream Task Events
+// @Description  Stream progress steps, Helm log lines and status changes of a task as Server-Sent Events. The stream replays past events, honours Last-Event-ID on reconnect and ends once the task has finished.
+// @Tags         deploy
+// @Produce      text/event-stream
+// @Security     BearerAuth
+// @Param        id             path    int     true   "Task ID"
+// @Param        Last-Event-ID  header  string  false  "ID of the last event received"
+// @Success      200  {object}  model.TaskEvent
+// @Failure      400  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /api/tasks/{id}/events [get]
+func (h *TaskHandler) Events(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	task, err := h.service.GetTask(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+		return
+	}
+
+	userID := c.MustGet("userID").(string)
+	if task.UserID != userID && c.GetString("role") != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+		return
+	}
+
+	var lastID uint64
+	if header := c.GetHeader("Last-Event-ID"); header != "" {
+		lastID, _ = strconv.ParseUint(header, 10, 64)
+	}
+
+	// Subscribe before the first read so no event slips through in between
+	notify, unsubscribe := h.service.SubscribeEvents(task.ID)
+	defer unsubscribe()
+
+	ticker := time.NewTicker(eventPollInterval)
+	defer ticker.Stop()
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	c.Stream(func(w io.Writer) bool {
+		// Read the status before the events so a final event written just
+		// before the task finished is never missed
+		task, err := h.service.GetTask(task.ID)
+		if err != nil {
+			return false
+		}
+
+		events, err := h.service.ListEvents(task.ID, uint(lastID))
+		if err != nil {
+			return false
+		}
+		for _, event := range events {
+			c.Render(-1, sse.Event{
+				Id:    strconv.FormatUint(uint64(event.ID), 10),
+				Event: event.Type,
+				Data:  event,
+			})
+			lastID = uint64(event.ID)
+		}
+
+		if service.IsTerminal(task) {
+			return false
+		}
+
+		select {
+		case <-notify:
+		case <-ticker.C:
+		case <-c.Request.Context().Done():
+			return false
+		}
+		return true
+	})
+}
@@ -0,0 +1,269 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/your-org/app-market/internal/service"
+)
+
+type TenancyHandler struct {
+	service *service.TenancyService
+	audit   *service.AuditService
+}
+
+func NewTenancyHandler(s *service.TenancyService, audit *service.AuditService) *TenancyHandler {
+	return &TenancyHandler{service: s, audit: audit}
+}
+
+type CreateGroupRequest struct {
+	Name        string `json:"name" binding:"required" example:"team-a"`
+	Description string `json:"description" example:"Team A developers"`
+}
+
+type AddGroupMemberRequest struct {
+	UserID string `json:"user_id" binding:"required" example:"alice"` // Username
+}
+
+type CreateGrantRequest struct {
+	ClusterID   *uint  `json:"cluster_id" example:"1"` // Omit to grant the namespace on every cluster
+	Namespace   string `json:"namespace" binding:"required" example:"team-a-"`
+	Prefix      bool   `json:"prefix" example:"true"` // Grant every namespace starting with Namespace
+	SubjectType string `json:"subject_type" binding:"required,oneof=user group" example:"group"`
+	SubjectID   string `json:"subject_id" binding:"required" example:"1"` // Username or group ID
+}
+
+// MyNamespaces godoc
+// @Summary      My Namespaces
+// @Description  List the namespaces and namespace prefixes the current user may deploy into
+// @Tags         deploy
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  service.NamespaceAccess
+// @Failure      500  {object}  map[string]string
+// @Router       /api/namespaces [get]
+func (h *TenancyHandler) MyNamespaces(c *gin.Context) {
+	userID := c.MustGet("userID").(string)
+
+	access, err := h.service.Access(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, access)
+}
+
+// ListGroups godoc
+// @Summary      List Groups
+// @Description  List user groups with their members
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {array}   model.Group
+// @Failure      500  {object}  map[string]string
+// @Router       /admin/groups [get]
+func (h *TenancyHandler) ListGroups(c *gin.Context) {
+	groups, err := h.service.ListGroups()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list groups"})
+		return
+	}
+	c.JSON(http.StatusOK, groups)
+}
+
+// CreateGroup godoc
+// @Summary      Create Group
+// @Description  Create a user group that namespaces can be granted to
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request  body  CreateGroupRequest  true  "Group"
+// @Success      201  {object}  model.Group
+// @Failure      400  {object}  map[string]string
+// @Router       /admin/groups [post]
+func (h *TenancyHandler) CreateGroup(c *gin.Context) {
+	var req CreateGroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	group, err := h.service.CreateGroup(req.Name, req.Description)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	recordAudit(h.audit, c, "group.create", "group", fmt.Sprintf("%d", group.ID), nil, group)
+
+	c.JSON(http.StatusCreated, group)
+}
+
+// DeleteGroup godoc
+// @Summary      Delete Group
+// @Description  Delete a group together with its memberships and namespace grants
+// @Tags         admin
+// @Security     BearerAuth
+// @Param        id   path  int  true  "Group ID"
+// @Success      204
+// @Failure      404  {object}  map[string]string
+// @Router       /admin/groups/{id} [delete]
+func (h *TenancyHandler) DeleteGroup(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	before, err := h.service.DeleteGroup(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	recordAudit(h.audit, c, "group.delete", "group", c.Param("id"), before, nil)
+
+	c.Status(http.StatusNoContent)
+}
+
+// AddGroupMember godoc
+// @Summary      Add Group Member
+// @Description  Add a user to a group
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id       path  int                    true  "Group ID"
+// @Param        request  body  AddGroupMemberRequest  true  "Member"
+// @Success      201  {object}  model.GroupMember
+// @Failure      400  {object}  map[string]string
+// @Router       /admin/groups/{id}/members [post]
+func (h *TenancyHandler) AddGroupMember(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	var req AddGroupMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	member, err := h.service.AddGroupMember(uint(id), req.UserID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	recordAudit(h.audit, c, "group.member.add", "group", c.Param("id"), nil, member)
+
+	c.JSON(http.StatusCreated, member)
+}
+
+// RemoveGroupMember godoc
+// @Summary      Remove Group Member
+// @Description  Remove a user from a group
+// @Tags         admin
+// @Security     BearerAuth
+// @Param        id       path  int     true  "Group ID"
+// @Param        user_id  path  string  true  "Username"
+// @Success      204
+// @Failure      404  {object}  map[string]string
+// @Router       /admin/groups/{id}/members/{user_id} [delete]
+func (h *TenancyHandler) RemoveGroupMember(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	if err := h.service.RemoveGroupMember(uint(id), c.Param("user_id")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	recordAudit(h.audit, c, "group.member.remove", "group", c.Param("id"), gin.H{"group_id": id, "user_id": c.Param("user_id")}, nil)
+
+	c.Status(http.StatusNoContent)
+}
+
+// ListGrants godoc
+// @Summary      List Namespace Grants
+// @Description  List the namespaces and namespace prefixes assigned to users and groups
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Param        subject_type  query  string  false  "user or group"
+// @Param        subject_id    query  string  false  "Username or group ID"
+// @Success      200  {array}   model.NamespaceGrant
+// @Failure      500  {object}  map[string]string
+// @Router       /admin/namespace-grants [get]
+func (h *TenancyHandler) ListGrants(c *gin.Context) {
+	grants, err := h.service.ListGrants(c.Query("subject_type"), c.Query("subject_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list namespace grants"})
+		return
+	}
+	c.JSON(http.StatusOK, grants)
+}
+
+// CreateGrant godoc
+// @Summary      Grant Namespace
+// @Description  Assign a namespace, or every namespace with a prefix, to a user or group
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request  body  CreateGrantRequest  true  "Grant"
+// @Success      201  {object}  model.NamespaceGrant
+// @Failure      400  {object}  map[string]string
+// @Router       /admin/namespace-grants [post]
+func (h *TenancyHandler) CreateGrant(c *gin.Context) {
+	var req CreateGrantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	grant, err := h.service.CreateGrant(service.GrantInput{
+		ClusterID:   req.ClusterID,
+		Namespace:   req.Namespace,
+		Prefix:      req.Prefix,
+		SubjectType: req.SubjectType,
+		SubjectID:   req.SubjectID,
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	recordAudit(h.audit, c, "namespace_grant.create", "namespace_grant", fmt.Sprintf("%d", grant.ID), nil, grant)
+
+	c.JSON(http.StatusCreated, grant)
+}
+
+// DeleteGrant godoc
+// @Summary      Revoke Namespace
+// @Description  Revoke a namespace grant. Existing instances are kept.
+// @Tags         admin
+// @Security     BearerAuth
+// @Param        id   path  int  true  "Grant ID"
+// @Success      204
+// @Failure      404  {object}  map[string]string
+// @Router       /admin/namespace-grants/{id} [delete]
+func (h *TenancyHandler) DeleteGrant(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	before, err := h.service.DeleteGrant(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	recordAudit(h.audit, c, "namespace_grant.delete", "namespace_grant", c.Param("id"), before, nil)
+
+	c.Status(http.StatusNoContent)
+}
@@ -11,10 +11,11 @@ import (
 
 type UserHandler struct {
 	service *service.UserService
+	audit   *service.AuditService
 }
 
-func NewUserHandler(service *service.UserService) *UserHandler {
-	return &UserHandler{service: service}
+func NewUserHandler(service *service.UserService, audit *service.AuditService) *UserHandler {
+	return &UserHandler{service: service, audit: audit}
 }
 
 type CreateUserRequest struct {
@@ -134,6 +135,7 @@ func (h *UserHandler) CreateUser(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	recordAudit(h.audit, c, "user.create", "user", strconv.FormatUint(uint64(user.ID), 10), nil, userToResponse(user))
 
 	c.JSON(http.StatusCreated, UserResponse{
 		ID:        user.ID,
@@ -200,6 +202,12 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 		return
 	}
 
+	before, err := h.service.GetUserByID(uint(id))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	user, err := h.service.UpdateUser(uint(id), service.UpdateUserInput{
 		Email:  req.Email,
 		Role:   req.Role,
@@ -209,6 +217,7 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	recordAudit(h.audit, c, "user.update", "user", c.Param("id"), userToResponse(before), userToResponse(user))
 
 	c.JSON(http.StatusOK, UserResponse{
 		ID:        user.ID,
@@ -236,10 +245,17 @@ func (h *UserHandler) DeleteUser(c *gin.Context) {
 		return
 	}
 
+	before, err := h.service.GetUserByID(uint(id))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	if err := h.service.DeleteUser(uint(id)); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	recordAudit(h.audit, c, "user.delete", "user", c.Param("id"), userToResponse(before), nil)
 
 	c.Status(http.StatusNoContent)
 }
@@ -271,6 +287,8 @@ func (h *UserHandler) ResetPassword(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	// The password itself is never recorded
+	recordAudit(h.audit, c, "user.reset_password", "user", c.Param("id"), nil, nil)
 
 	c.JSON(http.StatusOK, gin.H{"message": "password reset successfully"})
 }
@@ -0,0 +1,283 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/your-org/app-market/internal/model"
+	"github.com/your-org/app-market/internal/service"
+)
+
+type WebhookHandler struct {
+	service *service.WebhookService
+	audit   *service.AuditService
+}
+
+func NewWebhookHandler(s *service.WebhookService, audit *service.AuditService) *WebhookHandler {
+	return &WebhookHandler{service: s, audit: audit}
+}
+
+type CreateWebhookRequest struct {
+	Name    string   `json:"name" binding:"required" example:"deploy-bot"`
+	URL     string   `json:"url" binding:"required" example:"https://bots.example.com/app-market"`
+	Events  []string `json:"events" binding:"required" example:"task.completed,task.failed"` // task.completed, task.failed, instance.deleted, chart.published, repo.sync_failed or "*"
+	Secret  string   `json:"secret"`                                                         // HMAC signing secret, generated if empty
+	Enabled *bool    `json:"enabled" example:"true"`
+}
+
+type UpdateWebhookRequest struct {
+	Name    string   `json:"name" example:"deploy-bot"`
+	URL     string   `json:"url" example:"https://bots.example.com/app-market"`
+	Events  []string `json:"events" example:"task.failed"` // Replaces the subscribed events if set
+	Secret  string   `json:"secret"`                       // Replaces the signing secret if set
+	Enabled *bool    `json:"enabled" example:"false"`
+}
+
+// CreateWebhookResponse returns the signing secret, which is only shown once
+type CreateWebhookResponse struct {
+	*model.Webhook
+	Secret string `json:"secret"`
+}
+
+// ListWebhooks godoc
+// @Summary      List Webhooks
+// @Description  List the registered webhook endpoints
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {array}   model.Webhook
+// @Failure      500  {object}  map[string]string
+// @Router       /admin/webhooks [get]
+func (h *WebhookHandler) ListWebhooks(c *gin.Context) {
+	webhooks, err := h.service.ListWebhooks()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list webhooks"})
+		return
+	}
+	c.JSON(http.StatusOK, webhooks)
+}
+
+// CreateWebhook godoc
+// @Summary      Register Webhook
+// @Description  Register an endpoint for lifecycle events. Each delivery is a JSON POST signed with HMAC-SHA256 of the body in the X-AppMarket-Signature header ("sha256=<hex>"). The secret is returned only in this response.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request  body  CreateWebhookRequest  true  "Webhook"
+// @Success      201  {object}  CreateWebhookResponse
+// @Failure      400  {object}  map[string]string
+// @Router       /admin/webhooks [post]
+func (h *WebhookHandler) CreateWebhook(c *gin.Context) {
+	var req CreateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	webhook, secret, err := h.service.CreateWebhook(service.WebhookInput{
+		Name:    req.Name,
+		URL:     req.URL,
+		Events:  req.Events,
+		Secret:  req.Secret,
+		Enabled: req.Enabled,
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	recordAudit(h.audit, c, "webhook.create", "webhook", fmt.Sprintf("%d", webhook.ID), nil, webhook)
+
+	c.JSON(http.StatusCreated, CreateWebhookResponse{Webhook: webhook, Secret: secret})
+}
+
+// GetWebhook godoc
+// @Summary      Get Webhook
+// @Description  Get a registered webhook
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path  int  true  "Webhook ID"
+// @Success      200  {object}  model.Webhook
+// @Failure      404  {object}  map[string]string
+// @Router       /admin/webhooks/{id} [get]
+func (h *WebhookHandler) GetWebhook(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	webhook, err := h.service.GetWebhook(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, webhook)
+}
+
+// UpdateWebhook godoc
+// @Summary      Update Webhook
+// @Description  Update a webhook. Omitted fields keep their value.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id       path  int                   true  "Webhook ID"
+// @Param        request  body  UpdateWebhookRequest  true  "Changes"
+// @Success      200  {object}  model.Webhook
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /admin/webhooks/{id} [put]
+func (h *WebhookHandler) UpdateWebhook(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	var req UpdateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	before, err := h.service.GetWebhook(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	webhook, err := h.service.UpdateWebhook(uint(id), service.WebhookInput{
+		Name:    req.Name,
+		URL:     req.URL,
+		Events:  req.Events,
+		Secret:  req.Secret,
+		Enabled: req.Enabled,
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// The secret itself is never recorded, only that it was replaced
+	action := "webhook.update"
+	if req.Secret != "" {
+		action = "webhook.secret.update"
+	}
+	recordAudit(h.audit, c, action, "webhook", c.Param("id"), before, webhook)
+
+	c.JSON(http.StatusOK, webhook)
+}
+
+// DeleteWebhook godoc
+// @Summary      Delete Webhook
+// @Description  Remove a webhook. Its pending deliveries are not sent.
+// @Tags         admin
+// @Security     BearerAuth
+// @Param        id   path  int  true  "Webhook ID"
+// @Success      204
+// @Failure      404  {object}  map[string]string
+// @Router       /admin/webhooks/{id} [delete]
+func (h *WebhookHandler) DeleteWebhook(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	before, err := h.service.GetWebhook(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.service.DeleteWebhook(uint(id)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	recordAudit(h.audit, c, "webhook.delete", "webhook", c.Param("id"), before, nil)
+
+	c.Status(http.StatusNoContent)
+}
+
+// TestWebhook godoc
+// @Summary      Test Webhook
+// @Description  Queue a ping event to a webhook, regardless of its event filter
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path  int  true  "Webhook ID"
+// @Success      202  {object}  model.WebhookDelivery
+// @Failure      404  {object}  map[string]string
+// @Router       /admin/webhooks/{id}/test [post]
+func (h *WebhookHandler) TestWebhook(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	delivery, err := h.service.TestWebhook(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusAccepted, delivery)
+}
+
+// ListDeliveries godoc
+// @Summary      List Webhook Deliveries
+// @Description  List the deliveries of a webhook with their attempts and last response, newest first
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id      path   int     true   "Webhook ID"
+// @Param        status  query  string  false  "pending, delivered or failed"
+// @Param        page    query  int     false  "Page number"
+// @Param        limit   query  int     false  "Page size"
+// @Success      200  {object}  service.DeliveryListOutput
+// @Failure      500  {object}  map[string]string
+// @Router       /admin/webhooks/{id}/deliveries [get]
+func (h *WebhookHandler) ListDeliveries(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+	result, err := h.service.ListDeliveries(uint(id), c.Query("status"), page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// Redeliver godoc
+// @Summary      Redeliver Webhook Event
+// @Description  Queue a new delivery with the payload of an earlier one
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path  int  true  "Delivery ID"
+// @Success      202  {object}  model.WebhookDelivery
+// @Failure      404  {object}  map[string]string
+// @Router       /admin/webhook-deliveries/{id}/redeliver [post]
+func (h *WebhookHandler) Redeliver(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	delivery, err := h.service.Redeliver(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusAccepted, delivery)
+}
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"context"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -9,6 +10,7 @@ import (
 	"github.com/your-org/app-market/internal/config"
 	"github.com/your-org/app-market/internal/repository"
 	"github.com/your-org/app-market/internal/service"
+	"github.com/your-org/app-market/pkg/crypto"
 )
 
 func NewRouter(cfg *config.Config) (*gin.Engine, error) {
@@ -18,17 +20,48 @@ func NewRouter(cfg *config.Config) (*gin.Engine, error) {
 		return nil, err
 	}
 
-	chartService := service.NewChartService(db)
-	deployService := service.NewDeployService(db, chartService)
-	syncService := service.NewSyncService(db)
-	taskService := service.NewTaskService(db, deployService)
+	cipher, err := crypto.NewCipher(cfg.Security.EncryptionKey)
+	if err != nil {
+		return nil, err
+	}
+
+	webhookService := service.NewWebhookService(db, cipher, cfg.Webhook)
+	chartService := service.NewChartService(db, webhookService)
+	clusterService := service.NewClusterService(db, cipher)
+	tenancyService := service.NewTenancyService(db, cfg.Tenancy)
+	quotaService := service.NewQuotaService(db, cfg.Quota)
+	repoClient := service.NewRepoClient(cipher, cfg.Repo)
+	deployService := service.NewDeployService(db, chartService, clusterService, tenancyService, quotaService, repoClient)
+	syncService := service.NewSyncService(db, cipher, repoClient, webhookService, cfg.Reconcile)
+	approvalService := service.NewApprovalService(db)
+	taskService := service.NewTaskService(db, deployService, approvalService, webhookService, cfg.Task)
+	driftService := service.NewDriftService(db, deployService, taskService, cfg.Reconcile)
+	if cfg.Reconcile.StatusInterval > 0 {
+		go deployService.StartStatusReconciler(context.Background(), cfg.Reconcile.StatusInterval)
+	}
+	if cfg.Reconcile.DriftInterval > 0 {
+		go driftService.StartReconciler(context.Background(), cfg.Reconcile.DriftInterval)
+	}
+	if cfg.Reconcile.RepoSyncInterval > 0 {
+		go syncService.StartScheduler(context.Background(), cfg.Reconcile.RepoSyncInterval)
+	}
+	go webhookService.StartDispatcher(context.Background())
 	userService := service.NewUserService(db)
+	auditService := service.NewAuditService(db, cipher)
 
-	chartHandler := handler.NewChartHandler(chartService)
-	deployHandler := handler.NewDeployHandler(deployService, taskService)
-	repoHandler := handler.NewRepoHandler(syncService)
+	chartHandler := handler.NewChartHandler(chartService, auditService)
+	deployHandler := handler.NewDeployHandler(deployService, taskService, auditService)
+	repoHandler := handler.NewRepoHandler(syncService, auditService)
 	authHandler := handler.NewAuthHandler(db)
-	userHandler := handler.NewUserHandler(userService)
+	userHandler := handler.NewUserHandler(userService, auditService)
+	taskHandler := handler.NewTaskHandler(taskService, auditService)
+	driftHandler := handler.NewDriftHandler(driftService, auditService)
+	clusterHandler := handler.NewClusterHandler(clusterService, auditService)
+	tenancyHandler := handler.NewTenancyHandler(tenancyService, auditService)
+	quotaHandler := handler.NewQuotaHandler(quotaService, auditService)
+	approvalHandler := handler.NewApprovalHandler(approvalService, taskService, auditService)
+	auditHandler := handler.NewAuditHandler(auditService)
+	webhookHandler := handler.NewWebhookHandler(webhookService, auditService)
 
 	// 2. Setup Router
 	if cfg.Server.Mode == "release" {
@@ -79,8 +112,58 @@ func NewRouter(cfg *config.Config) (*gin.Engine, error) {
 
 		admin.GET("/repos", repoHandler.ListRepos)
 		admin.POST("/repos", repoHandler.AddRepo)
+		admin.PUT("/repos/:id", repoHandler.UpdateRepo)
 		admin.POST("/repos/:id/sync", repoHandler.SyncRepo)
 
+		admin.GET("/tasks", taskHandler.AdminListTasks)
+		admin.GET("/tasks/pool", taskHandler.PoolStats)
+
+		admin.GET("/clusters", clusterHandler.ListClusters)
+		admin.POST("/clusters", clusterHandler.CreateCluster)
+		admin.GET("/clusters/:id", clusterHandler.GetCluster)
+		admin.PUT("/clusters/:id", clusterHandler.UpdateCluster)
+		admin.DELETE("/clusters/:id", clusterHandler.DeleteCluster)
+		admin.POST("/clusters/:id/test", clusterHandler.TestCluster)
+
+		admin.GET("/groups", tenancyHandler.ListGroups)
+		admin.POST("/groups", tenancyHandler.CreateGroup)
+		admin.DELETE("/groups/:id", tenancyHandler.DeleteGroup)
+		admin.POST("/groups/:id/members", tenancyHandler.AddGroupMember)
+		admin.DELETE("/groups/:id/members/:user_id", tenancyHandler.RemoveGroupMember)
+		admin.GET("/namespace-grants", tenancyHandler.ListGrants)
+		admin.POST("/namespace-grants", tenancyHandler.CreateGrant)
+		admin.DELETE("/namespace-grants/:id", tenancyHandler.DeleteGrant)
+
+		admin.GET("/quotas", quotaHandler.ListQuotas)
+		admin.PUT("/quotas", quotaHandler.SetQuota)
+		admin.DELETE("/quotas/:id", quotaHandler.DeleteQuota)
+		admin.GET("/quotas/usage", quotaHandler.SubjectUsage)
+
+		admin.GET("/approval-policies", approvalHandler.ListPolicies)
+		admin.POST("/approval-policies", approvalHandler.CreatePolicy)
+		admin.PUT("/approval-policies/:id", approvalHandler.UpdatePolicy)
+		admin.DELETE("/approval-policies/:id", approvalHandler.DeletePolicy)
+
+		admin.POST("/instances/adopt", deployHandler.AdoptInstance)
+
+		admin.GET("/audit", auditHandler.ListAudit)
+		admin.GET("/audit/verify", auditHandler.VerifyAudit)
+
+		admin.GET("/webhooks", webhookHandler.ListWebhooks)
+		admin.POST("/webhooks", webhookHandler.CreateWebhook)
+		admin.GET("/webhooks/:id", webhookHandler.GetWebhook)
+		admin.PUT("/webhooks/:id", webhookHandler.UpdateWebhook)
+		admin.DELETE("/webhooks/:id", webhookHandler.DeleteWebhook)
+		admin.POST("/webhooks/:id/test", webhookHandler.TestWebhook)
+		admin.GET("/webhooks/:id/deliveries", webhookHandler.ListDeliveries)
+		admin.POST("/webhook-deliveries/:id/redeliver", webhookHandler.Redeliver)
+
+		admin.GET("/drift", driftHandler.ListDrift)
+		admin.POST("/drift/scan", driftHandler.ScanDrift)
+		admin.POST("/drift/:id/adopt", driftHandler.AdoptDrift)
+		admin.POST("/drift/:id/resync", driftHandler.ResyncDrift)
+		admin.POST("/drift/:id/forget", driftHandler.ForgetDrift)
+
 		// Chart Upload & Onboarding
 		admin.POST("/charts/upload", chartHandler.UploadChart)
 		admin.POST("/charts/parse", chartHandler.ParseChart)
@@ -92,10 +175,25 @@ func NewRouter(cfg *config.Config) (*gin.Engine, error) {
 	api.Use(middleware.AuthMiddleware())
 	{
 		api.GET("/charts", chartHandler.ListPublishedCharts)
+		api.GET("/charts/:id/versions/:version/form", chartHandler.GetDeployForm)
 		api.POST("/deploy", deployHandler.Deploy)
+		api.POST("/deploy/preview", deployHandler.PreviewDeploy)
+		api.GET("/namespaces", tenancyHandler.MyNamespaces)
+		api.GET("/quotas/usage", quotaHandler.MyUsage)
 		api.GET("/instances", deployHandler.ListInstances)
+		api.GET("/instances/:id", deployHandler.GetInstance)
+		api.PUT("/instances/:id", deployHandler.UpgradeInstance)
 		api.DELETE("/instances/:id", deployHandler.DeleteInstance)
+		api.GET("/instances/:id/revisions", deployHandler.ListRevisions)
+		api.POST("/instances/:id/diff", deployHandler.DiffInstance)
+		api.POST("/instances/:id/rollback", deployHandler.RollbackInstance)
+		api.GET("/approvals", approvalHandler.ListApprovals)
+		api.POST("/approvals/:id/approve", approvalHandler.Approve)
+		api.POST("/approvals/:id/reject", approvalHandler.Reject)
+		api.GET("/tasks", taskHandler.ListTasks)
 		api.GET("/tasks/:id", deployHandler.GetTaskStatus)
+		api.POST("/tasks/:id/cancel", taskHandler.CancelTask)
+		api.GET("/tasks/:id/events", taskHandler.Events)
 	}
 
 	// SPA Fallback: Serve index.html for all non-API routes (supports direct browser access to SPA routes)
@@ -1,18 +1,29 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"maps"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
 
 type Config struct {
-	Server   ServerConfig   `mapstructure:"server"`
-	Log      LogConfig      `mapstructure:"log"`
-	Database DatabaseConfig `mapstructure:"database"`
-	Helm     HelmConfig     `mapstructure:"helm"`
-	Chart    ChartConfig    `mapstructure:"chart"`
+	Server    ServerConfig    `mapstructure:"server"`
+	Log       LogConfig       `mapstructure:"log"`
+	Database  DatabaseConfig  `mapstructure:"database"`
+	Helm      HelmConfig      `mapstructure:"helm"`
+	Chart     ChartConfig     `mapstructure:"chart"`
+	Task      TaskConfig      `mapstructure:"task"`
+	Reconcile ReconcileConfig `mapstructure:"reconcile"`
+	Security  SecurityConfig  `mapstructure:"security"`
+	Tenancy   TenancyConfig   `mapstructure:"tenancy"`
+	Quota     QuotaConfig     `mapstructure:"quota"`
+	Webhook   WebhookConfig   `mapstructure:"webhook"`
+	Repo      RepoConfig      `mapstructure:"repo"`
 }
 
 type ServerConfig struct {
@@ -38,6 +49,106 @@ type ChartConfig struct {
 	MaxUploadSize int64  `mapstructure:"max_upload_size"`
 }
 
+type TaskConfig struct {
+	Workers       int           `mapstructure:"workers"`        // Number of concurrent task workers
+	PollInterval  time.Duration `mapstructure:"poll_interval"`  // How often idle workers check the tasks table
+	LeaseDuration time.Duration `mapstructure:"lease_duration"` // How long a claimed task stays leased without a heartbeat
+
+	// Per task type settings, keyed by type ("deploy", "upgrade", ...) with
+	// "default" as the fallback
+	Timeouts map[string]time.Duration `mapstructure:"timeouts"`
+	Retry    map[string]RetryPolicy   `mapstructure:"retry"`
+}
+
+type SecurityConfig struct {
+	EncryptionKey string `mapstructure:"encryption_key"` // Secret used to encrypt credentials (kubeconfigs, repo and webhook secrets) at rest, required
+}
+
+type TenancyConfig struct {
+	// Namespaces nobody may deploy into, admins included. A trailing "*"
+	// matches a prefix, e.g. "kube-*".
+	DeniedNamespaces []string `mapstructure:"denied_namespaces"`
+}
+
+// QuotaConfig holds the limits for users and namespaces without a quota of
+// their own
+type QuotaConfig struct {
+	DefaultUser      QuotaLimits `mapstructure:"default_user"`
+	DefaultNamespace QuotaLimits `mapstructure:"default_namespace"`
+}
+
+type QuotaLimits struct {
+	MaxInstances int    `mapstructure:"max_instances"` // 0 is unlimited
+	CPU          string `mapstructure:"cpu"`           // e.g. "4", empty is unlimited
+	Memory       string `mapstructure:"memory"`        // e.g. "8Gi", empty is unlimited
+}
+
+type ReconcileConfig struct {
+	StatusInterval time.Duration `mapstructure:"status_interval"` // How often instance health is refreshed, 0 disables it
+	DriftInterval  time.Duration `mapstructure:"drift_interval"`  // How often releases are compared with instances, 0 disables it
+
+	// How often repos due for a scheduled sync are looked up, 0 disables
+	// scheduled syncs. Each repo is synced at its own interval plus a random
+	// delay of up to RepoSyncJitter times that interval.
+	RepoSyncInterval time.Duration `mapstructure:"repo_sync_interval"`
+	RepoSyncJitter   float64       `mapstructure:"repo_sync_jitter"`
+
+	// Namespaces scanned for unmanaged releases in addition to those holding instances
+	ManagedNamespaces []string `mapstructure:"managed_namespaces"`
+}
+
+type RepoConfig struct {
+	Timeout               time.Duration `mapstructure:"timeout"`                 // Limit for a whole index fetch or chart download
+	DialTimeout           time.Duration `mapstructure:"dial_timeout"`            // Limit for connecting, including the TLS handshake
+	ResponseHeaderTimeout time.Duration `mapstructure:"response_header_timeout"` // Limit for the server to start responding
+}
+
+type WebhookConfig struct {
+	PollInterval time.Duration `mapstructure:"poll_interval"` // How often due deliveries are sent
+	Timeout      time.Duration `mapstructure:"timeout"`       // Timeout of a single delivery attempt
+	Retry        RetryPolicy   `mapstructure:"retry"`
+}
+
+type RetryPolicy struct {
+	MaxAttempts    int           `mapstructure:"max_attempts"`    // Total attempts including the first one
+	InitialBackoff time.Duration `mapstructure:"initial_backoff"` // Delay before the first retry, doubled on each further retry
+	MaxBackoff     time.Duration `mapstructure:"max_backoff"`
+}
+
+// TimeoutFor returns the execution timeout for a task type
+func (c TaskConfig) TimeoutFor(taskType string) time.Duration {
+	if timeout, ok := c.Timeouts[taskType]; ok {
+		return timeout
+	}
+	if timeout, ok := c.Timeouts["default"]; ok {
+		return timeout
+	}
+	return 10 * time.Minute
+}
+
+// RetryFor returns the retry policy for a task type
+func (c TaskConfig) RetryFor(taskType string) RetryPolicy {
+	if policy, ok := c.Retry[taskType]; ok {
+		return policy
+	}
+	if policy, ok := c.Retry["default"]; ok {
+		return policy
+	}
+	return RetryPolicy{MaxAttempts: 1}
+}
+
+// Backoff returns the delay before the retry following the given attempt
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	backoff := p.InitialBackoff
+	for i := 1; i < attempt && (p.MaxBackoff == 0 || backoff < p.MaxBackoff); i++ {
+		backoff *= 2
+	}
+	if p.MaxBackoff > 0 && backoff > p.MaxBackoff {
+		backoff = p.MaxBackoff
+	}
+	return backoff
+}
+
 // Load reads configuration from config file and environment variables
 func Load() (*Config, error) {
 	viper.SetConfigName("config")
@@ -63,10 +174,62 @@ func Load() (*Config, error) {
 	if err := viper.Unmarshal(&cfg); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
 	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
 
 	return &cfg, nil
 }
 
+// Validate rejects settings the server cannot safely run with, such as a
+// missing encryption key, zero poll intervals or an empty worker pool
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.Security.EncryptionKey != "", "security.encryption_key is required, set it with APP_SECURITY_ENCRYPTION_KEY")
+
+	check(c.Task.Workers >= 1, "task.workers must be at least 1")
+	check(c.Task.PollInterval > 0, "task.poll_interval must be positive")
+	check(c.Task.LeaseDuration >= time.Second, "task.lease_duration must be at least 1s")
+	for _, taskType := range slices.Sorted(maps.Keys(c.Task.Timeouts)) {
+		check(c.Task.Timeouts[taskType] > 0, "task.timeouts.%s must be positive", taskType)
+	}
+	for _, taskType := range slices.Sorted(maps.Keys(c.Task.Retry)) {
+		errs = append(errs, c.Task.Retry[taskType].validate("task.retry."+taskType)...)
+	}
+
+	check(c.Reconcile.StatusInterval >= 0, "reconcile.status_interval must not be negative")
+	check(c.Reconcile.DriftInterval >= 0, "reconcile.drift_interval must not be negative")
+	check(c.Reconcile.RepoSyncInterval >= 0, "reconcile.repo_sync_interval must not be negative")
+	check(c.Reconcile.RepoSyncJitter >= 0, "reconcile.repo_sync_jitter must not be negative")
+
+	check(c.Repo.Timeout >= 0, "repo.timeout must not be negative")
+	check(c.Repo.DialTimeout >= 0, "repo.dial_timeout must not be negative")
+	check(c.Repo.ResponseHeaderTimeout >= 0, "repo.response_header_timeout must not be negative")
+
+	check(c.Webhook.PollInterval > 0, "webhook.poll_interval must be positive")
+	check(c.Webhook.Timeout > 0, "webhook.timeout must be positive")
+	errs = append(errs, c.Webhook.Retry.validate("webhook.retry")...)
+
+	return errors.Join(errs...)
+}
+
+func (p RetryPolicy) validate(prefix string) []error {
+	var errs []error
+	if p.MaxAttempts < 1 {
+		errs = append(errs, fmt.Errorf("%s.max_attempts must be at least 1", prefix))
+	}
+	if p.InitialBackoff < 0 || p.MaxBackoff < 0 {
+		errs = append(errs, fmt.Errorf("%s backoffs must not be negative", prefix))
+	}
+	return errs
+}
+
 func setDefaults() {
 	viper.SetDefault("server.port", "8080")
 	viper.SetDefault("server.mode", "debug")
@@ -74,4 +237,25 @@ func setDefaults() {
 	viper.SetDefault("database.driver", "sqlite")
 	viper.SetDefault("database.dsn", "app-market.db")
 	viper.SetDefault("helm.repo_url", "https://charts.bitnami.com/bitnami")
+	viper.SetDefault("task.workers", 4)
+	viper.SetDefault("task.poll_interval", "2s")
+	viper.SetDefault("task.lease_duration", "30s")
+	viper.SetDefault("task.timeouts", map[string]string{"default": "10m"})
+	viper.SetDefault("task.retry", map[string]interface{}{
+		"default": map[string]interface{}{"max_attempts": 3, "initial_backoff": "10s", "max_backoff": "5m"},
+	})
+	viper.SetDefault("reconcile.status_interval", "1m")
+	viper.SetDefault("reconcile.drift_interval", "5m")
+	viper.SetDefault("reconcile.repo_sync_interval", "1m")
+	viper.SetDefault("reconcile.repo_sync_jitter", 0.1)
+	viper.SetDefault("tenancy.denied_namespaces", []string{"kube-system", "kube-public", "kube-node-lease"})
+	viper.SetDefault("quota.default_user.max_instances", 20)
+	viper.SetDefault("repo.timeout", "5m")
+	viper.SetDefault("repo.dial_timeout", "10s")
+	viper.SetDefault("repo.response_header_timeout", "30s")
+	viper.SetDefault("webhook.poll_interval", "5s")
+	viper.SetDefault("webhook.timeout", "10s")
+	viper.SetDefault("webhook.retry.max_attempts", 5)
+	viper.SetDefault("webhook.retry.initial_backoff", "30s")
+	viper.SetDefault("webhook.retry.max_backoff", "30m")
 }
@@ -0,0 +1,25 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 10, InitialBackoff: time.Second, MaxBackoff: 10 * time.Second}
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second}
+	for i, w := range want {
+		if got := policy.Backoff(i + 1); got != w {
+			t.Errorf("Backoff(%d) = %v, want %v", i+1, got, w)
+		}
+	}
+	// Doubling stops at the cap instead of overflowing
+	if got := policy.Backoff(1000); got != policy.MaxBackoff {
+		t.Errorf("Backoff(1000) = %v, want %v", got, policy.MaxBackoff)
+	}
+
+	uncapped := RetryPolicy{InitialBackoff: time.Second}
+	if got := uncapped.Backoff(5); got != 16*time.Second {
+		t.Errorf("uncapped Backoff(5) = %v, want 16s", got)
+	}
+}
@@ -2,12 +2,17 @@ package helm
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
+	"time"
 
 	"helm.sh/helm/v3/pkg/action"
 	"helm.sh/helm/v3/pkg/chart/loader"
 	"helm.sh/helm/v3/pkg/cli"
+	"helm.sh/helm/v3/pkg/kube"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage/driver"
 )
 
 type Client struct {
@@ -38,34 +43,247 @@ func NewClient(namespace string) (*Client, error) {
 	}, nil
 }
 
+// SetLogger replaces the log function of Helm actions and the Kubernetes
+// client, which defaults to stdout.
+func (c *Client) SetLogger(log func(format string, v ...interface{})) {
+	c.cfg.Log = log
+	if kc, ok := c.cfg.KubeClient.(*kube.Client); ok {
+		kc.Log = log
+	}
+}
+
+// InstallOptions controls how Install and Upgrade wait for the release
+type InstallOptions struct {
+	// Wait blocks until all resources are ready (pods running, services
+	// having endpoints) instead of returning once manifests are submitted
+	Wait bool
+	// Atomic rolls back (or uninstalls a new release) when the release does
+	// not become ready in time. Implies Wait.
+	Atomic bool
+	// Timeout bounds the wait; it defaults to the remaining time of ctx, or
+	// DefaultWaitTimeout if ctx has no deadline
+	Timeout time.Duration
+	// Replace lets Install re-use the name of a release that was uninstalled
+	// with its history kept. Ignored by Upgrade.
+	Replace bool
+}
+
+// DefaultWaitTimeout is used when waiting without a timeout or ctx deadline
+const DefaultWaitTimeout = 5 * time.Minute
+
+func (o InstallOptions) timeout(ctx context.Context) time.Duration {
+	if o.Timeout > 0 {
+		return o.Timeout
+	}
+	return contextTimeout(ctx)
+}
+
+// contextTimeout returns the time left until the deadline of ctx, or
+// DefaultWaitTimeout if it has none
+func contextTimeout(ctx context.Context) time.Duration {
+	if deadline, ok := ctx.Deadline(); ok {
+		return time.Until(deadline)
+	}
+	return DefaultWaitTimeout
+}
+
 // InstallChart installs a chart from a local path or remote URL (simplified to local path for now).
-func (c *Client) InstallChart(ctx context.Context, releaseName, chartPath string, values map[string]interface{}) error {
+// It returns the revision number of the new release.
+func (c *Client) InstallChart(ctx context.Context, releaseName, chartPath string, values map[string]interface{}, opts InstallOptions) (int, error) {
 	install := action.NewInstall(c.cfg)
 	install.ReleaseName = releaseName
 	install.Namespace = c.settings.Namespace()
 	install.CreateNamespace = true
+	install.Wait = opts.Wait || opts.Atomic
+	install.Atomic = opts.Atomic
+	install.Timeout = opts.timeout(ctx)
+	install.Replace = opts.Replace
 
 	// Load the chart
 	chartRequested, err := loader.Load(chartPath)
 	if err != nil {
-		return fmt.Errorf("failed to load chart: %w", err)
+		return 0, fmt.Errorf("failed to load chart: %w", err)
 	}
 
 	// Execute installation
-	_, err = install.Run(chartRequested, values)
+	rel, err := install.RunWithContext(ctx, chartRequested, values)
 	if err != nil {
-		return fmt.Errorf("helm install failed: %w", err)
+		return 0, fmt.Errorf("helm install failed: %w", err)
 	}
 
-	return nil
+	return rel.Version, nil
 }
 
-// UninstallRelease removes a release.
-func (c *Client) UninstallRelease(releaseName string) error {
-	uninstall := action.NewUninstall(c.cfg)
-	_, err := uninstall.Run(releaseName)
+// UpgradeRelease upgrades an existing release to the given chart and values.
+// The values are applied as-is (no reuse of the previous release values), so
+// callers are expected to pass the fully merged configuration.
+// It returns the revision number of the upgraded release.
+func (c *Client) UpgradeRelease(ctx context.Context, releaseName, chartPath string, values map[string]interface{}, opts InstallOptions) (int, error) {
+	upgrade := action.NewUpgrade(c.cfg)
+	upgrade.Namespace = c.settings.Namespace()
+	upgrade.Wait = opts.Wait || opts.Atomic
+	upgrade.Atomic = opts.Atomic
+	upgrade.Timeout = opts.timeout(ctx)
+
+	chartRequested, err := loader.Load(chartPath)
 	if err != nil {
+		return 0, fmt.Errorf("failed to load chart: %w", err)
+	}
+
+	rel, err := upgrade.RunWithContext(ctx, releaseName, chartRequested, values)
+	if err != nil {
+		return 0, fmt.Errorf("helm upgrade failed: %w", err)
+	}
+
+	return rel.Version, nil
+}
+
+// Rollback rolls a release back to the given revision and returns the
+// revision number Helm created for the rollback.
+// Helm's rollback takes no context, so the deadline of ctx is passed on as
+// its hook timeout and a cancelled ctx only prevents the rollback from
+// starting; once started it runs to completion before Rollback returns.
+func (c *Client) Rollback(ctx context.Context, releaseName string, revision int) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	rollback := action.NewRollback(c.cfg)
+	rollback.Version = revision
+	rollback.Timeout = contextTimeout(ctx)
+
+	if err := rollback.Run(releaseName); err != nil {
+		return 0, fmt.Errorf("helm rollback failed: %w", err)
+	}
+
+	rel, err := action.NewGet(c.cfg).Run(releaseName)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get release after rollback: %w", err)
+	}
+
+	return rel.Version, nil
+}
+
+// UninstallRelease removes a release. With keepHistory the release records
+// are kept (status "uninstalled") so it can still be inspected or rolled back.
+// A release that no longer exists is not an error.
+// Like Rollback, ctx bounds the hooks and only prevents a cancelled uninstall
+// from starting.
+func (c *Client) UninstallRelease(ctx context.Context, releaseName string, keepHistory bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	uninstall := action.NewUninstall(c.cfg)
+	uninstall.KeepHistory = keepHistory
+	uninstall.IgnoreNotFound = true
+	uninstall.Timeout = contextTimeout(ctx)
+
+	if _, err := uninstall.Run(releaseName); err != nil {
 		return fmt.Errorf("helm uninstall failed: %w", err)
 	}
 	return nil
 }
+
+// ReleaseState returns the status of the latest revision of a release
+// ("deployed", "uninstalled", ...), or "" if the release does not exist.
+func (c *Client) ReleaseState(releaseName string) (string, error) {
+	rel, err := action.NewGet(c.cfg).Run(releaseName)
+	if err != nil {
+		if errors.Is(err, driver.ErrReleaseNotFound) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to get release: %w", err)
+	}
+	if rel.Info == nil {
+		return "unknown", nil
+	}
+	return rel.Info.Status.String(), nil
+}
+
+// GetManifest returns the rendered manifest of the current release revision.
+func (c *Client) GetManifest(releaseName string) (string, error) {
+	rel, err := action.NewGet(c.cfg).Run(releaseName)
+	if err != nil {
+		return "", fmt.Errorf("failed to get release: %w", err)
+	}
+	return rel.Manifest, nil
+}
+
+// GetRevisionManifest returns the rendered manifest of a release revision.
+func (c *Client) GetRevisionManifest(releaseName string, revision int) (string, error) {
+	get := action.NewGet(c.cfg)
+	get.Version = revision
+	rel, err := get.Run(releaseName)
+	if err != nil {
+		return "", fmt.Errorf("failed to get release revision %d: %w", revision, err)
+	}
+	return rel.Manifest, nil
+}
+
+// GetManifests returns the manifests of the current release revision
+// including its hooks, keyed by template path like RenderResult.Manifests.
+func (c *Client) GetManifests(releaseName string) (map[string]string, error) {
+	rel, err := action.NewGet(c.cfg).Run(releaseName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get release: %w", err)
+	}
+	return releaseManifests(rel), nil
+}
+
+// ReleaseSummary describes the latest revision of a release
+type ReleaseSummary struct {
+	Name         string                 `json:"name"`
+	Namespace    string                 `json:"namespace"`
+	Revision     int                    `json:"revision"`
+	Status       string                 `json:"status"`
+	Chart        string                 `json:"chart"`
+	ChartVersion string                 `json:"chart_version"`
+	AppVersion   string                 `json:"app_version"`
+	Values       map[string]interface{} `json:"-"` // user-supplied values of the revision
+}
+
+// ListReleases returns the releases of the client namespace that are not
+// uninstalled, i.e. deployed, failed or in the middle of an operation.
+func (c *Client) ListReleases() ([]ReleaseSummary, error) {
+	list := action.NewList(c.cfg)
+	list.StateMask = action.ListDeployed | action.ListFailed | action.ListPendingInstall |
+		action.ListPendingUpgrade | action.ListPendingRollback | action.ListUninstalling
+
+	releases, err := list.Run()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list releases: %w", err)
+	}
+
+	summaries := make([]ReleaseSummary, 0, len(releases))
+	for _, rel := range releases {
+		summaries = append(summaries, summarize(rel))
+	}
+	return summaries, nil
+}
+
+// GetRelease returns the latest revision of a release
+func (c *Client) GetRelease(releaseName string) (*ReleaseSummary, error) {
+	rel, err := action.NewGet(c.cfg).Run(releaseName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get release: %w", err)
+	}
+	summary := summarize(rel)
+	return &summary, nil
+}
+
+func summarize(rel *release.Release) ReleaseSummary {
+	summary := ReleaseSummary{
+		Name:      rel.Name,
+		Namespace: rel.Namespace,
+		Revision:  rel.Version,
+		Values:    rel.Config,
+	}
+	if rel.Info != nil {
+		summary.Status = rel.Info.Status.String()
+	}
+	if rel.Chart != nil && rel.Chart.Metadata != nil {
+		summary.Chart = rel.Chart.Metadata.Name
+		summary.ChartVersion = rel.Chart.Metadata.Version
+		summary.AppVersion = rel.Chart.Metadata.AppVersion
+	}
+	return summary
+}
@@ -0,0 +1,194 @@
+package helm
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/cli"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// ClusterSource describes how to reach a registered cluster
+type ClusterSource struct {
+	InCluster  bool   // Use the service account of the pod the market runs in
+	Local      bool   // Use the kubeconfig of the Helm environment, as NewClient does
+	Kubeconfig []byte // Kubeconfig content, used unless InCluster or Local is set
+	Context    string // Kubeconfig context, empty for the current context
+}
+
+// restConfig builds the REST config of the cluster
+func (s ClusterSource) restConfig() (*rest.Config, error) {
+	if s.InCluster {
+		return rest.InClusterConfig()
+	}
+	if s.Local {
+		return cli.New().RESTClientGetter().ToRESTConfig()
+	}
+
+	config, err := clientcmd.Load(s.Kubeconfig)
+	if err != nil {
+		return nil, fmt.Errorf("invalid kubeconfig: %w", err)
+	}
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: s.Context}
+	return clientcmd.NewDefaultClientConfig(*config, overrides).ClientConfig()
+}
+
+// Validate checks that the kubeconfig can be loaded and has the context
+func (s ClusterSource) Validate() error {
+	if s.InCluster || s.Local {
+		return nil
+	}
+	_, err := s.restConfig()
+	return err
+}
+
+// clusterClients are shared by all Helm clients of one cluster so discovery
+// and REST mapping are only fetched once
+type clusterClients struct {
+	version    time.Time // Version of the cluster record the clients were built from
+	restConfig *rest.Config
+	discovery  discovery.CachedDiscoveryInterface
+	mapper     meta.RESTMapper
+}
+
+func newClusterClients(src ClusterSource, version time.Time) (*clusterClients, error) {
+	config, err := src.restConfig()
+	if err != nil {
+		return nil, err
+	}
+	// Helm issues bursts of discovery and apply requests
+	config.QPS = 50
+	config.Burst = 100
+
+	dc, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create discovery client: %w", err)
+	}
+	cached := memory.NewMemCacheClient(dc)
+
+	return &clusterClients{
+		version:    version,
+		restConfig: config,
+		discovery:  cached,
+		mapper:     restmapper.NewShortcutExpander(restmapper.NewDeferredDiscoveryRESTMapper(cached), cached),
+	}, nil
+}
+
+// restClientGetter implements genericclioptions.RESTClientGetter on top of
+// the shared clients of a cluster, scoped to one namespace
+type restClientGetter struct {
+	clients   *clusterClients
+	namespace string
+}
+
+func (g *restClientGetter) ToRESTConfig() (*rest.Config, error) {
+	return rest.CopyConfig(g.clients.restConfig), nil
+}
+
+func (g *restClientGetter) ToDiscoveryClient() (discovery.CachedDiscoveryInterface, error) {
+	return g.clients.discovery, nil
+}
+
+func (g *restClientGetter) ToRESTMapper() (meta.RESTMapper, error) {
+	return g.clients.mapper, nil
+}
+
+func (g *restClientGetter) ToRawKubeConfigLoader() clientcmd.ClientConfig {
+	// Only the namespace is read from it; the REST config is served above
+	return clientcmd.NewDefaultClientConfig(clientcmdapi.Config{}, &clientcmd.ConfigOverrides{
+		Context: clientcmdapi.Context{Namespace: g.namespace},
+	})
+}
+
+// Factory creates Helm clients for registered clusters, caching the REST
+// config, discovery and REST mapper of every cluster. Cluster 0 is the
+// cluster of the process' default kubeconfig and is cached like the others.
+type Factory struct {
+	mu       sync.Mutex
+	clusters map[uint]*clusterClients
+}
+
+func NewFactory() *Factory {
+	return &Factory{clusters: make(map[uint]*clusterClients)}
+}
+
+// Client returns a Helm client for a namespace of a cluster. version
+// identifies the cluster's connection settings, e.g. the update time of its
+// record; load is only called the first time a cluster is used or when its
+// version changed, so every replica picks up changed kubeconfigs.
+func (f *Factory) Client(clusterID uint, version time.Time, load func() (ClusterSource, error), namespace string) (*Client, error) {
+	clients, err := f.clusterClients(clusterID, version, load)
+	if err != nil {
+		return nil, err
+	}
+
+	settings := cli.New()
+	settings.SetNamespace(namespace)
+
+	getter := &restClientGetter{clients: clients, namespace: namespace}
+	cfg := new(action.Configuration)
+	if err := cfg.Init(getter, namespace, os.Getenv("HELM_DRIVER"), func(format string, v ...interface{}) {
+		fmt.Printf(format+"\n", v...)
+	}); err != nil {
+		return nil, fmt.Errorf("failed to init helm config: %w", err)
+	}
+
+	return &Client{
+		settings: settings,
+		cfg:      cfg,
+	}, nil
+}
+
+func (f *Factory) clusterClients(clusterID uint, version time.Time, load func() (ClusterSource, error)) (*clusterClients, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if clients, ok := f.clusters[clusterID]; ok && clients.version.Equal(version) {
+		return clients, nil
+	}
+
+	src, err := load()
+	if err != nil {
+		return nil, err
+	}
+	clients, err := newClusterClients(src, version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to cluster %d: %w", clusterID, err)
+	}
+	f.clusters[clusterID] = clients
+	return clients, nil
+}
+
+// Invalidate drops the cached clients of a cluster that was removed. Changed
+// clusters are reloaded through their version instead.
+func (f *Factory) Invalidate(clusterID uint) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.clusters, clusterID)
+}
+
+// ServerVersion connects to a cluster and returns its Kubernetes version
+func ServerVersion(src ClusterSource) (string, error) {
+	config, err := src.restConfig()
+	if err != nil {
+		return "", err
+	}
+	dc, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		return "", err
+	}
+	info, err := dc.ServerVersion()
+	if err != nil {
+		return "", err
+	}
+	return info.GitVersion, nil
+}
@@ -0,0 +1,51 @@
+package helm
+
+import (
+	"testing"
+	"time"
+)
+
+const testKubeconfig = `apiVersion: v1
+kind: Config
+clusters:
+  - name: test
+    cluster:
+      server: https://127.0.0.1:6443
+contexts:
+  - name: test
+    context:
+      cluster: test
+      user: test
+current-context: test
+users:
+  - name: test
+    user:
+      token: secret
+`
+
+func TestFactoryReloadsChangedClusters(t *testing.T) {
+	factory := NewFactory()
+	loads := 0
+	load := func() (ClusterSource, error) {
+		loads++
+		return ClusterSource{Kubeconfig: []byte(testKubeconfig)}, nil
+	}
+
+	v1 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, namespace := range []string{"team-a", "team-b"} {
+		if _, err := factory.Client(1, v1, load, namespace); err != nil {
+			t.Fatalf("Client: %v", err)
+		}
+	}
+	if loads != 1 {
+		t.Fatalf("cluster loaded %d times for one version, want 1", loads)
+	}
+
+	// Another replica updated the cluster
+	if _, err := factory.Client(1, v1.Add(time.Second), load, "team-a"); err != nil {
+		t.Fatalf("Client: %v", err)
+	}
+	if loads != 2 {
+		t.Errorf("cluster loaded %d times after its version changed, want 2", loads)
+	}
+}
@@ -0,0 +1,216 @@
+package helm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"helm.sh/helm/v3/pkg/action"
+)
+
+// Endpoint is an address under which a release can be reached from outside the cluster
+type Endpoint struct {
+	Type     string `json:"type"` // NodePort, LoadBalancer, Ingress
+	Name     string `json:"name"` // Service or Ingress name
+	Host     string `json:"host,omitempty"`
+	Port     int32  `json:"port,omitempty"`
+	Protocol string `json:"protocol,omitempty"`
+	URL      string `json:"url,omitempty"`
+}
+
+// ReleaseDetail is the live state of a Helm release
+type ReleaseDetail struct {
+	Revision     int              `json:"revision"`
+	Status       string           `json:"status"` // Helm release status, e.g. deployed, failed, pending-upgrade
+	LastDeployed time.Time        `json:"last_deployed"`
+	Notes        string           `json:"notes"`
+	Health       string           `json:"health"`
+	Objects      []ResourceStatus `json:"objects"`
+	Endpoints    []Endpoint       `json:"endpoints"`
+}
+
+// ReleaseDetail returns the current revision of a release together with the
+// status of every object it owns and the endpoints it exposes.
+func (c *Client) ReleaseDetail(ctx context.Context, releaseName string) (*ReleaseDetail, error) {
+	rel, err := action.NewGet(c.cfg).Run(releaseName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get release: %w", err)
+	}
+
+	detail := &ReleaseDetail{
+		Revision:  rel.Version,
+		Objects:   []ResourceStatus{},
+		Endpoints: []Endpoint{},
+	}
+	if rel.Info != nil {
+		detail.Status = rel.Info.Status.String()
+		detail.LastDeployed = rel.Info.LastDeployed.Time
+		detail.Notes = rel.Info.Notes
+	}
+
+	// Workloads, pods and services with their health
+	status, err := c.ReleaseStatus(ctx, releaseName)
+	if err != nil {
+		return nil, err
+	}
+	detail.Health = status.Health
+	detail.Objects = append(detail.Objects, status.Resources...)
+
+	// Remaining objects are only checked for existence
+	others, err := c.objectStatuses(rel.Manifest)
+	if err != nil {
+		return nil, err
+	}
+	for _, obj := range others {
+		detail.Objects = append(detail.Objects, obj)
+		detail.Health = WorstHealth(detail.Health, obj.Health)
+	}
+
+	clientset, err := c.cfg.KubernetesClientSet()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kubernetes client: %w", err)
+	}
+	detail.Endpoints = releaseEndpoints(ctx, clientset, ManifestObjects(rel.Manifest, c.settings.Namespace()))
+
+	return detail, nil
+}
+
+// statusKinds are the kinds ReleaseStatus reports on
+var statusKinds = map[string]bool{
+	"Deployment":  true,
+	"StatefulSet": true,
+	"Service":     true,
+}
+
+// objectStatuses reports whether the manifest objects not covered by
+// ReleaseStatus exist in the cluster
+func (c *Client) objectStatuses(manifest string) ([]ResourceStatus, error) {
+	resources, err := c.cfg.KubeClient.Build(strings.NewReader(manifest), false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build release objects: %w", err)
+	}
+
+	var statuses []ResourceStatus
+	for _, info := range resources {
+		kind := info.Mapping.GroupVersionKind.Kind
+		if statusKinds[kind] {
+			continue
+		}
+
+		rs := ResourceStatus{
+			Kind:      kind,
+			Name:      info.Name,
+			Namespace: info.Namespace,
+			Health:    HealthHealthy,
+		}
+		if err := info.Get(); err != nil {
+			rs.Health = HealthFailed
+			rs.Message = err.Error()
+		}
+		statuses = append(statuses, rs)
+	}
+	return statuses, nil
+}
+
+// releaseEndpoints discovers NodePorts, LoadBalancer addresses and Ingress
+// hosts of the release. Objects that cannot be read are skipped.
+func releaseEndpoints(ctx context.Context, clientset kubernetes.Interface, objects []ObjectRef) []Endpoint {
+	endpoints := []Endpoint{}
+	var nodeAddress *string
+
+	for _, ref := range objects {
+		switch ref.Kind {
+		case "Service":
+			svc, err := clientset.CoreV1().Services(ref.Namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+			if err != nil {
+				continue
+			}
+
+			switch svc.Spec.Type {
+			case corev1.ServiceTypeNodePort:
+				if nodeAddress == nil {
+					addr := firstNodeAddress(ctx, clientset)
+					nodeAddress = &addr
+				}
+				for _, port := range svc.Spec.Ports {
+					endpoints = append(endpoints, newEndpoint("NodePort", svc.Name, *nodeAddress, port.NodePort, string(port.Protocol)))
+				}
+			case corev1.ServiceTypeLoadBalancer:
+				for _, ingress := range svc.Status.LoadBalancer.Ingress {
+					host := ingress.IP
+					if host == "" {
+						host = ingress.Hostname
+					}
+					for _, port := range svc.Spec.Ports {
+						endpoints = append(endpoints, newEndpoint("LoadBalancer", svc.Name, host, port.Port, string(port.Protocol)))
+					}
+				}
+			}
+		case "Ingress":
+			ing, err := clientset.NetworkingV1().Ingresses(ref.Namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+			if err != nil {
+				continue
+			}
+
+			tlsHosts := make(map[string]bool)
+			for _, tls := range ing.Spec.TLS {
+				for _, host := range tls.Hosts {
+					tlsHosts[host] = true
+				}
+			}
+			for _, rule := range ing.Spec.Rules {
+				if rule.Host == "" {
+					continue
+				}
+				scheme := "http"
+				if tlsHosts[rule.Host] {
+					scheme = "https"
+				}
+				path := "/"
+				if rule.HTTP != nil && len(rule.HTTP.Paths) > 0 && rule.HTTP.Paths[0].Path != "" {
+					path = rule.HTTP.Paths[0].Path
+				}
+				endpoints = append(endpoints, Endpoint{
+					Type: "Ingress",
+					Name: ing.Name,
+					Host: rule.Host,
+					URL:  scheme + "://" + rule.Host + path,
+				})
+			}
+		}
+	}
+	return endpoints
+}
+
+func newEndpoint(endpointType, name, host string, port int32, protocol string) Endpoint {
+	ep := Endpoint{Type: endpointType, Name: name, Host: host, Port: port, Protocol: protocol}
+	if host != "" && protocol == string(corev1.ProtocolTCP) {
+		ep.URL = fmt.Sprintf("http://%s:%d", host, port)
+	}
+	return ep
+}
+
+// firstNodeAddress returns an address NodePorts can be reached at, preferring
+// external addresses. It is empty if nodes cannot be listed.
+func firstNodeAddress(ctx context.Context, clientset kubernetes.Interface) string {
+	nodes, err := clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{Limit: 1})
+	if err != nil || len(nodes.Items) == 0 {
+		return ""
+	}
+
+	var internal string
+	for _, addr := range nodes.Items[0].Status.Addresses {
+		switch addr.Type {
+		case corev1.NodeExternalIP:
+			return addr.Address
+		case corev1.NodeInternalIP:
+			internal = addr.Address
+		}
+	}
+	return internal
+}
@@ -0,0 +1,200 @@
+package helm
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// ValueChange is a single dotted path that differs between two value sets
+type ValueChange struct {
+	Path string      `json:"path"`
+	Old  interface{} `json:"old,omitempty"`
+	New  interface{} `json:"new,omitempty"`
+}
+
+// ValuesDiff groups value changes by kind
+type ValuesDiff struct {
+	Added   []ValueChange `json:"added"`
+	Removed []ValueChange `json:"removed"`
+	Changed []ValueChange `json:"changed"`
+}
+
+// Empty reports whether the two value sets were identical
+func (d ValuesDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// DiffValues compares two nested value maps leaf by leaf using the dotted
+// paths produced by FlattenValues.
+func DiffValues(current, proposed map[string]interface{}) ValuesDiff {
+	oldFlat := FlattenValues(current)
+	newFlat := FlattenValues(proposed)
+
+	diff := ValuesDiff{
+		Added:   []ValueChange{},
+		Removed: []ValueChange{},
+		Changed: []ValueChange{},
+	}
+	for path, newValue := range newFlat {
+		oldValue, ok := oldFlat[path]
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, ValueChange{Path: path, New: newValue})
+		case !reflect.DeepEqual(oldValue, newValue):
+			diff.Changed = append(diff.Changed, ValueChange{Path: path, Old: oldValue, New: newValue})
+		}
+	}
+	for path, oldValue := range oldFlat {
+		if _, ok := newFlat[path]; !ok {
+			diff.Removed = append(diff.Removed, ValueChange{Path: path, Old: oldValue})
+		}
+	}
+
+	for _, changes := range [][]ValueChange{diff.Added, diff.Removed, diff.Changed} {
+		sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	}
+	return diff
+}
+
+// DiffManifests produces a unified diff between two sets of manifests keyed
+// by template path (see SplitManifests). Unchanged templates are omitted.
+func DiffManifests(current, proposed map[string]string) string {
+	sources := make(map[string]bool)
+	for source := range current {
+		sources[source] = true
+	}
+	for source := range proposed {
+		sources[source] = true
+	}
+
+	names := make([]string, 0, len(sources))
+	for source := range sources {
+		names = append(names, source)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, source := range names {
+		b.WriteString(UnifiedDiff("a/"+source, "b/"+source, current[source], proposed[source]))
+	}
+	return b.String()
+}
+
+const diffContext = 3
+
+// UnifiedDiff returns a unified diff of two texts, or "" when they are equal
+func UnifiedDiff(fromName, toName, from, to string) string {
+	if from == to {
+		return ""
+	}
+	a, b := splitLines(from), splitLines(to)
+	ops := diffLines(a, b)
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", fromName, toName)
+
+	for start := 0; start < len(ops); {
+		// Skip to the next change
+		for start < len(ops) && ops[start].kind == ' ' {
+			start++
+		}
+		if start == len(ops) {
+			break
+		}
+
+		// Extend the hunk while changes are within 2*context lines of each other
+		hunkStart := max(start-diffContext, 0)
+		end := start
+		for i := start; i < len(ops); i++ {
+			if ops[i].kind != ' ' {
+				end = i
+			} else if i-end > 2*diffContext {
+				break
+			}
+		}
+		hunkEnd := min(end+diffContext+1, len(ops))
+
+		hunk := ops[hunkStart:hunkEnd]
+		aStart, bStart := hunk[0].aLine, hunk[0].bLine
+		aCount, bCount := 0, 0
+		for _, op := range hunk {
+			if op.kind != '+' {
+				aCount++
+			}
+			if op.kind != '-' {
+				bCount++
+			}
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(aStart, aCount), hunkRange(bStart, bCount))
+		for _, op := range hunk {
+			out.WriteByte(op.kind)
+			out.WriteString(op.text)
+			out.WriteByte('\n')
+		}
+
+		start = hunkEnd
+	}
+	return out.String()
+}
+
+type diffOp struct {
+	kind  byte // ' ', '-' or '+'
+	text  string
+	aLine int // 1-based line in a where this op applies
+	bLine int // 1-based line in b where this op applies
+}
+
+// diffLines computes a line edit script based on the longest common subsequence
+func diffLines(a, b []string) []diffOp {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	ops := make([]diffOp, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			ops = append(ops, diffOp{kind: ' ', text: a[i], aLine: i + 1, bLine: j + 1})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			ops = append(ops, diffOp{kind: '-', text: a[i], aLine: i + 1, bLine: j + 1})
+			i++
+		default:
+			ops = append(ops, diffOp{kind: '+', text: b[j], aLine: i + 1, bLine: j + 1})
+			j++
+		}
+	}
+	return ops
+}
+
+func hunkRange(start, count int) string {
+	if count == 0 {
+		// An empty range refers to the line before the insertion/deletion point
+		return fmt.Sprintf("%d,0", start-1)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
@@ -0,0 +1,62 @@
+package helm
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffValues(t *testing.T) {
+	current := map[string]interface{}{
+		"image":    map[string]interface{}{"repository": "nginx", "tag": "1.0"},
+		"replicas": 2,
+		"debug":    true,
+	}
+	proposed := map[string]interface{}{
+		"image":    map[string]interface{}{"repository": "nginx", "tag": "1.1"},
+		"replicas": 2,
+		"service":  map[string]interface{}{"type": "ClusterIP", "port": 80},
+	}
+
+	diff := DiffValues(current, proposed)
+	want := ValuesDiff{
+		Added:   []ValueChange{{Path: "service.port", New: 80}, {Path: "service.type", New: "ClusterIP"}},
+		Removed: []ValueChange{{Path: "debug", Old: true}},
+		Changed: []ValueChange{{Path: "image.tag", Old: "1.0", New: "1.1"}},
+	}
+	if !reflect.DeepEqual(diff, want) {
+		t.Errorf("DiffValues() = %+v, want %+v", diff, want)
+	}
+
+	if diff := DiffValues(current, current); !diff.Empty() {
+		t.Errorf("DiffValues of identical values = %+v, want empty", diff)
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	if got := UnifiedDiff("a", "b", "same\n", "same\n"); got != "" {
+		t.Errorf("UnifiedDiff of equal texts = %q, want empty", got)
+	}
+
+	from := "apiVersion: v1\nkind: Service\nmetadata:\n  name: web\nspec:\n  type: ClusterIP\n  ports:\n    - port: 80\n"
+	to := "apiVersion: v1\nkind: Service\nmetadata:\n  name: web\nspec:\n  type: NodePort\n  ports:\n    - port: 80\n"
+	want := `--- a/service.yaml
++++ b/service.yaml
+@@ -3,6 +3,6 @@
+ metadata:
+   name: web
+ spec:
+-  type: ClusterIP
++  type: NodePort
+   ports:
+     - port: 80
+`
+	if got := UnifiedDiff("a/service.yaml", "b/service.yaml", from, to); got != want {
+		t.Errorf("UnifiedDiff() =\n%s\nwant\n%s", got, want)
+	}
+
+	// A new template is diffed against nothing
+	want = "--- a/cm.yaml\n+++ b/cm.yaml\n@@ -0,0 +1,2 @@\n+kind: ConfigMap\n+data: {}\n"
+	if got := UnifiedDiff("a/cm.yaml", "b/cm.yaml", "", "kind: ConfigMap\ndata: {}\n"); got != want {
+		t.Errorf("UnifiedDiff() of a new file =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,262 @@
+package helm
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Form field types understood by the frontend
+const (
+	FieldString = "string"
+	FieldNumber = "number"
+	FieldBool   = "bool"
+	FieldEnum   = "enum"
+	FieldSecret = "secret"
+	FieldList   = "list"
+)
+
+// FormField describes one input of a generated deploy form
+type FormField struct {
+	Path     string        `json:"path"`
+	Type     string        `json:"type"`
+	Label    string        `json:"label"`
+	Help     string        `json:"help,omitempty"`
+	Group    string        `json:"group,omitempty"`
+	Default  interface{}   `json:"default,omitempty"`
+	Enum     []interface{} `json:"enum,omitempty"`
+	Items    string        `json:"items,omitempty"` // element type for list fields
+	Required bool          `json:"required"`
+	Fixed    bool          `json:"fixed"` // read-only, set by the administrator
+	Minimum  *float64      `json:"minimum,omitempty"`
+	Maximum  *float64      `json:"maximum,omitempty"`
+	Pattern  string        `json:"pattern,omitempty"`
+
+	order int
+}
+
+// FormInput gathers everything that shapes a chart's deploy form
+type FormInput struct {
+	Defaults     map[string]interface{} // chart defaults merged with admin defaults
+	Schema       []byte                 // values.schema.json, may be empty
+	Constraints  map[string]interface{} // admin JSON Schema fragments per path
+	Hints        map[string]interface{} // admin labels/help/group/order/type per path
+	VisibleKeys  []string
+	RequiredKeys []string
+	FixedKeys    []string
+}
+
+// BuildForm combines defaults, schema, admin constraints and hints into an
+// ordered list of typed form fields. When VisibleKeys is set only those keys
+// (and their children) are included, otherwise every known leaf is.
+func BuildForm(in FormInput) ([]FormField, error) {
+	var schema map[string]interface{}
+	if len(in.Schema) > 0 {
+		if err := json.Unmarshal(in.Schema, &schema); err != nil {
+			return nil, fmt.Errorf("invalid values schema: %w", err)
+		}
+	}
+
+	// Collect candidate paths from defaults, schema leaves and hinted keys
+	paths := make(map[string]bool)
+	for path := range FlattenValues(in.Defaults) {
+		paths[path] = true
+	}
+	schemaLeaves(schema, "", paths)
+	for path := range in.Hints {
+		paths[path] = true
+	}
+	for _, path := range in.RequiredKeys {
+		paths[path] = true
+	}
+
+	defaults := FlattenValues(in.Defaults)
+	var fields []FormField
+	for path := range paths {
+		if len(in.VisibleKeys) > 0 && !matchesAny(path, in.VisibleKeys, false) {
+			continue
+		}
+
+		field := FormField{
+			Path:    path,
+			Label:   path,
+			Default: defaults[path],
+			Fixed:   matchesAny(path, in.FixedKeys, true),
+			order:   len(in.VisibleKeys),
+		}
+		for i, key := range in.VisibleKeys {
+			if matchesAny(path, []string{key}, false) {
+				field.order = i
+				break
+			}
+		}
+		for _, key := range in.RequiredKeys {
+			if key == path {
+				field.Required = true
+			}
+		}
+
+		prop, required := schemaProperty(schema, path)
+		field.Required = field.Required || required
+		applyProperty(&field, prop)
+		if constraint, ok := in.Constraints[path].(map[string]interface{}); ok {
+			applyProperty(&field, constraint)
+		}
+		applyHints(&field, in.Hints[path])
+
+		if field.Type == "" {
+			field.Type = inferType(path, field.Default)
+		}
+		fields = append(fields, field)
+	}
+
+	sort.Slice(fields, func(i, j int) bool {
+		a, b := fields[i], fields[j]
+		if a.Group != b.Group {
+			return a.Group < b.Group
+		}
+		if a.order != b.order {
+			return a.order < b.order
+		}
+		return a.Path < b.Path
+	})
+	return fields, nil
+}
+
+// schemaLeaves adds every non-object property path of a JSON schema to paths
+func schemaLeaves(schema map[string]interface{}, prefix string, paths map[string]bool) {
+	props, _ := schema["properties"].(map[string]interface{})
+	for name, raw := range props {
+		prop, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		path := name
+		if prefix != "" {
+			path = prefix + "." + name
+		}
+		if _, nested := prop["properties"]; nested {
+			schemaLeaves(prop, path, paths)
+		} else {
+			paths[path] = true
+		}
+	}
+}
+
+// schemaProperty walks the schema to the property for path and reports
+// whether its parent object lists it as required
+func schemaProperty(schema map[string]interface{}, path string) (map[string]interface{}, bool) {
+	node := schema
+	parts := strings.Split(path, ".")
+	for i, part := range parts {
+		props, _ := node["properties"].(map[string]interface{})
+		child, ok := props[part].(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if i == len(parts)-1 {
+			required, _ := node["required"].([]interface{})
+			for _, r := range required {
+				if r == part {
+					return child, true
+				}
+			}
+			return child, false
+		}
+		node = child
+	}
+	return nil, false
+}
+
+func applyProperty(field *FormField, prop map[string]interface{}) {
+	if prop == nil {
+		return
+	}
+	if title, ok := prop["title"].(string); ok {
+		field.Label = title
+	}
+	if desc, ok := prop["description"].(string); ok {
+		field.Help = desc
+	}
+	if field.Default == nil {
+		field.Default = prop["default"]
+	}
+	if enum, ok := prop["enum"].([]interface{}); ok {
+		field.Enum = enum
+		field.Type = FieldEnum
+	}
+	if min, ok := prop["minimum"].(float64); ok {
+		field.Minimum = &min
+	}
+	if max, ok := prop["maximum"].(float64); ok {
+		field.Maximum = &max
+	}
+	if pattern, ok := prop["pattern"].(string); ok {
+		field.Pattern = pattern
+	}
+	if field.Type == FieldEnum {
+		return
+	}
+
+	switch prop["type"] {
+	case "string":
+		field.Type = FieldString
+		if prop["format"] == "password" {
+			field.Type = FieldSecret
+		}
+	case "integer", "number":
+		field.Type = FieldNumber
+	case "boolean":
+		field.Type = FieldBool
+	case "array":
+		field.Type = FieldList
+		if items, ok := prop["items"].(map[string]interface{}); ok {
+			if t, ok := items["type"].(string); ok {
+				field.Items = t
+			}
+		}
+	}
+}
+
+func applyHints(field *FormField, raw interface{}) {
+	hints, ok := raw.(map[string]interface{})
+	if !ok {
+		return
+	}
+	if label, ok := hints["label"].(string); ok {
+		field.Label = label
+	}
+	if help, ok := hints["help"].(string); ok {
+		field.Help = help
+	}
+	if group, ok := hints["group"].(string); ok {
+		field.Group = group
+	}
+	if order, ok := hints["order"].(float64); ok {
+		field.order = int(order)
+	}
+	if t, ok := hints["type"].(string); ok {
+		field.Type = t
+	}
+}
+
+// inferType guesses a field type from its default value and name
+func inferType(path string, value interface{}) string {
+	switch value.(type) {
+	case bool:
+		return FieldBool
+	case int, int64, float64:
+		return FieldNumber
+	case []interface{}:
+		return FieldList
+	}
+
+	name := strings.ToLower(path[strings.LastIndex(path, ".")+1:])
+	for _, hint := range []string{"password", "secret", "token"} {
+		if strings.Contains(name, hint) {
+			return FieldSecret
+		}
+	}
+	return FieldString
+}
@@ -0,0 +1,78 @@
+package helm
+
+import (
+	"reflect"
+	"testing"
+)
+
+func formPaths(fields []FormField) []string {
+	paths := make([]string, len(fields))
+	for i, f := range fields {
+		paths[i] = f.Path
+	}
+	return paths
+}
+
+func TestBuildForm(t *testing.T) {
+	fields, err := BuildForm(FormInput{
+		Defaults: map[string]interface{}{
+			"replicas": 2,
+			"debug":    false,
+			"image":    map[string]interface{}{"tag": "1.0"},
+			"auth":     map[string]interface{}{"password": ""},
+		},
+		Schema: []byte(`{"properties": {"service": {"required": ["type"], "properties": {
+			"type": {"type": "string", "enum": ["ClusterIP", "NodePort"], "default": "ClusterIP", "title": "Service type"}
+		}}}}`),
+		Constraints:  map[string]interface{}{"replicas": map[string]interface{}{"minimum": 1.0, "maximum": 5.0}},
+		Hints:        map[string]interface{}{"image.tag": map[string]interface{}{"label": "Image tag", "group": "image"}},
+		RequiredKeys: []string{"ingress.host"},
+		FixedKeys:    []string{"image"},
+	})
+	if err != nil {
+		t.Fatalf("BuildForm: %v", err)
+	}
+
+	// Ungrouped fields come first, each group sorted by path
+	want := []string{"auth.password", "debug", "ingress.host", "replicas", "service.type", "image.tag"}
+	if got := formPaths(fields); !reflect.DeepEqual(got, want) {
+		t.Fatalf("BuildForm paths = %v, want %v", got, want)
+	}
+	byPath := make(map[string]FormField)
+	for _, f := range fields {
+		byPath[f.Path] = f
+	}
+
+	if f := byPath["auth.password"]; f.Type != FieldSecret {
+		t.Errorf("auth.password type = %s, want %s from its name", f.Type, FieldSecret)
+	}
+	if f := byPath["debug"]; f.Type != FieldBool || f.Default != false {
+		t.Errorf("debug = %+v, want a bool defaulting to false", f)
+	}
+	if f := byPath["ingress.host"]; !f.Required || f.Type != FieldString {
+		t.Errorf("ingress.host = %+v, want a required string", f)
+	}
+	if f := byPath["replicas"]; f.Type != FieldNumber || f.Minimum == nil || *f.Minimum != 1 || f.Maximum == nil || *f.Maximum != 5 {
+		t.Errorf("replicas = %+v, want a number between 1 and 5", f)
+	}
+	if f := byPath["service.type"]; f.Type != FieldEnum || !f.Required || f.Label != "Service type" || f.Default != "ClusterIP" || len(f.Enum) != 2 {
+		t.Errorf("service.type = %+v, want the required enum from the schema", f)
+	}
+	if f := byPath["image.tag"]; !f.Fixed || f.Label != "Image tag" || f.Group != "image" {
+		t.Errorf("image.tag = %+v, want a fixed field labelled by its hint", f)
+	}
+}
+
+func TestBuildFormFollowsVisibleKeys(t *testing.T) {
+	fields, err := BuildForm(FormInput{
+		Defaults:    map[string]interface{}{"replicas": 2, "debug": false, "service": map[string]interface{}{"port": 80, "type": "ClusterIP"}},
+		VisibleKeys: []string{"service", "replicas"},
+	})
+	if err != nil {
+		t.Fatalf("BuildForm: %v", err)
+	}
+	// Only visible keys, in the order the admin listed them
+	if got, want := formPaths(fields), []string{"service.port", "service.type", "replicas"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("BuildForm paths = %v, want %v", got, want)
+	}
+}
@@ -15,6 +15,7 @@ type ChartInfo struct {
 	Icon          string
 	Home          string
 	DefaultValues map[string]interface{} // 从 values.yaml 解析
+	ValuesSchema  string                 // values.schema.json 原文 (可能为空)
 }
 
 // ParseChartArchive 解析 .tgz 文件
@@ -34,6 +35,7 @@ func ParseChartArchive(tgzPath string) (*ChartInfo, error) {
 		Icon:          chart.Metadata.Icon,
 		Home:          chart.Metadata.Home,
 		DefaultValues: chart.Values, // Helm SDK 已解析为 map
+		ValuesSchema:  string(chart.Schema),
 	}, nil
 }
 
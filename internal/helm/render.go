@@ -0,0 +1,84 @@
+package helm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+// RenderResult is the output of a client-only dry run
+type RenderResult struct {
+	Manifests map[string]string `json:"manifests"` // rendered YAML keyed by template path
+	Notes     string            `json:"notes"`     // rendered NOTES.txt
+	Manifest  string            `json:"-"`         // release manifest without hooks
+}
+
+// RenderChart renders a chart locally, the same way `helm template` does,
+// without contacting the cluster or storing a release.
+func RenderChart(ctx context.Context, releaseName, namespace, chartPath string, values map[string]interface{}) (*RenderResult, error) {
+	cfg := &action.Configuration{Log: func(string, ...interface{}) {}}
+
+	install := action.NewInstall(cfg)
+	install.ReleaseName = releaseName
+	install.Namespace = namespace
+	install.DryRun = true
+	install.ClientOnly = true
+	install.Replace = true
+
+	chartRequested, err := loader.Load(chartPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load chart: %w", err)
+	}
+
+	rel, err := install.RunWithContext(ctx, chartRequested, values)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render chart: %w", err)
+	}
+
+	return &RenderResult{
+		Manifests: releaseManifests(rel),
+		Notes:     rel.Info.Notes,
+		Manifest:  rel.Manifest,
+	}, nil
+}
+
+// releaseManifests returns the manifests of a release including its hooks,
+// keyed by template path
+func releaseManifests(rel *release.Release) map[string]string {
+	manifests := SplitManifests(rel.Manifest)
+	for _, hook := range rel.Hooks {
+		manifests[hook.Path] = appendManifest(manifests[hook.Path], hook.Manifest)
+	}
+	return manifests
+}
+
+// SplitManifests splits a release manifest into documents keyed by the
+// template they came from ("# Source: <path>" header). Templates producing
+// several documents are joined back with "---".
+func SplitManifests(manifest string) map[string]string {
+	result := make(map[string]string)
+	for _, doc := range strings.Split(manifest, "\n---") {
+		doc = strings.TrimSpace(strings.TrimPrefix(doc, "---"))
+		if doc == "" {
+			continue
+		}
+
+		source := "unknown"
+		if first, _, _ := strings.Cut(doc, "\n"); strings.HasPrefix(first, "# Source: ") {
+			source = strings.TrimPrefix(first, "# Source: ")
+		}
+		result[source] = appendManifest(result[source], doc)
+	}
+	return result
+}
+
+func appendManifest(existing, doc string) string {
+	if existing == "" {
+		return doc
+	}
+	return existing + "\n---\n" + doc
+}
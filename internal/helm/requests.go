@@ -0,0 +1,105 @@
+package helm
+
+import (
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// ResourceRequests is the CPU and memory a release requests from the cluster
+type ResourceRequests struct {
+	CPUMillis   int64 `json:"cpu_millis"`
+	MemoryBytes int64 `json:"memory_bytes"`
+}
+
+// ManifestRequests sums the resource requests of the pods a manifest creates,
+// multiplied by their replica count. DaemonSets count as one replica since
+// the number of nodes is unknown. Documents of unknown kinds are skipped.
+func ManifestRequests(manifest string) ResourceRequests {
+	var total ResourceRequests
+	decoder := scheme.Codecs.UniversalDeserializer()
+
+	for _, doc := range strings.Split(manifest, "\n---") {
+		doc = strings.TrimSpace(strings.TrimPrefix(doc, "---"))
+		if doc == "" {
+			continue
+		}
+		obj, _, err := decoder.Decode([]byte(doc), nil, nil)
+		if err != nil {
+			continue
+		}
+
+		var spec *corev1.PodSpec
+		replicas := int32(1)
+		switch o := obj.(type) {
+		case *appsv1.Deployment:
+			spec, replicas = &o.Spec.Template.Spec, replicasOrOne(o.Spec.Replicas)
+		case *appsv1.StatefulSet:
+			spec, replicas = &o.Spec.Template.Spec, replicasOrOne(o.Spec.Replicas)
+		case *appsv1.ReplicaSet:
+			spec, replicas = &o.Spec.Template.Spec, replicasOrOne(o.Spec.Replicas)
+		case *appsv1.DaemonSet:
+			spec = &o.Spec.Template.Spec
+		case *batchv1.Job:
+			spec, replicas = &o.Spec.Template.Spec, replicasOrOne(o.Spec.Parallelism)
+		case *batchv1.CronJob:
+			spec, replicas = &o.Spec.JobTemplate.Spec.Template.Spec, replicasOrOne(o.Spec.JobTemplate.Spec.Parallelism)
+		case *corev1.Pod:
+			spec = &o.Spec
+		default:
+			continue
+		}
+
+		pod := podRequests(spec)
+		total.CPUMillis += pod.CPUMillis * int64(replicas)
+		total.MemoryBytes += pod.MemoryBytes * int64(replicas)
+	}
+	return total
+}
+
+func replicasOrOne(replicas *int32) int32 {
+	if replicas == nil {
+		return 1
+	}
+	return *replicas
+}
+
+// podRequests returns what the scheduler reserves for a pod: the sum of its
+// containers, or the largest init container if that is more
+func podRequests(spec *corev1.PodSpec) ResourceRequests {
+	var sum, initMax ResourceRequests
+	for _, c := range spec.Containers {
+		r := containerRequests(c)
+		sum.CPUMillis += r.CPUMillis
+		sum.MemoryBytes += r.MemoryBytes
+	}
+	for _, c := range spec.InitContainers {
+		r := containerRequests(c)
+		initMax.CPUMillis = max(initMax.CPUMillis, r.CPUMillis)
+		initMax.MemoryBytes = max(initMax.MemoryBytes, r.MemoryBytes)
+	}
+	return ResourceRequests{
+		CPUMillis:   max(sum.CPUMillis, initMax.CPUMillis),
+		MemoryBytes: max(sum.MemoryBytes, initMax.MemoryBytes),
+	}
+}
+
+// containerRequests falls back to the limits, as Kubernetes defaults
+// requests to limits when only limits are set
+func containerRequests(c corev1.Container) ResourceRequests {
+	var r ResourceRequests
+	if q, ok := c.Resources.Requests[corev1.ResourceCPU]; ok {
+		r.CPUMillis = q.MilliValue()
+	} else if q, ok := c.Resources.Limits[corev1.ResourceCPU]; ok {
+		r.CPUMillis = q.MilliValue()
+	}
+	if q, ok := c.Resources.Requests[corev1.ResourceMemory]; ok {
+		r.MemoryBytes = q.Value()
+	} else if q, ok := c.Resources.Limits[corev1.ResourceMemory]; ok {
+		r.MemoryBytes = q.Value()
+	}
+	return r
+}
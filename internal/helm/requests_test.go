@@ -0,0 +1,78 @@
+package helm
+
+import "testing"
+
+func TestManifestRequests(t *testing.T) {
+	const mi = 1024 * 1024
+
+	// 3 replicas of 100m/64Mi, plus a sidecar counted by its limits
+	deployment := `---
+# Source: web/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 3
+  template:
+    spec:
+      containers:
+        - name: app
+          resources:
+            requests:
+              cpu: 100m
+              memory: 64Mi
+        - name: sidecar
+          resources:
+            limits:
+              cpu: 50m
+              memory: 32Mi
+`
+	// One pod whose init container needs more CPU than its containers
+	daemonSet := `---
+apiVersion: apps/v1
+kind: DaemonSet
+metadata:
+  name: agent
+spec:
+  template:
+    spec:
+      initContainers:
+        - name: setup
+          resources:
+            requests:
+              cpu: "1"
+              memory: 16Mi
+      containers:
+        - name: agent
+          resources:
+            requests:
+              cpu: 200m
+              memory: 128Mi
+`
+	service := `---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+spec:
+  ports:
+    - port: 80
+`
+
+	if got, want := ManifestRequests(deployment), (ResourceRequests{CPUMillis: 450, MemoryBytes: 288 * mi}); got != want {
+		t.Errorf("deployment: got %+v, want %+v", got, want)
+	}
+	if got, want := ManifestRequests(daemonSet), (ResourceRequests{CPUMillis: 1000, MemoryBytes: 128 * mi}); got != want {
+		t.Errorf("daemonset: got %+v, want %+v", got, want)
+	}
+	if got := ManifestRequests(service); got != (ResourceRequests{}) {
+		t.Errorf("service: got %+v, want no requests", got)
+	}
+
+	// Unparsable documents are skipped, the others add up
+	all := "---\nnot: [valid\n" + deployment + service + daemonSet
+	if got, want := ManifestRequests(all), (ResourceRequests{CPUMillis: 1450, MemoryBytes: 416 * mi}); got != want {
+		t.Errorf("all documents: got %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,107 @@
+package helm
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// ValidateSchema validates values against a chart's values.schema.json and
+// returns one FieldError per violation. An error is only returned when the
+// schema itself cannot be loaded.
+func ValidateSchema(values map[string]interface{}, schema []byte) ([]FieldError, error) {
+	if len(schema) == 0 {
+		return nil, nil
+	}
+	return validate(values, gojsonschema.NewBytesLoader(schema))
+}
+
+// ValidateConstraints validates values against admin defined constraints.
+// Keys are dot-separated value paths and each constraint is a JSON Schema
+// fragment for that path, e.g. {"enum": ["ClusterIP", "NodePort"]},
+// {"minimum": 1, "maximum": 5} or {"pattern": "^[a-z]+$"}.
+// Paths missing from values are not checked.
+func ValidateConstraints(values map[string]interface{}, constraints map[string]interface{}) ([]FieldError, error) {
+	if len(constraints) == 0 {
+		return nil, nil
+	}
+	return validate(values, gojsonschema.NewGoLoader(constraintsSchema(constraints)))
+}
+
+// constraintsSchema nests the flat path -> fragment map into an object schema
+func constraintsSchema(constraints map[string]interface{}) map[string]interface{} {
+	root := map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+
+	for path, fragment := range constraints {
+		node := root
+		parts := strings.Split(path, ".")
+		for i, part := range parts {
+			props := node["properties"].(map[string]interface{})
+			if i == len(parts)-1 {
+				child, ok := props[part].(map[string]interface{})
+				if !ok {
+					child = map[string]interface{}{}
+				}
+				if f, ok := fragment.(map[string]interface{}); ok {
+					for k, v := range f {
+						child[k] = v
+					}
+				}
+				props[part] = child
+				break
+			}
+
+			child, ok := props[part].(map[string]interface{})
+			if !ok {
+				child = map[string]interface{}{}
+				props[part] = child
+			}
+			if _, ok := child["properties"]; !ok {
+				child["properties"] = map[string]interface{}{}
+			}
+			node = child
+		}
+	}
+
+	return root
+}
+
+func validate(values map[string]interface{}, schemaLoader gojsonschema.JSONLoader) ([]FieldError, error) {
+	schema, err := gojsonschema.NewSchema(schemaLoader)
+	if err != nil {
+		return nil, fmt.Errorf("invalid values schema: %w", err)
+	}
+
+	// Round-trip through JSON so YAML-decoded numbers and maps compare like JSON
+	raw, err := json.Marshal(values)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode values: %w", err)
+	}
+
+	result, err := schema.Validate(gojsonschema.NewBytesLoader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("failed to validate values: %w", err)
+	}
+
+	var fieldErrors []FieldError
+	for _, e := range result.Errors() {
+		path := e.Field()
+		if path == gojsonschema.STRING_ROOT_SCHEMA_PROPERTY {
+			path = ""
+		}
+		// "required" errors are reported on the parent object, point at the missing key instead
+		if property, ok := e.Details()["property"].(string); ok && e.Type() == "required" {
+			if path != "" {
+				path += "."
+			}
+			path += property
+		}
+		fieldErrors = append(fieldErrors, FieldError{Path: path, Message: e.Description()})
+	}
+
+	sort.SliceStable(fieldErrors, func(i, j int) bool { return fieldErrors[i].Path < fieldErrors[j].Path })
+	return fieldErrors, nil
+}
@@ -0,0 +1,35 @@
+package helm
+
+import "testing"
+
+func TestValidateConstraints(t *testing.T) {
+	constraints := map[string]interface{}{
+		"service.type": map[string]interface{}{"enum": []interface{}{"ClusterIP", "NodePort"}},
+		"replicas":     map[string]interface{}{"minimum": 1, "maximum": 5},
+		"name":         map[string]interface{}{"pattern": "^[a-z]+$"},
+	}
+	// Values come from YAML or JSON, so nested maps are untyped
+	values := map[string]interface{}{
+		"service":  map[string]interface{}{"type": "LoadBalancer"},
+		"replicas": 10,
+		"name":     "web",
+	}
+
+	errs, err := ValidateConstraints(values, constraints)
+	if err != nil {
+		t.Fatalf("ValidateConstraints: %v", err)
+	}
+	if len(errs) != 2 || errs[0].Path != "replicas" || errs[1].Path != "service.type" {
+		t.Fatalf("ValidateConstraints() = %+v, want errors for replicas and service.type", errs)
+	}
+
+	// Paths missing from the values are not required
+	if errs, err := ValidateConstraints(map[string]interface{}{"name": "web"}, constraints); err != nil || len(errs) != 0 {
+		t.Errorf("ValidateConstraints with unset paths = %+v, %v, want no errors", errs, err)
+	}
+
+	// A broken constraint is an error of its own, not a field error
+	if _, err := ValidateConstraints(values, map[string]interface{}{"name": map[string]interface{}{"pattern": "[a-"}}); err == nil {
+		t.Error("ValidateConstraints with an invalid pattern: want error")
+	}
+}
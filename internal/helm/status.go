@@ -0,0 +1,305 @@
+package helm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/yaml"
+)
+
+// Health states of a resource or a whole release, ordered from best to worst
+const (
+	HealthHealthy     = "healthy"
+	HealthProgressing = "progressing"
+	HealthDegraded    = "degraded"
+	HealthFailed      = "failed"
+)
+
+var healthRank = map[string]int{
+	HealthHealthy:     0,
+	HealthProgressing: 1,
+	HealthDegraded:    2,
+	HealthFailed:      3,
+}
+
+// WorstHealth returns the worse of two health states
+func WorstHealth(a, b string) string {
+	if healthRank[b] > healthRank[a] {
+		return b
+	}
+	return a
+}
+
+// ObjectRef identifies a Kubernetes object of a release manifest
+type ObjectRef struct {
+	APIVersion string `json:"api_version"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+	Namespace  string `json:"namespace,omitempty"`
+}
+
+// ManifestObjects lists the objects of a rendered manifest. Objects without
+// a namespace are reported in defaultNamespace.
+func ManifestObjects(manifest, defaultNamespace string) []ObjectRef {
+	var refs []ObjectRef
+	for _, doc := range strings.Split(manifest, "\n---") {
+		var obj struct {
+			APIVersion string `json:"apiVersion"`
+			Kind       string `json:"kind"`
+			Metadata   struct {
+				Name      string `json:"name"`
+				Namespace string `json:"namespace"`
+			} `json:"metadata"`
+		}
+		if err := yaml.Unmarshal([]byte(doc), &obj); err != nil || obj.Kind == "" {
+			continue
+		}
+
+		ref := ObjectRef{
+			APIVersion: obj.APIVersion,
+			Kind:       obj.Kind,
+			Name:       obj.Metadata.Name,
+			Namespace:  obj.Metadata.Namespace,
+		}
+		if ref.Namespace == "" {
+			ref.Namespace = defaultNamespace
+		}
+		refs = append(refs, ref)
+	}
+	return refs
+}
+
+// ResourceStatus summarises the state of one workload, pod or service
+type ResourceStatus struct {
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	Health    string `json:"health"`
+	Ready     string `json:"ready,omitempty"` // e.g. "2/3" for workloads
+	Message   string `json:"message,omitempty"`
+}
+
+// ReleaseStatus is the health of a release and its resources
+type ReleaseStatus struct {
+	Health    string           `json:"health"`
+	Resources []ResourceStatus `json:"resources"`
+}
+
+// ReleaseStatus inspects the Deployments, StatefulSets and Services of the
+// current release revision, plus the Pods selected by its workloads, and
+// derives an overall health from the worst resource.
+func (c *Client) ReleaseStatus(ctx context.Context, releaseName string) (*ReleaseStatus, error) {
+	manifest, err := c.GetManifest(releaseName)
+	if err != nil {
+		return nil, err
+	}
+
+	clientset, err := c.cfg.KubernetesClientSet()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kubernetes client: %w", err)
+	}
+
+	status := &ReleaseStatus{Health: HealthHealthy, Resources: []ResourceStatus{}}
+	add := func(rs ResourceStatus) {
+		status.Resources = append(status.Resources, rs)
+		status.Health = WorstHealth(status.Health, rs.Health)
+	}
+
+	for _, ref := range ManifestObjects(manifest, c.settings.Namespace()) {
+		switch ref.Kind {
+		case "Deployment":
+			deployment, err := clientset.AppsV1().Deployments(ref.Namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+			if err != nil {
+				add(missingStatus(ref, err))
+				continue
+			}
+			add(deploymentStatus(deployment))
+			pods, err := podStatuses(ctx, clientset, ref.Namespace, deployment.Spec.Selector)
+			if err != nil {
+				return nil, err
+			}
+			for _, pod := range pods {
+				add(pod)
+			}
+		case "StatefulSet":
+			statefulSet, err := clientset.AppsV1().StatefulSets(ref.Namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+			if err != nil {
+				add(missingStatus(ref, err))
+				continue
+			}
+			add(statefulSetStatus(statefulSet))
+			pods, err := podStatuses(ctx, clientset, ref.Namespace, statefulSet.Spec.Selector)
+			if err != nil {
+				return nil, err
+			}
+			for _, pod := range pods {
+				add(pod)
+			}
+		case "Service":
+			service, err := clientset.CoreV1().Services(ref.Namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+			if err != nil {
+				add(missingStatus(ref, err))
+				continue
+			}
+			add(serviceStatus(service))
+		}
+	}
+
+	return status, nil
+}
+
+func missingStatus(ref ObjectRef, err error) ResourceStatus {
+	return ResourceStatus{
+		Kind:      ref.Kind,
+		Name:      ref.Name,
+		Namespace: ref.Namespace,
+		Health:    HealthFailed,
+		Message:   err.Error(),
+	}
+}
+
+func deploymentStatus(d *appsv1.Deployment) ResourceStatus {
+	desired := int32(1)
+	if d.Spec.Replicas != nil {
+		desired = *d.Spec.Replicas
+	}
+	rs := ResourceStatus{
+		Kind:      "Deployment",
+		Name:      d.Name,
+		Namespace: d.Namespace,
+		Ready:     fmt.Sprintf("%d/%d", d.Status.ReadyReplicas, desired),
+		Health:    HealthHealthy,
+	}
+
+	for _, cond := range d.Status.Conditions {
+		if cond.Type == appsv1.DeploymentProgressing && cond.Reason == "ProgressDeadlineExceeded" {
+			rs.Health = HealthFailed
+			rs.Message = cond.Message
+			return rs
+		}
+	}
+
+	switch {
+	case d.Status.ObservedGeneration < d.Generation || d.Status.UpdatedReplicas < desired:
+		rs.Health = HealthProgressing
+		rs.Message = "rollout in progress"
+	case d.Status.AvailableReplicas < desired:
+		rs.Health = HealthDegraded
+		rs.Message = fmt.Sprintf("%d of %d replicas available", d.Status.AvailableReplicas, desired)
+	}
+	return rs
+}
+
+func statefulSetStatus(s *appsv1.StatefulSet) ResourceStatus {
+	desired := int32(1)
+	if s.Spec.Replicas != nil {
+		desired = *s.Spec.Replicas
+	}
+	rs := ResourceStatus{
+		Kind:      "StatefulSet",
+		Name:      s.Name,
+		Namespace: s.Namespace,
+		Ready:     fmt.Sprintf("%d/%d", s.Status.ReadyReplicas, desired),
+		Health:    HealthHealthy,
+	}
+
+	switch {
+	case s.Status.ObservedGeneration < s.Generation || s.Status.UpdatedReplicas < desired ||
+		(s.Status.UpdateRevision != "" && s.Status.CurrentRevision != s.Status.UpdateRevision):
+		rs.Health = HealthProgressing
+		rs.Message = "rollout in progress"
+	case s.Status.ReadyReplicas < desired:
+		rs.Health = HealthDegraded
+		rs.Message = fmt.Sprintf("%d of %d replicas ready", s.Status.ReadyReplicas, desired)
+	}
+	return rs
+}
+
+// podFailureReasons are container waiting reasons that will not resolve on their own
+var podFailureReasons = map[string]bool{
+	"CrashLoopBackOff":           true,
+	"ImagePullBackOff":           true,
+	"ErrImagePull":               true,
+	"InvalidImageName":           true,
+	"CreateContainerConfigError": true,
+	"CreateContainerError":       true,
+}
+
+func podStatuses(ctx context.Context, clientset kubernetes.Interface, namespace string, selector *metav1.LabelSelector) ([]ResourceStatus, error) {
+	if selector == nil {
+		return nil, nil
+	}
+	sel, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid selector: %w", err)
+	}
+
+	pods, err := clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: sel.String()})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %w", err)
+	}
+
+	statuses := make([]ResourceStatus, 0, len(pods.Items))
+	for i := range pods.Items {
+		statuses = append(statuses, podStatus(&pods.Items[i]))
+	}
+	return statuses, nil
+}
+
+func podStatus(pod *corev1.Pod) ResourceStatus {
+	ready := 0
+	for _, cs := range pod.Status.ContainerStatuses {
+		if cs.Ready {
+			ready++
+		}
+	}
+	rs := ResourceStatus{
+		Kind:      "Pod",
+		Name:      pod.Name,
+		Namespace: pod.Namespace,
+		Ready:     fmt.Sprintf("%d/%d", ready, len(pod.Spec.Containers)),
+		Health:    HealthHealthy,
+	}
+
+	for _, cs := range pod.Status.ContainerStatuses {
+		if cs.State.Waiting != nil && podFailureReasons[cs.State.Waiting.Reason] {
+			rs.Health = HealthDegraded
+			rs.Message = fmt.Sprintf("%s: %s", cs.Name, cs.State.Waiting.Reason)
+			return rs
+		}
+	}
+
+	switch pod.Status.Phase {
+	case corev1.PodFailed:
+		rs.Health = HealthFailed
+		rs.Message = pod.Status.Reason
+	case corev1.PodPending:
+		rs.Health = HealthProgressing
+		rs.Message = "pending"
+	case corev1.PodRunning:
+		if ready < len(pod.Spec.Containers) {
+			rs.Health = HealthProgressing
+			rs.Message = "containers not ready"
+		}
+	}
+	return rs
+}
+
+func serviceStatus(svc *corev1.Service) ResourceStatus {
+	rs := ResourceStatus{
+		Kind:      "Service",
+		Name:      svc.Name,
+		Namespace: svc.Namespace,
+		Health:    HealthHealthy,
+	}
+	if svc.Spec.Type == corev1.ServiceTypeLoadBalancer && len(svc.Status.LoadBalancer.Ingress) == 0 {
+		rs.Health = HealthProgressing
+		rs.Message = "waiting for load balancer"
+	}
+	return rs
+}
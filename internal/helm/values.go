@@ -2,6 +2,7 @@ package helm
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 
 	"dario.cat/mergo"
@@ -32,23 +33,78 @@ func MergeValues(chartDefaults, adminDefaults, userValues map[string]interface{}
 	return final, nil
 }
 
+// FieldError describes a problem with a single dot-separated values key
+type FieldError struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// ValidationError is returned when values violate the chart configuration.
+// Fields lists every offending key so the API can report them individually.
+type ValidationError struct {
+	Message string
+	Fields  []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	paths := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		paths[i] = f.Path
+	}
+	return fmt.Sprintf("%s: %s", e.Message, strings.Join(paths, ", "))
+}
+
 // ValidateRequiredKeys checks if specific keys exist in the values map.
 // Keys can be dot-separated for nested lookup (e.g., "image.repository").
 func ValidateRequiredKeys(values map[string]interface{}, requiredKeys []string) error {
-	var missing []string
+	var missing []FieldError
 
 	for _, key := range requiredKeys {
 		if !hasKey(values, key) {
-			missing = append(missing, key)
+			missing = append(missing, FieldError{Path: key, Message: "required"})
 		}
 	}
 
 	if len(missing) > 0 {
-		return fmt.Errorf("missing required keys: %s", strings.Join(missing, ", "))
+		return &ValidationError{Message: "missing required keys", Fields: missing}
 	}
 	return nil
 }
 
+// RestrictedKeys returns the user supplied keys that may not be set: keys that
+// are fixed by the admin, and keys outside visibleKeys when that list is not
+// empty. Setting a parent of a restricted key (e.g. "image" when
+// "image.repository" is fixed) counts as overriding it.
+func RestrictedKeys(userValues map[string]interface{}, visibleKeys, fixedKeys []string) []FieldError {
+	var restricted []FieldError
+
+	for path := range FlattenValues(userValues) {
+		switch {
+		case matchesAny(path, fixedKeys, true):
+			restricted = append(restricted, FieldError{Path: path, Message: "fixed by administrator"})
+		case len(visibleKeys) > 0 && !matchesAny(path, visibleKeys, false):
+			restricted = append(restricted, FieldError{Path: path, Message: "not configurable"})
+		}
+	}
+
+	sort.Slice(restricted, func(i, j int) bool { return restricted[i].Path < restricted[j].Path })
+	return restricted
+}
+
+// matchesAny reports whether path equals or is nested under one of keys.
+// With includeParents, a path that is itself a parent of a key also matches.
+func matchesAny(path string, keys []string, includeParents bool) bool {
+	for _, key := range keys {
+		if path == key || strings.HasPrefix(path, key+".") {
+			return true
+		}
+		if includeParents && strings.HasPrefix(key, path+".") {
+			return true
+		}
+	}
+	return false
+}
+
 func hasKey(m map[string]interface{}, key string) bool {
 	parts := strings.Split(key, ".")
 	current := m
@@ -74,3 +130,41 @@ func hasKey(m map[string]interface{}, key string) bool {
 	}
 	return false
 }
+
+// RemoveKeys returns a copy of values with the given dot-separated keys removed.
+// Nested maps left empty by the removal are pruned as well.
+func RemoveKeys(values map[string]interface{}, keys []string) map[string]interface{} {
+	result := copyValues(values)
+	for _, key := range keys {
+		removeKey(result, strings.Split(key, "."))
+	}
+	return result
+}
+
+func removeKey(m map[string]interface{}, parts []string) {
+	if len(parts) == 1 {
+		delete(m, parts[0])
+		return
+	}
+
+	nested, ok := m[parts[0]].(map[string]interface{})
+	if !ok {
+		return
+	}
+	removeKey(nested, parts[1:])
+	if len(nested) == 0 {
+		delete(m, parts[0])
+	}
+}
+
+func copyValues(values map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(values))
+	for k, v := range values {
+		if nested, ok := v.(map[string]interface{}); ok {
+			result[k] = copyValues(nested)
+		} else {
+			result[k] = v
+		}
+	}
+	return result
+}
@@ -0,0 +1,65 @@
+package helm
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRemoveKeys(t *testing.T) {
+	values := map[string]interface{}{
+		"image":    map[string]interface{}{"repository": "nginx", "tag": "1.0"},
+		"auth":     map[string]interface{}{"password": "secret"},
+		"replicas": 2,
+	}
+
+	got := RemoveKeys(values, []string{"image.tag", "auth.password", "service.port", "replicas.count"})
+	want := map[string]interface{}{
+		"image":    map[string]interface{}{"repository": "nginx"},
+		"replicas": 2,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("RemoveKeys() = %v, want %v", got, want)
+	}
+
+	// The input is copied, not modified
+	if _, ok := values["auth"]; !ok {
+		t.Error("RemoveKeys removed auth from its input")
+	}
+	if tag := values["image"].(map[string]interface{})["tag"]; tag != "1.0" {
+		t.Errorf("RemoveKeys changed the input's image.tag to %v", tag)
+	}
+}
+
+func TestRestrictedKeys(t *testing.T) {
+	fixed := []string{"image.repository", "service.type"}
+
+	for name, tc := range map[string]struct {
+		values  map[string]interface{}
+		visible []string
+		want    string
+	}{
+		"unrestricted":       {map[string]interface{}{"replicas": 2}, nil, ""},
+		"fixed key":          {map[string]interface{}{"image": map[string]interface{}{"repository": "evil/nginx"}}, nil, "image.repository: fixed by administrator"},
+		"parent of a fixed":  {map[string]interface{}{"image": "evil/nginx:1.0"}, nil, "image: fixed by administrator"},
+		"sibling of a fixed": {map[string]interface{}{"image": map[string]interface{}{"tag": "1.0"}}, nil, ""},
+		"hidden keys": {
+			map[string]interface{}{"service": map[string]interface{}{"port": 80}, "replicas": 2, "debug": true},
+			[]string{"service"},
+			"debug: not configurable, replicas: not configurable",
+		},
+		"fixed beats visible": {
+			map[string]interface{}{"service": map[string]interface{}{"type": "NodePort", "port": 80}},
+			[]string{"service"},
+			"service.type: fixed by administrator",
+		},
+	} {
+		var got []string
+		for _, f := range RestrictedKeys(tc.values, tc.visible, fixed) {
+			got = append(got, f.Path+": "+f.Message)
+		}
+		if strings.Join(got, ", ") != tc.want {
+			t.Errorf("%s: RestrictedKeys() = %q, want %q", name, strings.Join(got, ", "), tc.want)
+		}
+	}
+}
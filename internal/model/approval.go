@@ -0,0 +1,43 @@
+package model
+
+import "time"
+
+// ApprovalPolicy requires deployments of a chart, or into a namespace, and
+// upgrades, rollbacks, re-syncs and uninstalls of their instances to be
+// approved before they run
+type ApprovalPolicy struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	ClusterID   *uint  `gorm:"uniqueIndex:idx_policy_target" json:"cluster_id"`           // nil applies the policy on every cluster
+	TargetType  string `gorm:"uniqueIndex:idx_policy_target;not null" json:"target_type"` // chart, namespace
+	Target      string `gorm:"uniqueIndex:idx_policy_target;not null" json:"target"`      // Chart ID or namespace; a trailing "*" matches a namespace prefix
+	Description string `json:"description"`
+
+	// Users and groups that may decide; admins always may
+	Approvers      StringArray `gorm:"type:text" json:"approvers"`       // Usernames
+	ApproverGroups StringArray `gorm:"type:text" json:"approver_groups"` // Group IDs
+}
+
+// Approval is the approval request of a task held back by approval policies
+type Approval struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	TaskID uint  `gorm:"uniqueIndex;not null" json:"task_id"`
+	Task   *Task `json:"task,omitempty"`
+
+	Status      string `gorm:"index" json:"status"` // pending, approved, rejected, cancelled
+	RequestedBy string `gorm:"index" json:"requested_by"`
+	ClusterID   uint   `json:"cluster_id"`
+	ChartID     string `json:"chart_id"`
+	Namespace   string `json:"namespace"`
+	ReleaseName string `json:"release_name"`
+
+	DecidedBy    string     `json:"decided_by,omitempty"`
+	DecidedAt    *time.Time `json:"decided_at,omitempty"`
+	Comment      string     `json:"comment,omitempty"`       // Rejection reason or approval note
+	ValuesEdited bool       `json:"values_edited,omitempty"` // The approver changed the user values
+}
@@ -0,0 +1,45 @@
+package model
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrAuditImmutable is returned when something tries to change a stored audit entry
+var ErrAuditImmutable = errors.New("audit entries cannot be modified")
+
+// AuditEntry records an administrative or deploy action. Entries are
+// append-only and hash-chained: Hash covers the entry and the Hash of the
+// entry before it, so editing or removing an entry breaks the chain.
+type AuditEntry struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+
+	ActorID   string `gorm:"index" json:"actor_id"`
+	ActorRole string `json:"actor_role"`
+	Action    string `gorm:"index" json:"action"` // e.g. user.create, chart.publish, instance.deploy
+
+	TargetType string  `gorm:"index:idx_audit_target" json:"target_type"` // user, chart, chart_version, repo, release, instance
+	TargetID   string  `gorm:"index:idx_audit_target" json:"target_id"`
+	Before     JSONMap `gorm:"type:text" json:"before,omitempty"`
+	After      JSONMap `gorm:"type:text" json:"after,omitempty"`
+
+	// Request metadata
+	IP        string `json:"ip"`
+	UserAgent string `json:"user_agent"`
+	Method    string `json:"method"`
+	Path      string `json:"path"`
+
+	PrevHash string `gorm:"uniqueIndex" json:"prev_hash"` // Unique so the chain cannot fork
+	Hash     string `gorm:"uniqueIndex;not null" json:"hash"`
+}
+
+func (e *AuditEntry) BeforeUpdate(tx *gorm.DB) error {
+	return ErrAuditImmutable
+}
+
+func (e *AuditEntry) BeforeDelete(tx *gorm.DB) error {
+	return ErrAuditImmutable
+}
@@ -57,4 +57,17 @@ type ChartMetadata struct {
 	VisibleKeys StringArray `gorm:"type:text" json:"visible_keys"`
 
 	FixedKeys StringArray `gorm:"type:text" json:"fixed_keys"`
+
+	// ValuesPolicy decides what happens to user values touching fixed or
+	// hidden keys: "reject" fails the deployment, "strip" silently drops them
+	ValuesPolicy string `gorm:"default:'reject'" json:"values_policy"`
+
+	// Constraints layers extra validation onto the chart schema. Keys are
+	// dotted value paths, values are JSON Schema fragments (enum, minimum,
+	// maximum, pattern, ...)
+	Constraints JSONMap `gorm:"type:text" json:"constraints"`
+
+	// FormFields holds deploy form hints per dotted path:
+	// {"label": "...", "help": "...", "group": "...", "order": 1, "type": "secret"}
+	FormFields JSONMap `gorm:"type:text" json:"form_fields"`
 }
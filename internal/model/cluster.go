@@ -0,0 +1,25 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Cluster is a Kubernetes cluster the market can deploy to
+type Cluster struct {
+	ID        uint           `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	Name        string `gorm:"uniqueIndex;not null" json:"name"`
+	Description string `json:"description"`
+
+	Mode       string `gorm:"not null;default:'kubeconfig'" json:"mode"` // kubeconfig, in_cluster
+	Kubeconfig string `gorm:"type:text" json:"-"`                        // Encrypted kubeconfig content
+	Context    string `json:"context"`                                   // Kubeconfig context, empty for the current one
+
+	// Default is used for deployments that do not name a cluster
+	IsDefault bool `gorm:"default:false" json:"is_default"`
+}
@@ -0,0 +1,31 @@
+package model
+
+import (
+	"time"
+)
+
+// DriftItem is a mismatch between AppInstance records and the Helm releases
+// found in the cluster, as detected by the drift reconciler
+type DriftItem struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"created_at"` // First detected
+	UpdatedAt time.Time `json:"updated_at"` // Last confirmed
+
+	Type        string `gorm:"index" json:"type"` // unmanaged_release, missing_release, values_drift
+	ClusterID   uint   `gorm:"uniqueIndex:idx_drift_cluster_release;not null;default:0" json:"cluster_id"`
+	Namespace   string `gorm:"uniqueIndex:idx_drift_cluster_release;not null" json:"namespace"`
+	ReleaseName string `gorm:"uniqueIndex:idx_drift_cluster_release;not null" json:"release_name"`
+	InstanceID  *uint  `gorm:"index" json:"instance_id,omitempty"`
+
+	// Live release, if it exists
+	Revision      int    `json:"revision,omitempty"`
+	ReleaseStatus string `json:"release_status,omitempty"`
+	Chart         string `json:"chart,omitempty"`
+	ChartVersion  string `json:"chart_version,omitempty"`
+
+	// Details holds the values diff for values_drift
+	Details JSONMap `gorm:"type:text" json:"details,omitempty"`
+
+	// Ignored unmanaged releases were forgotten by an admin and are no longer reported
+	Ignored bool `json:"ignored"`
+}
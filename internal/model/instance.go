@@ -1,6 +1,9 @@
 package model
 
 import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
 	"time"
 
 	"gorm.io/gorm"
@@ -13,15 +16,77 @@ type AppInstance struct {
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
 
-	Name      string `gorm:"uniqueIndex:idx_ns_name;not null" json:"name"`
-	Namespace string `gorm:"uniqueIndex:idx_ns_name;not null" json:"namespace"`
+	ClusterID uint   `gorm:"uniqueIndex:idx_cluster_ns_name;not null;default:0" json:"cluster_id"` // 0 is the cluster of the market's own kubeconfig
+	Name      string `gorm:"uniqueIndex:idx_cluster_ns_name;not null" json:"name"`
+	Namespace string `gorm:"uniqueIndex:idx_cluster_ns_name;not null" json:"namespace"`
 	UserID    string `gorm:"index" json:"user_id"`
 
 	ChartID      string `json:"chart_id"`
 	ChartVersion string `json:"chart_version"`
 
-	Status string `json:"status"` // deployed, failed, pending
+	// Adopted instances were installed outside the market. ChartName is the
+	// chart reported by the release; ChartID stays empty if no catalog chart
+	// of that name exists.
+	Adopted   bool   `json:"adopted"`
+	ChartName string `json:"chart_name,omitempty"`
+
+	Status string `json:"status"` // deployed, failed, pending, uninstalling, uninstall_failed
+
+	// Health of the release resources as last seen by the status reconciler
+	Health          string            `gorm:"index" json:"health"` // progressing, healthy, degraded, failed
+	Resources       ResourceSummaries `gorm:"type:text" json:"resources"`
+	HealthCheckedAt *time.Time        `json:"health_checked_at,omitempty"`
+
+	// Resource requests of the release pods, counted against quotas.
+	// RequestsRecorded is false until they were read from the release, e.g.
+	// for instances created before requests were tracked.
+	CPURequest       int64 `json:"cpu_request"`    // millicores
+	MemoryRequest    int64 `json:"memory_request"` // bytes
+	RequestsRecorded bool  `json:"requests_recorded"`
 
 	// AppliedValues stores the final merged values used for deployment
 	AppliedValues JSONMap `gorm:"type:text" json:"applied_values"`
+	// UserValues are the overrides supplied by the user, kept by upgrades
+	// that do not specify new ones
+	UserValues JSONMap `gorm:"type:text" json:"user_values"`
+}
+
+// InstanceRevision records the configuration applied by every Helm revision of an instance
+type InstanceRevision struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+
+	InstanceID uint   `gorm:"index;not null" json:"instance_id"`
+	Revision   int    `json:"revision"` // Helm release revision number
+	Action     string `json:"action"`   // install, upgrade, rollback, resync, adopt
+	TaskID     uint   `gorm:"index" json:"task_id"`
+
+	ChartVersion  string  `json:"chart_version"`
+	AppliedValues JSONMap `gorm:"type:text" json:"applied_values"`
+	UserValues    JSONMap `gorm:"type:text" json:"user_values"`
+}
+
+// ResourceSummary is the state of one Kubernetes resource of an instance
+type ResourceSummary struct {
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	Health    string `json:"health"`
+	Ready     string `json:"ready,omitempty"`
+	Message   string `json:"message,omitempty"`
+}
+
+// ResourceSummaries handles JSON storage for []ResourceSummary
+type ResourceSummaries []ResourceSummary
+
+func (r ResourceSummaries) Value() (driver.Value, error) {
+	return json.Marshal(r)
+}
+
+func (r *ResourceSummaries) Scan(value interface{}) error {
+	b, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+	return json.Unmarshal(b, &r)
 }
@@ -0,0 +1,12 @@
+package model
+
+import "time"
+
+// Lease elects the process that runs a background job meant to run once per
+// deployment rather than once per replica. The holder renews it on every run;
+// another process takes over once it expires.
+type Lease struct {
+	Name      string    `gorm:"primaryKey" json:"name"`
+	Owner     string    `gorm:"not null" json:"owner"`
+	ExpiresAt time.Time `gorm:"not null" json:"expires_at"`
+}
@@ -0,0 +1,19 @@
+package model
+
+import "time"
+
+// Quota limits the instances and resource requests of a user, a group (all
+// its members together), a namespace (across clusters) or a chart (across
+// the whole market). Zero or empty limits are unlimited.
+type Quota struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	SubjectType string `gorm:"uniqueIndex:idx_quota_subject;not null" json:"subject_type"` // user, group, namespace, chart
+	SubjectID   string `gorm:"uniqueIndex:idx_quota_subject;not null" json:"subject_id"`   // Username, group ID, namespace or chart ID
+
+	MaxInstances int    `json:"max_instances"`
+	CPU          string `json:"cpu"`    // Budget for CPU requests, e.g. "4" or "500m"
+	Memory       string `json:"memory"` // Budget for memory requests, e.g. "8Gi"
+}
@@ -14,6 +14,36 @@ type ChartRepo struct {
 
 	Name string `gorm:"uniqueIndex;not null" json:"name"`
 	URL  string `gorm:"not null" json:"url"`
+
+	// Credentials, sent only to the repo's own host. Secrets are encrypted
+	// and never returned.
+	AuthType    string `gorm:"default:'none'" json:"auth_type"` // none, basic, bearer
+	Username    string `json:"username,omitempty"`
+	Password    string `gorm:"type:text" json:"-"`
+	BearerToken string `gorm:"type:text" json:"-"`
+
+	// TLS settings, PEM encoded
+	CACert             string `gorm:"type:text" json:"ca_cert,omitempty"`     // Trusted in addition to the system roots
+	ClientCert         string `gorm:"type:text" json:"client_cert,omitempty"` // Client certificate for mutual TLS
+	ClientKey          string `gorm:"type:text" json:"-"`                     // Encrypted client key
+	InsecureSkipVerify bool   `json:"insecure_skip_verify"`
+
+	// Scheduled sync: SyncInterval is in seconds, 0 syncs only on request
+	SyncInterval int        `json:"sync_interval"`
+	NextSyncAt   *time.Time `gorm:"index" json:"next_sync_at,omitempty"`
+
+	// Outcome of the last sync
+	LastSyncAt     *time.Time `json:"last_sync_at,omitempty"`
+	LastSyncStatus string     `json:"last_sync_status,omitempty"` // synced, unchanged, failed
+	LastSyncError  string     `json:"last_sync_error,omitempty"`
+	ChartCount     int        `json:"chart_count"`    // Charts in the index
+	VersionCount   int        `json:"version_count"`  // Chart versions in the index
+	AddedVersions  int        `json:"added_versions"` // Versions added by the last sync
+
+	// Validators of the last synced index, used to skip unchanged indexes
+	IndexETag         string     `gorm:"column:index_etag" json:"-"`
+	IndexLastModified string     `json:"-"`
+	IndexGenerated    *time.Time `json:"index_generated,omitempty"`
 }
 
 type Chart struct {
@@ -46,4 +76,5 @@ type ChartVersion struct {
 	// 新增字段：支持本地上传的 Chart
 	ChartDefaultValues JSONMap `gorm:"type:text" json:"chart_default_values"` // Chart 原始 values.yaml
 	LocalPath          string  `json:"local_path"`                            // 本地存储路径 (如 /charts/nginx/1.0.0.tgz)
+	ValuesSchema       string  `gorm:"type:text" json:"values_schema"`        // Chart 自带的 values.schema.json
 }
@@ -1,6 +1,9 @@
 package model
 
 import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
 	"time"
 
 	"gorm.io/gorm"
@@ -8,13 +11,69 @@ import (
 
 type Task struct {
 	ID        uint           `gorm:"primaryKey" json:"id"`
-	CreatedAt time.Time      `json:"created_at"`
+	CreatedAt time.Time      `gorm:"index;index:idx_task_user_created,priority:2" json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
 
-	Type    string  `json:"type"`   // deploy, uninstall
-	Status  string  `json:"status"` // pending, running, completed, failed
+	Type    string  `gorm:"index" json:"type"`   // deploy, upgrade, rollback, uninstall, resync
+	Status  string  `gorm:"index" json:"status"` // awaiting_approval, pending, running, completed, failed, cancelled, rejected
 	Payload JSONMap `gorm:"type:text" json:"payload"`
 	Result  string  `json:"result"` // Error message or success details
-	UserID  string  `gorm:"index" json:"user_id"`
+	UserID  string  `gorm:"index;index:idx_task_user_created,priority:1" json:"user_id"`
+
+	// Chart and instance the task operates on. A deploy task is linked to the
+	// instance it created once it succeeds.
+	ChartID    string `gorm:"index" json:"chart_id"`
+	InstanceID *uint  `gorm:"index" json:"instance_id,omitempty"`
+
+	// LockKey serializes tasks touching the same release ("cluster/namespace/release")
+	LockKey string `gorm:"index" json:"lock_key"`
+
+	// Lease held by the worker currently running the task. A running task
+	// whose lease expired is considered orphaned and is picked up again.
+	LeaseOwner     string     `gorm:"index" json:"-"`
+	LeaseExpiresAt *time.Time `gorm:"index" json:"-"`
+	HeartbeatAt    *time.Time `json:"heartbeat_at,omitempty"`
+
+	// Retry bookkeeping: Attempts counts executions started, failed attempts
+	// are logged in AttemptLog and a retry is not started before NextRunAt
+	Attempts   int          `json:"attempts"`
+	AttemptLog TaskAttempts `gorm:"type:text" json:"attempt_log"`
+	NextRunAt  *time.Time   `gorm:"index" json:"next_run_at,omitempty"`
+
+	CancelRequested bool `json:"cancel_requested"`
+}
+
+// TaskAttempt records the outcome of one failed execution attempt
+type TaskAttempt struct {
+	Attempt    int       `json:"attempt"`
+	Error      string    `json:"error"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+}
+
+// TaskAttempts handles JSON storage for []TaskAttempt
+type TaskAttempts []TaskAttempt
+
+func (a TaskAttempts) Value() (driver.Value, error) {
+	return json.Marshal(a)
+}
+
+func (a *TaskAttempts) Scan(value interface{}) error {
+	b, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+	return json.Unmarshal(b, &a)
+}
+
+// TaskEvent is a progress step, log line or status change emitted while a task runs
+type TaskEvent struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+
+	TaskID  uint   `gorm:"index;not null" json:"task_id"`
+	Type    string `json:"type"`           // progress, log, status
+	Step    string `json:"step,omitempty"` // e.g. chart_resolved, install_started; the status for status events
+	Message string `json:"message"`
 }
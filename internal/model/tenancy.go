@@ -0,0 +1,50 @@
+package model
+
+import "time"
+
+// Group is a set of users that namespaces can be granted to
+type Group struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Name        string `gorm:"uniqueIndex;not null" json:"name"`
+	Description string `json:"description"`
+
+	Members []GroupMember `gorm:"foreignKey:GroupID" json:"members"`
+}
+
+// GroupMember puts a user into a group
+type GroupMember struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+
+	GroupID uint   `gorm:"uniqueIndex:idx_group_user;not null" json:"group_id"`
+	UserID  string `gorm:"uniqueIndex:idx_group_user;index;not null" json:"user_id"` // Username
+}
+
+// NamespaceGrant lets a user or the members of a group deploy into a
+// namespace, or into every namespace starting with a prefix
+type NamespaceGrant struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	ClusterID *uint  `gorm:"index" json:"cluster_id"` // nil grants the namespace on every cluster
+	Namespace string `gorm:"not null" json:"namespace"`
+	Prefix    bool   `json:"prefix"` // Namespace is a prefix, e.g. "team-a-"
+
+	SubjectType string `gorm:"index:idx_grant_subject;not null" json:"subject_type"` // user, group
+	SubjectID   string `gorm:"index:idx_grant_subject;not null" json:"subject_id"`   // Username or group ID
+}
+
+// Matches reports whether the grant covers a namespace of a cluster
+func (g *NamespaceGrant) Matches(clusterID uint, namespace string) bool {
+	if g.ClusterID != nil && *g.ClusterID != clusterID {
+		return false
+	}
+	if g.Prefix {
+		return len(namespace) >= len(g.Namespace) && namespace[:len(g.Namespace)] == g.Namespace
+	}
+	return namespace == g.Namespace
+}
@@ -0,0 +1,28 @@
+package model
+
+import "testing"
+
+func TestNamespaceGrantMatches(t *testing.T) {
+	exact := NamespaceGrant{Namespace: "team-a"}
+	if !exact.Matches(1, "team-a") || exact.Matches(1, "team-ab") {
+		t.Error("an exact grant must match only its namespace")
+	}
+
+	prefix := NamespaceGrant{Namespace: "team-a-", Prefix: true}
+	for namespace, want := range map[string]bool{
+		"team-a-dev": true,
+		"team-a-":    true,
+		"team-a":     false,
+		"team-b-dev": false,
+	} {
+		if got := prefix.Matches(1, namespace); got != want {
+			t.Errorf("prefix grant Matches(%q) = %v, want %v", namespace, got, want)
+		}
+	}
+
+	cluster := uint(2)
+	scoped := NamespaceGrant{ClusterID: &cluster, Namespace: "team-a"}
+	if !scoped.Matches(2, "team-a") || scoped.Matches(1, "team-a") {
+		t.Error("a cluster scoped grant must match only on its cluster")
+	}
+}
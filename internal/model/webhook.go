@@ -0,0 +1,42 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Webhook is an outbound endpoint notified of lifecycle events
+type Webhook struct {
+	ID        uint           `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	Name    string      `gorm:"uniqueIndex;not null" json:"name"`
+	URL     string      `gorm:"not null" json:"url"`
+	Events  StringArray `gorm:"type:text" json:"events"` // Subscribed events, "*" for all
+	Secret  string      `gorm:"type:text" json:"-"`      // Encrypted HMAC signing secret
+	Enabled bool        `json:"enabled"`
+}
+
+// WebhookDelivery is one event sent, or to be sent, to a webhook. Failed
+// attempts are retried with backoff until the delivery succeeds or runs out
+// of attempts.
+type WebhookDelivery struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	WebhookID uint    `gorm:"index;not null" json:"webhook_id"`
+	Event     string  `gorm:"index" json:"event"`
+	Payload   JSONMap `gorm:"type:text" json:"payload"`
+
+	Status        string     `gorm:"index:idx_delivery_due,priority:1" json:"status"` // pending, delivered, failed
+	Attempts      int        `json:"attempts"`
+	NextAttemptAt *time.Time `gorm:"index:idx_delivery_due,priority:2" json:"next_attempt_at,omitempty"`
+	StatusCode    int        `json:"status_code,omitempty"`               // HTTP status of the last attempt
+	Response      string     `gorm:"type:text" json:"response,omitempty"` // Start of the last response body
+	Error         string     `json:"error,omitempty"`                     // Error of the last attempt
+	DeliveredAt   *time.Time `json:"delivered_at,omitempty"`
+}
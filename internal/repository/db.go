@@ -25,15 +25,37 @@ func NewDB(cfg config.DatabaseConfig) (*gorm.DB, error) {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
 
+	// The instance name index gained a cluster_id column; AutoMigrate does not
+	// alter existing indexes, so drop the old one before it is recreated
+	if db.Migrator().HasIndex(&model.AppInstance{}, "idx_ns_name") {
+		if err := db.Migrator().DropIndex(&model.AppInstance{}, "idx_ns_name"); err != nil {
+			return nil, fmt.Errorf("failed to drop index idx_ns_name: %w", err)
+		}
+	}
+
 	// Auto Migrate
 	if err := db.AutoMigrate(
 		&model.ChartMetadata{},
 		&model.AppInstance{},
+		&model.InstanceRevision{},
 		&model.ChartRepo{},
 		&model.Chart{},
 		&model.ChartVersion{},
 		&model.Task{},
+		&model.TaskEvent{},
+		&model.Lease{},
+		&model.DriftItem{},
+		&model.Cluster{},
 		&model.User{},
+		&model.Group{},
+		&model.GroupMember{},
+		&model.NamespaceGrant{},
+		&model.Quota{},
+		&model.ApprovalPolicy{},
+		&model.Approval{},
+		&model.AuditEntry{},
+		&model.Webhook{},
+		&model.WebhookDelivery{},
 	); err != nil {
 		return nil, fmt.Errorf("failed to migrate database: %w", err)
 	}
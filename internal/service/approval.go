@@ -0,0 +1,425 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/your-org/app-market/internal/model"
+	"gorm.io/gorm"
+)
+
+// Approval policy targets
+const (
+	PolicyTargetChart     = "chart"
+	PolicyTargetNamespace = "namespace"
+)
+
+var (
+	// ErrNotApprover is returned when a user may not decide an approval
+	ErrNotApprover = errors.New("not an approver for this deployment")
+	// ErrApprovalClosed is returned when an approval was already decided or cancelled
+	ErrApprovalClosed = errors.New("approval is no longer pending")
+	// ErrApprovalNotFound is returned when an approval does not exist
+	ErrApprovalNotFound = errors.New("approval not found")
+	// ErrValuesNotEditable is returned when an approver edits the values of a
+	// task that does not apply any
+	ErrValuesNotEditable = errors.New("values can only be edited for deployments and upgrades")
+)
+
+// ApprovalService manages approval policies and decides who may approve the
+// tasks they hold back. The tasks themselves are released by TaskService.
+type ApprovalService struct {
+	db *gorm.DB
+}
+
+func NewApprovalService(db *gorm.DB) *ApprovalService {
+	return &ApprovalService{db: db}
+}
+
+// PolicyInput creates or updates an approval policy
+type PolicyInput struct {
+	ClusterID      *uint
+	TargetType     string
+	Target         string
+	Description    string
+	Approvers      []string
+	ApproverGroups []string
+}
+
+// CreatePolicy attaches an approval policy to a chart or namespace, on one
+// cluster or on all of them
+func (s *ApprovalService) CreatePolicy(input PolicyInput) (*model.ApprovalPolicy, error) {
+	policy := &model.ApprovalPolicy{}
+	if err := applyPolicyInput(policy, input); err != nil {
+		return nil, err
+	}
+	if err := s.db.Create(policy).Error; err != nil {
+		return nil, fmt.Errorf("failed to create approval policy: %w", err)
+	}
+	return policy, nil
+}
+
+// GetPolicy returns an approval policy
+func (s *ApprovalService) GetPolicy(id uint) (*model.ApprovalPolicy, error) {
+	var policy model.ApprovalPolicy
+	if err := s.db.First(&policy, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("approval policy not found")
+		}
+		return nil, fmt.Errorf("failed to get approval policy: %w", err)
+	}
+	return &policy, nil
+}
+
+// UpdatePolicy replaces the target and approvers of a policy
+func (s *ApprovalService) UpdatePolicy(id uint, input PolicyInput) (*model.ApprovalPolicy, error) {
+	policy, err := s.GetPolicy(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyPolicyInput(policy, input); err != nil {
+		return nil, err
+	}
+	if err := s.db.Save(policy).Error; err != nil {
+		return nil, fmt.Errorf("failed to update approval policy: %w", err)
+	}
+	return policy, nil
+}
+
+func applyPolicyInput(policy *model.ApprovalPolicy, input PolicyInput) error {
+	if input.TargetType != PolicyTargetChart && input.TargetType != PolicyTargetNamespace {
+		return fmt.Errorf("invalid policy target type: %s", input.TargetType)
+	}
+	if input.Target == "" {
+		return fmt.Errorf("policy target is required")
+	}
+	policy.ClusterID = input.ClusterID
+	policy.TargetType = input.TargetType
+	policy.Target = input.Target
+	policy.Description = input.Description
+	policy.Approvers = model.StringArray(input.Approvers)
+	policy.ApproverGroups = model.StringArray(input.ApproverGroups)
+	return nil
+}
+
+// ListPolicies returns all approval policies
+func (s *ApprovalService) ListPolicies() ([]model.ApprovalPolicy, error) {
+	policies := []model.ApprovalPolicy{}
+	err := s.db.Order("target_type, target").Find(&policies).Error
+	return policies, err
+}
+
+// DeletePolicy removes an approval policy. Deployments already waiting stay
+// pending until decided.
+func (s *ApprovalService) DeletePolicy(id uint) error {
+	result := s.db.Delete(&model.ApprovalPolicy{}, id)
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete approval policy: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("approval policy not found")
+	}
+	return nil
+}
+
+// policiesFor returns the policies that apply to a release of a chart in a
+// namespace on a cluster
+func (s *ApprovalService) policiesFor(clusterID uint, chartID, namespace string) ([]model.ApprovalPolicy, error) {
+	var candidates []model.ApprovalPolicy
+	err := s.db.Where("cluster_id IS NULL OR cluster_id = ?", clusterID).
+		Where("(target_type = ? AND target = ?) OR target_type = ?", PolicyTargetChart, chartID, PolicyTargetNamespace).
+		Find(&candidates).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get approval policies: %w", err)
+	}
+
+	var policies []model.ApprovalPolicy
+	for _, p := range candidates {
+		if p.TargetType == PolicyTargetNamespace {
+			if prefix, ok := strings.CutSuffix(p.Target, "*"); ok {
+				if !strings.HasPrefix(namespace, prefix) {
+					continue
+				}
+			} else if p.Target != namespace {
+				continue
+			}
+		}
+		policies = append(policies, p)
+	}
+	return policies, nil
+}
+
+// canDecide reports whether a user may approve or reject a task: admins
+// and the approvers of a matching policy may, except for their own requests.
+// userID is the username and role the role from the user's token.
+func (s *ApprovalService) canDecide(userID, role string, approval *model.Approval) (bool, error) {
+	if userID == approval.RequestedBy {
+		return false, nil
+	}
+	if role == "admin" {
+		return true, nil
+	}
+
+	policies, err := s.policiesFor(approval.ClusterID, approval.ChartID, approval.Namespace)
+	if err != nil {
+		return false, err
+	}
+	var groups []string
+	if err := s.db.Model(&model.GroupMember{}).Where("user_id = ?", userID).Pluck("group_id", &groups).Error; err != nil {
+		return false, fmt.Errorf("failed to get groups: %w", err)
+	}
+	for _, p := range policies {
+		if slices.Contains(p.Approvers, userID) {
+			return true, nil
+		}
+		for _, g := range groups {
+			if slices.Contains(p.ApproverGroups, g) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// ListApprovals returns the approvals a user may decide, with their tasks.
+// An empty status lists pending approvals.
+func (s *ApprovalService) ListApprovals(userID, role, status string) ([]model.Approval, error) {
+	if status == "" {
+		status = "pending"
+	}
+
+	var all []model.Approval
+	if err := s.db.Preload("Task").Where("status = ?", status).Order("id DESC").Find(&all).Error; err != nil {
+		return nil, fmt.Errorf("failed to list approvals: %w", err)
+	}
+
+	approvals := []model.Approval{}
+	for i := range all {
+		ok, err := s.canDecide(userID, role, &all[i])
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			approvals = append(approvals, all[i])
+		}
+	}
+	return approvals, nil
+}
+
+// pendingApproval loads an approval a user is about to decide
+func (s *ApprovalService) pendingApproval(id uint, userID, role string) (*model.Approval, error) {
+	var approval model.Approval
+	if err := s.db.First(&approval, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrApprovalNotFound
+		}
+		return nil, fmt.Errorf("failed to get approval: %w", err)
+	}
+	if approval.Status != "pending" {
+		return nil, ErrApprovalClosed
+	}
+
+	ok, err := s.canDecide(userID, role, &approval)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrNotApprover
+	}
+	return &approval, nil
+}
+
+// enqueueOrHold queues a task, or holds it for approval if a policy covers
+// its release
+func (s *TaskService) enqueueOrHold(task *model.Task, req interface{}) (*model.Task, error) {
+	clusterID, namespace, _ := releaseOf(task.LockKey)
+	policies, err := s.approvalService.policiesFor(clusterID, task.ChartID, namespace)
+	if err != nil {
+		return nil, err
+	}
+	if len(policies) > 0 {
+		return s.holdForApproval(task, req)
+	}
+	return s.enqueue(task, req)
+}
+
+// holdForApproval stores a task awaiting approval instead of queueing it
+func (s *TaskService) holdForApproval(task *model.Task, req interface{}) (*model.Task, error) {
+	payload, err := toPayload(req)
+	if err != nil {
+		return nil, err
+	}
+	task.Status = "awaiting_approval"
+	task.Payload = payload
+	task.CreatedAt = time.Now()
+
+	clusterID, namespace, releaseName := releaseOf(task.LockKey)
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(task).Error; err != nil {
+			return err
+		}
+		return tx.Create(&model.Approval{
+			TaskID:      task.ID,
+			Status:      "pending",
+			RequestedBy: task.UserID,
+			ClusterID:   clusterID,
+			ChartID:     task.ChartID,
+			Namespace:   namespace,
+			ReleaseName: releaseName,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.events.publish(task.ID, "status", "awaiting_approval", "Waiting for approval")
+	return task, nil
+}
+
+// approvedPayload validates a held task again before it is released and
+// returns its payload. userValues, if not nil, replace the values of a
+// deployment or upgrade; other tasks apply no values.
+func (s *TaskService) approvedPayload(task *model.Task, userValues map[string]interface{}, includeUnpublished bool) (model.JSONMap, error) {
+	switch task.Type {
+	case "deploy":
+		var req DeployRequest
+		if err := decodePayload(*task, &req); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal deploy request: %w", err)
+		}
+		if userValues != nil {
+			req.UserValues = userValues
+		}
+		if err := s.deployService.ValidateDeploy(req, includeUnpublished); err != nil {
+			return nil, err
+		}
+		return toPayload(req)
+	case "upgrade":
+		var req UpgradeRequest
+		if err := decodePayload(*task, &req); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal upgrade request: %w", err)
+		}
+		if userValues != nil {
+			req.UserValues = userValues
+		}
+		if err := s.deployService.ValidateUpgrade(req); err != nil {
+			return nil, err
+		}
+		return toPayload(req)
+	}
+
+	if userValues != nil {
+		return nil, ErrValuesNotEditable
+	}
+	if task.InstanceID != nil {
+		if err := s.db.First(&model.AppInstance{}, *task.InstanceID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, ErrInstanceNotFound
+			}
+			return nil, fmt.Errorf("failed to get instance: %w", err)
+		}
+	}
+	return task.Payload, nil
+}
+
+// ApproveTask releases a task waiting for approval to the workers. If
+// userValues is not nil it replaces the requested values of a deployment or
+// upgrade; the task is validated again either way.
+func (s *TaskService) ApproveTask(approvalID uint, approverID, role string, userValues map[string]interface{}, comment string) (*model.Approval, error) {
+	approval, err := s.approvalService.pendingApproval(approvalID, approverID, role)
+	if err != nil {
+		return nil, err
+	}
+	task, err := s.GetTask(approval.TaskID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Admin approvers may release deployments of charts unpublished since
+	payload, err := s.approvedPayload(task, userValues, role == "admin")
+	if err != nil {
+		return nil, err
+	}
+	approval.ValuesEdited = userValues != nil
+
+	now := time.Now()
+	approval.Status = "approved"
+	approval.DecidedBy = approverID
+	approval.DecidedAt = &now
+	approval.Comment = comment
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if task.Type == "uninstall" {
+			var queued int64
+			err := tx.Model(&model.Task{}).
+				Where("instance_id = ? AND type = ? AND status IN ?", task.InstanceID, "uninstall", []string{"pending", "running"}).
+				Count(&queued).Error
+			if err != nil {
+				return err
+			}
+			if queued > 0 {
+				return ErrUninstallQueued
+			}
+		}
+
+		result := tx.Model(&model.Task{}).
+			Where("id = ? AND status = ?", task.ID, "awaiting_approval").
+			Updates(map[string]interface{}{"status": "pending", "payload": payload})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrApprovalClosed
+		}
+		return tx.Save(approval).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.events.publish(task.ID, "status", "pending", fmt.Sprintf("Approved by user %s", approverID))
+	s.wakeWorker()
+	if task.Type == "uninstall" {
+		s.markUninstalling(*task.InstanceID)
+	}
+	return approval, nil
+}
+
+// RejectTask ends a deployment waiting for approval
+func (s *TaskService) RejectTask(approvalID uint, approverID, role, reason string) (*model.Approval, error) {
+	if reason == "" {
+		return nil, fmt.Errorf("a reason is required")
+	}
+	approval, err := s.approvalService.pendingApproval(approvalID, approverID, role)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	approval.Status = "rejected"
+	approval.DecidedBy = approverID
+	approval.DecidedAt = &now
+	approval.Comment = reason
+
+	result := "Rejected: " + reason
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		updated := tx.Model(&model.Task{}).
+			Where("id = ? AND status = ?", approval.TaskID, "awaiting_approval").
+			Updates(map[string]interface{}{"status": "rejected", "result": result})
+		if updated.Error != nil {
+			return updated.Error
+		}
+		if updated.RowsAffected == 0 {
+			return ErrApprovalClosed
+		}
+		return tx.Save(approval).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.events.publish(approval.TaskID, "status", "rejected", result)
+	return approval, nil
+}
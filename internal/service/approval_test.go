@@ -0,0 +1,66 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/your-org/app-market/internal/config"
+	"github.com/your-org/app-market/internal/model"
+)
+
+func TestRollbackHeldByPolicyOnItsCluster(t *testing.T) {
+	db := newTestDB(t)
+	approvals := NewApprovalService(db)
+	tasks := NewTaskService(db, nil, approvals, nil, config.TaskConfig{})
+
+	instance := &model.AppInstance{Name: "db", ClusterID: 2, Namespace: "prod", UserID: "alice", ChartID: "1"}
+	if err := db.Create(instance).Error; err != nil {
+		t.Fatal(err)
+	}
+	other := uint(1)
+	if _, err := approvals.CreatePolicy(PolicyInput{ClusterID: &other, TargetType: PolicyTargetNamespace, Target: "prod"}); err != nil {
+		t.Fatalf("CreatePolicy: %v", err)
+	}
+
+	task, err := tasks.EnqueueRollback("alice", RollbackRequest{UserID: "alice", InstanceID: instance.ID})
+	if err != nil {
+		t.Fatalf("EnqueueRollback: %v", err)
+	}
+	if task.Status != "pending" {
+		t.Errorf("rollback with a policy on another cluster is %s, want pending", task.Status)
+	}
+
+	if _, err := approvals.CreatePolicy(PolicyInput{TargetType: PolicyTargetNamespace, Target: "prod*"}); err != nil {
+		t.Fatalf("CreatePolicy: %v", err)
+	}
+	task, err = tasks.EnqueueRollback("alice", RollbackRequest{UserID: "alice", InstanceID: instance.ID})
+	if err != nil {
+		t.Fatalf("EnqueueRollback: %v", err)
+	}
+	if task.Status != "awaiting_approval" {
+		t.Fatalf("rollback with a policy on every cluster is %s, want awaiting_approval", task.Status)
+	}
+
+	var approval model.Approval
+	if err := db.Where("task_id = ?", task.ID).First(&approval).Error; err != nil {
+		t.Fatal(err)
+	}
+	if approval.ClusterID != 2 || approval.Namespace != "prod" || approval.ReleaseName != "db" {
+		t.Errorf("approval is for %d/%s/%s, want 2/prod/db", approval.ClusterID, approval.Namespace, approval.ReleaseName)
+	}
+
+	_, err = tasks.ApproveTask(approval.ID, "bob", "admin", map[string]interface{}{"replicas": 2}, "")
+	if !errors.Is(err, ErrValuesNotEditable) {
+		t.Errorf("approving a rollback with values: got %v, want ErrValuesNotEditable", err)
+	}
+	if _, err := tasks.ApproveTask(approval.ID, "bob", "admin", nil, "ok"); err != nil {
+		t.Fatalf("ApproveTask: %v", err)
+	}
+	released, err := tasks.GetTask(task.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if released.Status != "pending" {
+		t.Errorf("approved rollback is %s, want pending", released.Status)
+	}
+}
@@ -0,0 +1,251 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/your-org/app-market/internal/model"
+	"github.com/your-org/app-market/pkg/crypto"
+	"gorm.io/gorm"
+)
+
+// auditVerifyBatch is the number of entries Verify loads at a time
+const auditVerifyBatch = 500
+
+// auditChainLock is the lease row Record locks so each entry chains onto the
+// latest one, whichever replica wrote it
+const auditChainLock = "audit_chain"
+
+// AuditService appends entries to the hash-chained audit log and checks the
+// chain for tampering
+type AuditService struct {
+	db     *gorm.DB
+	cipher *crypto.Cipher
+}
+
+func NewAuditService(db *gorm.DB, cipher *crypto.Cipher) *AuditService {
+	return &AuditService{db: db, cipher: cipher}
+}
+
+// AuditRecord describes an action to record. Before and After are snapshots
+// of the target and are stored as JSON objects.
+type AuditRecord struct {
+	ActorID    string
+	ActorRole  string
+	Action     string
+	TargetType string
+	TargetID   string
+	Before     interface{}
+	After      interface{}
+
+	IP        string
+	UserAgent string
+	Method    string
+	Path      string
+}
+
+// Record appends an entry to the audit log
+func (s *AuditService) Record(rec AuditRecord) (*model.AuditEntry, error) {
+	before, err := auditSnapshot(rec.Before)
+	if err != nil {
+		return nil, err
+	}
+	after, err := auditSnapshot(rec.After)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &model.AuditEntry{
+		// Stored at microsecond precision so the hash survives any database
+		CreatedAt:  time.Now().UTC().Truncate(time.Microsecond),
+		ActorID:    rec.ActorID,
+		ActorRole:  rec.ActorRole,
+		Action:     rec.Action,
+		TargetType: rec.TargetType,
+		TargetID:   rec.TargetID,
+		Before:     before,
+		After:      after,
+		IP:         rec.IP,
+		UserAgent:  rec.UserAgent,
+		Method:     rec.Method,
+		Path:       rec.Path,
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockLease(tx, auditChainLock); err != nil {
+			return err
+		}
+		var last model.AuditEntry
+		if err := tx.Order("id DESC").Limit(1).Find(&last).Error; err != nil {
+			return err
+		}
+		entry.PrevHash = last.Hash
+		entry.Hash = auditHash(entry)
+		return tx.Create(entry).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to record audit entry: %w", err)
+	}
+	return entry, nil
+}
+
+// auditSnapshot converts a snapshot to the JSON object it is stored as, so
+// the hash is computed over exactly what is read back
+func auditSnapshot(v interface{}) (model.JSONMap, error) {
+	if v == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal audit snapshot: %w", err)
+	}
+	var snapshot model.JSONMap
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("audit snapshot is not an object: %w", err)
+	}
+	return snapshot, nil
+}
+
+// ValuesDigest returns a keyed digest of Helm values, which audit snapshots
+// record instead of the values themselves. Equal values give equal digests,
+// but without the server's key they cannot be guessed from the digest.
+func (s *AuditService) ValuesDigest(values map[string]interface{}) string {
+	// Maps are marshalled with sorted keys
+	data, _ := json.Marshal(values)
+	return s.cipher.MAC(data)
+}
+
+// auditHash hashes an entry's content together with the hash of its predecessor
+func auditHash(e *model.AuditEntry) string {
+	// Fixed field order; maps are marshalled with sorted keys
+	data, _ := json.Marshal([]interface{}{
+		e.PrevHash,
+		e.CreatedAt.UTC().Format(time.RFC3339Nano),
+		e.ActorID,
+		e.ActorRole,
+		e.Action,
+		e.TargetType,
+		e.TargetID,
+		e.Before,
+		e.After,
+		e.IP,
+		e.UserAgent,
+		e.Method,
+		e.Path,
+	})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// AuditFilter selects entries for ListAudit. Zero values match everything.
+type AuditFilter struct {
+	ActorID    string
+	Action     string // "chart.*" matches a prefix
+	TargetType string
+	TargetID   string
+	From       *time.Time // created at or after
+	To         *time.Time // created before
+	Page       int
+	Limit      int
+}
+
+// AuditListOutput is a page of audit entries
+type AuditListOutput struct {
+	Entries []model.AuditEntry `json:"entries"`
+	Total   int64              `json:"total"`
+	Page    int                `json:"page"`
+	Limit   int                `json:"limit"`
+}
+
+// ListAudit returns a page of audit entries matching the filter, newest first
+func (s *AuditService) ListAudit(filter AuditFilter) (*AuditListOutput, error) {
+	if filter.Page < 1 {
+		filter.Page = 1
+	}
+	if filter.Limit < 1 || filter.Limit > 100 {
+		filter.Limit = 50
+	}
+
+	query := s.db.Model(&model.AuditEntry{})
+	if filter.ActorID != "" {
+		query = query.Where("actor_id = ?", filter.ActorID)
+	}
+	if filter.Action != "" {
+		if prefix, ok := strings.CutSuffix(filter.Action, "*"); ok {
+			query = query.Where("action LIKE ?", prefix+"%")
+		} else {
+			query = query.Where("action = ?", filter.Action)
+		}
+	}
+	if filter.TargetType != "" {
+		query = query.Where("target_type = ?", filter.TargetType)
+	}
+	if filter.TargetID != "" {
+		query = query.Where("target_id = ?", filter.TargetID)
+	}
+	if filter.From != nil {
+		query = query.Where("created_at >= ?", filter.From.UTC())
+	}
+	if filter.To != nil {
+		query = query.Where("created_at < ?", filter.To.UTC())
+	}
+
+	output := &AuditListOutput{Entries: []model.AuditEntry{}, Page: filter.Page, Limit: filter.Limit}
+	if err := query.Count(&output.Total).Error; err != nil {
+		return nil, fmt.Errorf("failed to count audit entries: %w", err)
+	}
+
+	err := query.Order("id DESC").
+		Offset((filter.Page - 1) * filter.Limit).Limit(filter.Limit).
+		Find(&output.Entries).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audit entries: %w", err)
+	}
+	return output, nil
+}
+
+// AuditVerification is the result of checking the audit chain
+type AuditVerification struct {
+	Valid    bool   `json:"valid"`
+	Checked  int    `json:"checked"`             // Entries checked before stopping
+	BrokenID uint   `json:"broken_id,omitempty"` // First entry that does not match the chain
+	Reason   string `json:"reason,omitempty"`
+	HeadHash string `json:"head_hash"` // Hash of the latest valid entry; keep a copy elsewhere to detect truncation
+}
+
+// Verify walks the audit log from the first entry and reports the first
+// entry whose content or link to its predecessor was changed
+func (s *AuditService) Verify() (*AuditVerification, error) {
+	result := &AuditVerification{Valid: true}
+	var lastID uint
+	for {
+		var batch []model.AuditEntry
+		if err := s.db.Where("id > ?", lastID).Order("id").Limit(auditVerifyBatch).Find(&batch).Error; err != nil {
+			return nil, fmt.Errorf("failed to load audit entries: %w", err)
+		}
+		for i := range batch {
+			e := &batch[i]
+			switch {
+			case e.PrevHash != result.HeadHash:
+				result.Reason = "entry does not link to the previous entry"
+			case auditHash(e) != e.Hash:
+				result.Reason = "entry content does not match its hash"
+			}
+			if result.Reason != "" {
+				result.Valid = false
+				result.BrokenID = e.ID
+				return result, nil
+			}
+			result.Checked++
+			result.HeadHash = e.Hash
+			lastID = e.ID
+		}
+		if len(batch) < auditVerifyBatch {
+			return result, nil
+		}
+	}
+}
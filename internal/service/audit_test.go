@@ -0,0 +1,102 @@
+package service
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/your-org/app-market/internal/model"
+	"github.com/your-org/app-market/pkg/crypto"
+	"gorm.io/gorm"
+)
+
+func TestAuditConcurrentRecordsFormOneChain(t *testing.T) {
+	db := newTestDB(t)
+	cipher, _ := crypto.NewCipher("test-key")
+	replicas := []*AuditService{NewAuditService(db, cipher), NewAuditService(db, cipher)}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if _, err := replicas[i%2].Record(AuditRecord{ActorID: "alice", Action: fmt.Sprintf("test.%d", i)}); err != nil {
+				t.Errorf("Record: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	result, err := replicas[0].Verify()
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if !result.Valid || result.Checked != 10 {
+		t.Errorf("Verify() = %+v, want a valid chain of 10 entries", result)
+	}
+}
+
+// recordAuditChain records three entries and returns them
+func recordAuditChain(t *testing.T) (*gorm.DB, *AuditService, []*model.AuditEntry) {
+	t.Helper()
+	db := newTestDB(t)
+	cipher, _ := crypto.NewCipher("test-key")
+	audit := NewAuditService(db, cipher)
+
+	var entries []*model.AuditEntry
+	for _, action := range []string{"repo.add", "chart.publish", "instance.deploy"} {
+		entry, err := audit.Record(AuditRecord{ActorID: "alice", Action: action, After: map[string]string{"action": action}})
+		if err != nil {
+			t.Fatalf("Record: %v", err)
+		}
+		entries = append(entries, entry)
+	}
+	return db, audit, entries
+}
+
+func TestAuditVerifyDetectsTampering(t *testing.T) {
+	t.Run("changed content", func(t *testing.T) {
+		db, audit, entries := recordAuditChain(t)
+		if err := db.Exec("UPDATE audit_entries SET actor_id = ? WHERE id = ?", "mallory", entries[1].ID).Error; err != nil {
+			t.Fatal(err)
+		}
+		if result, _ := audit.Verify(); result.Valid || result.BrokenID != entries[1].ID {
+			t.Errorf("Verify() = %+v, want entry %d reported", result, entries[1].ID)
+		}
+	})
+
+	// A recomputed hash no longer matches the link stored in the next entry
+	t.Run("changed content with a recomputed hash", func(t *testing.T) {
+		db, audit, entries := recordAuditChain(t)
+		forged := *entries[1]
+		forged.ActorID = "mallory"
+		err := db.Exec("UPDATE audit_entries SET actor_id = ?, hash = ? WHERE id = ?", forged.ActorID, auditHash(&forged), forged.ID).Error
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result, _ := audit.Verify(); result.Valid || result.BrokenID != entries[2].ID {
+			t.Errorf("Verify() = %+v, want entry %d reported", result, entries[2].ID)
+		}
+	})
+
+	t.Run("deleted entry", func(t *testing.T) {
+		db, audit, entries := recordAuditChain(t)
+		if err := db.Exec("DELETE FROM audit_entries WHERE id = ?", entries[1].ID).Error; err != nil {
+			t.Fatal(err)
+		}
+		if result, _ := audit.Verify(); result.Valid || result.BrokenID != entries[2].ID {
+			t.Errorf("Verify() = %+v, want entry %d reported", result, entries[2].ID)
+		}
+	})
+
+	t.Run("untouched", func(t *testing.T) {
+		_, audit, entries := recordAuditChain(t)
+		result, err := audit.Verify()
+		if err != nil {
+			t.Fatalf("Verify: %v", err)
+		}
+		if !result.Valid || result.HeadHash != entries[2].Hash {
+			t.Errorf("Verify() = %+v, want a valid chain ending in %s", result, entries[2].Hash)
+		}
+	})
+}
@@ -4,16 +4,25 @@ import (
 	"errors"
 	"fmt"
 
+	"github.com/your-org/app-market/internal/helm"
 	"github.com/your-org/app-market/internal/model"
 	"gorm.io/gorm"
 )
 
+var (
+	// ErrChartNotFound is returned when a chart does not exist or is not visible
+	ErrChartNotFound = errors.New("chart not found")
+	// ErrChartVersionNotFound is returned when a chart has no such version
+	ErrChartVersionNotFound = errors.New("chart version not found")
+)
+
 type ChartService struct {
-	db *gorm.DB
+	db       *gorm.DB
+	webhooks *WebhookService
 }
 
-func NewChartService(db *gorm.DB) *ChartService {
-	return &ChartService{db: db}
+func NewChartService(db *gorm.DB, webhooks *WebhookService) *ChartService {
+	return &ChartService{db: db, webhooks: webhooks}
 }
 
 // SaveMetadata creates or updates chart configuration
@@ -53,6 +62,50 @@ func (s *ChartService) GetMetadata(chartID, version string) (*model.ChartMetadat
 	return &meta, nil
 }
 
+// GetDeployForm builds the typed deploy form for a chart version from its
+// defaults, values schema and admin configuration. Unpublished charts are
+// reported as not found unless includeUnpublished is set.
+func (s *ChartService) GetDeployForm(chartID, version string, includeUnpublished bool) ([]helm.FormField, error) {
+	var chart model.Chart
+	if err := s.db.First(&chart, "id = ?", chartID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrChartNotFound
+		}
+		return nil, fmt.Errorf("failed to find chart: %w", err)
+	}
+	if !chart.Published && !includeUnpublished {
+		return nil, ErrChartNotFound
+	}
+
+	var chartVersion model.ChartVersion
+	if err := s.db.Where("chart_id = ? AND version = ?", chartID, version).First(&chartVersion).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrChartVersionNotFound
+		}
+		return nil, fmt.Errorf("failed to find chart version: %w", err)
+	}
+
+	meta, err := s.GetMetadata(chartID, version)
+	if err != nil {
+		return nil, err
+	}
+
+	defaults, err := helm.MergeValues(chartVersion.ChartDefaultValues, meta.DefaultValues, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return helm.BuildForm(helm.FormInput{
+		Defaults:     defaults,
+		Schema:       []byte(chartVersion.ValuesSchema),
+		Constraints:  meta.Constraints,
+		Hints:        meta.FormFields,
+		VisibleKeys:  meta.VisibleKeys,
+		RequiredKeys: meta.RequiredKeys,
+		FixedKeys:    meta.FixedKeys,
+	})
+}
+
 func (s *ChartService) ListCharts(onlyPublished bool) ([]model.Chart, error) {
 	var charts []model.Chart
 	query := s.db.Preload("Versions")
@@ -65,8 +118,37 @@ func (s *ChartService) ListCharts(onlyPublished bool) ([]model.Chart, error) {
 	return charts, nil
 }
 
+// GetChart returns a chart without its versions
+func (s *ChartService) GetChart(chartID uint) (*model.Chart, error) {
+	var chart model.Chart
+	if err := s.db.First(&chart, chartID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrChartNotFound
+		}
+		return nil, fmt.Errorf("failed to find chart: %w", err)
+	}
+	return &chart, nil
+}
+
+// UpdatePublishStatus publishes or unpublishes a chart and notifies webhooks
+// when it becomes published
 func (s *ChartService) UpdatePublishStatus(chartID uint, published bool) error {
-	return s.db.Model(&model.Chart{}).Where("id = ?", chartID).Update("published", published).Error
+	chart, err := s.GetChart(chartID)
+	if err != nil {
+		return err
+	}
+	if err := s.db.Model(&model.Chart{}).Where("id = ?", chartID).Update("published", published).Error; err != nil {
+		return err
+	}
+
+	if published && !chart.Published {
+		s.webhooks.Emit(EventChartPublished, map[string]interface{}{
+			"chart_id": chart.ID,
+			"name":     chart.Name,
+			"repo_id":  chart.RepoID,
+		})
+	}
+	return nil
 }
 
 func (s *ChartService) CreateChart(chart *model.Chart) error {
@@ -88,6 +170,7 @@ type UploadChartRequest struct {
 	AppVersion    string
 	LocalPath     string
 	DefaultValues map[string]interface{}
+	ValuesSchema  string
 	Published     bool
 }
 
@@ -124,6 +207,7 @@ func (s *ChartService) CreateChartFromUpload(req UploadChartRequest) (*model.Cha
 			AppVersion:         req.AppVersion,
 			LocalPath:          req.LocalPath,
 			ChartDefaultValues: model.JSONMap(req.DefaultValues), // 存储原始 values
+			ValuesSchema:       req.ValuesSchema,
 			URLs:               model.StringArray{},
 		}
 
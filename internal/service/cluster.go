@@ -0,0 +1,265 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/your-org/app-market/internal/helm"
+	"github.com/your-org/app-market/internal/model"
+	"github.com/your-org/app-market/pkg/crypto"
+	"gorm.io/gorm"
+)
+
+// Cluster modes
+const (
+	ClusterModeKubeconfig = "kubeconfig"
+	ClusterModeInCluster  = "in_cluster"
+)
+
+// ErrClusterNotFound is returned when a cluster is not registered
+var ErrClusterNotFound = errors.New("cluster not found")
+
+type ClusterService struct {
+	db      *gorm.DB
+	cipher  *crypto.Cipher
+	factory *helm.Factory
+}
+
+func NewClusterService(db *gorm.DB, cipher *crypto.Cipher) *ClusterService {
+	return &ClusterService{
+		db:      db,
+		cipher:  cipher,
+		factory: helm.NewFactory(),
+	}
+}
+
+// ClusterInput registers or updates a cluster. On update, empty fields keep
+// their current value.
+type ClusterInput struct {
+	Name        string
+	Description string
+	Mode        string
+	Kubeconfig  string
+	Context     string
+	IsDefault   *bool
+}
+
+// CreateCluster registers a cluster. The kubeconfig is validated and stored
+// encrypted; the cluster does not need to be reachable yet.
+func (s *ClusterService) CreateCluster(input ClusterInput) (*model.Cluster, error) {
+	cluster := &model.Cluster{
+		Name:        input.Name,
+		Description: input.Description,
+		Mode:        input.Mode,
+		Context:     input.Context,
+	}
+	if cluster.Mode == "" {
+		cluster.Mode = ClusterModeKubeconfig
+	}
+	if input.IsDefault != nil {
+		cluster.IsDefault = *input.IsDefault
+	}
+
+	if err := s.setKubeconfig(cluster, input.Kubeconfig); err != nil {
+		return nil, err
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(cluster).Error; err != nil {
+			return err
+		}
+		return clearOtherDefaults(tx, cluster)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cluster: %w", err)
+	}
+	return cluster, nil
+}
+
+// UpdateCluster changes a cluster and drops its cached clients
+func (s *ClusterService) UpdateCluster(id uint, input ClusterInput) (*model.Cluster, error) {
+	cluster, err := s.GetCluster(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if input.Name != "" {
+		cluster.Name = input.Name
+	}
+	if input.Description != "" {
+		cluster.Description = input.Description
+	}
+	if input.Mode != "" {
+		cluster.Mode = input.Mode
+	}
+	if input.Context != "" {
+		cluster.Context = input.Context
+	}
+	if input.IsDefault != nil {
+		cluster.IsDefault = *input.IsDefault
+	}
+	// Switching to kubeconfig mode requires a kubeconfig
+	if input.Kubeconfig != "" || cluster.Mode == ClusterModeInCluster || cluster.Kubeconfig == "" {
+		if err := s.setKubeconfig(cluster, input.Kubeconfig); err != nil {
+			return nil, err
+		}
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(cluster).Error; err != nil {
+			return err
+		}
+		return clearOtherDefaults(tx, cluster)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update cluster: %w", err)
+	}
+
+	return cluster, nil
+}
+
+// setKubeconfig validates the connection settings for the cluster mode and
+// stores the kubeconfig encrypted
+func (s *ClusterService) setKubeconfig(cluster *model.Cluster, kubeconfig string) error {
+	switch cluster.Mode {
+	case ClusterModeInCluster:
+		cluster.Kubeconfig = ""
+		return nil
+	case ClusterModeKubeconfig:
+		if kubeconfig == "" {
+			return fmt.Errorf("kubeconfig is required")
+		}
+		src := helm.ClusterSource{Kubeconfig: []byte(kubeconfig), Context: cluster.Context}
+		if err := src.Validate(); err != nil {
+			return err
+		}
+		encrypted, err := s.cipher.Encrypt(kubeconfig)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt kubeconfig: %w", err)
+		}
+		cluster.Kubeconfig = encrypted
+		return nil
+	default:
+		return fmt.Errorf("invalid cluster mode: %s", cluster.Mode)
+	}
+}
+
+// clearOtherDefaults keeps at most one default cluster
+func clearOtherDefaults(tx *gorm.DB, cluster *model.Cluster) error {
+	if !cluster.IsDefault {
+		return nil
+	}
+	return tx.Model(&model.Cluster{}).Where("id <> ? AND is_default = ?", cluster.ID, true).Update("is_default", false).Error
+}
+
+// DeleteCluster removes a cluster that no longer has instances
+func (s *ClusterService) DeleteCluster(id uint) error {
+	var count int64
+	if err := s.db.Model(&model.AppInstance{}).Where("cluster_id = ?", id).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("cluster still has %d instances", count)
+	}
+
+	if err := s.db.Delete(&model.Cluster{}, id).Error; err != nil {
+		return fmt.Errorf("failed to delete cluster: %w", err)
+	}
+	s.factory.Invalidate(id)
+	return nil
+}
+
+// ListClusters returns all registered clusters
+func (s *ClusterService) ListClusters() ([]model.Cluster, error) {
+	clusters := []model.Cluster{}
+	err := s.db.Order("name").Find(&clusters).Error
+	return clusters, err
+}
+
+// GetCluster returns a registered cluster
+func (s *ClusterService) GetCluster(id uint) (*model.Cluster, error) {
+	var cluster model.Cluster
+	if err := s.db.First(&cluster, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrClusterNotFound
+		}
+		return nil, fmt.Errorf("failed to get cluster: %w", err)
+	}
+	return &cluster, nil
+}
+
+// TestCluster connects to a cluster and returns its Kubernetes version
+func (s *ClusterService) TestCluster(id uint) (string, error) {
+	cluster, err := s.GetCluster(id)
+	if err != nil {
+		return "", err
+	}
+	src, err := s.source(cluster)
+	if err != nil {
+		return "", err
+	}
+	return helm.ServerVersion(src)
+}
+
+// ResolveClusterID returns the cluster a deployment targets: the requested
+// one if set, else the default cluster, else 0 for the cluster of the
+// process' own kubeconfig.
+func (s *ClusterService) ResolveClusterID(requested uint) (uint, error) {
+	if requested != 0 {
+		if _, err := s.GetCluster(requested); err != nil {
+			return 0, err
+		}
+		return requested, nil
+	}
+
+	var cluster model.Cluster
+	err := s.db.Where("is_default = ?", true).First(&cluster).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get default cluster: %w", err)
+	}
+	return cluster.ID, nil
+}
+
+// ClusterIDs returns the IDs of all clusters, including 0 for the local one
+func (s *ClusterService) ClusterIDs() ([]uint, error) {
+	var ids []uint
+	if err := s.db.Model(&model.Cluster{}).Order("id").Pluck("id", &ids).Error; err != nil {
+		return nil, err
+	}
+	return append([]uint{0}, ids...), nil
+}
+
+// HelmClient returns a Helm client for a namespace of a cluster. The cluster
+// record is read every time so clients built from an outdated kubeconfig,
+// possibly changed by another replica, are replaced.
+func (s *ClusterService) HelmClient(clusterID uint, namespace string) (*helm.Client, error) {
+	if clusterID == 0 {
+		return s.factory.Client(0, time.Time{}, func() (helm.ClusterSource, error) {
+			return helm.ClusterSource{Local: true}, nil
+		}, namespace)
+	}
+
+	cluster, err := s.GetCluster(clusterID)
+	if err != nil {
+		return nil, err
+	}
+	return s.factory.Client(clusterID, cluster.UpdatedAt, func() (helm.ClusterSource, error) {
+		return s.source(cluster)
+	}, namespace)
+}
+
+func (s *ClusterService) source(cluster *model.Cluster) (helm.ClusterSource, error) {
+	if cluster.Mode == ClusterModeInCluster {
+		return helm.ClusterSource{InCluster: true}, nil
+	}
+
+	kubeconfig, err := s.cipher.Decrypt(cluster.Kubeconfig)
+	if err != nil {
+		return helm.ClusterSource{}, fmt.Errorf("failed to decrypt kubeconfig of cluster %s: %w", cluster.Name, err)
+	}
+	return helm.ClusterSource{Kubeconfig: []byte(kubeconfig), Context: cluster.Context}, nil
+}
@@ -2,8 +2,10 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"os"
 
@@ -12,15 +14,27 @@ import (
 	"gorm.io/gorm"
 )
 
+// ErrInstanceNotFound is returned when an instance does not exist or is not
+// visible to the user
+var ErrInstanceNotFound = errors.New("instance not found")
+
 type DeployService struct {
-	db           *gorm.DB
-	chartService *ChartService
+	db             *gorm.DB
+	chartService   *ChartService
+	clusterService *ClusterService
+	tenancyService *TenancyService
+	quotaService   *QuotaService
+	repoClient     *RepoClient
 }
 
-func NewDeployService(db *gorm.DB, chartService *ChartService) *DeployService {
+func NewDeployService(db *gorm.DB, chartService *ChartService, clusterService *ClusterService, tenancyService *TenancyService, quotaService *QuotaService, repoClient *RepoClient) *DeployService {
 	return &DeployService{
-		db:           db,
-		chartService: chartService,
+		db:             db,
+		chartService:   chartService,
+		clusterService: clusterService,
+		tenancyService: tenancyService,
+		quotaService:   quotaService,
+		repoClient:     repoClient,
 	}
 }
 
@@ -28,98 +42,656 @@ type DeployRequest struct {
 	UserID      string                 `json:"user_id"`
 	ChartID     string                 `json:"chart_id"`
 	Version     string                 `json:"version"`
+	ClusterID   uint                   `json:"cluster_id"` // Target cluster, resolved to the default cluster when 0
 	ReleaseName string                 `json:"release_name"`
 	Namespace   string                 `json:"namespace"`
 	UserValues  map[string]interface{} `json:"user_values"`
 	IsQuickMode bool                   `json:"is_quick_mode"` // If true, strictly enforce admin defaults
+	Wait        bool                   `json:"wait"`          // Wait until all resources are ready
+	Atomic      bool                   `json:"atomic"`        // Uninstall again if the release does not become ready, implies Wait
+	TaskID      uint                   `json:"task_id,omitempty"`
 }
 
-// Deploy orchestrates the deployment process
-func (s *DeployService) Deploy(ctx context.Context, req DeployRequest) (*model.AppInstance, error) {
-	// 1. 获取 ChartVersion (包含原始 values)
-	var chartVersion model.ChartVersion
-	err := s.db.Where("chart_id = ? AND version = ?", req.ChartID, req.Version).
-		Preload("Chart").
-		First(&chartVersion).Error
-	if err != nil {
-		return nil, fmt.Errorf("chart version not found: %w", err)
-	}
+// UpgradeRequest describes an in-place reconfiguration of a deployed instance
+type UpgradeRequest struct {
+	UserID      string                 `json:"user_id"`
+	InstanceID  uint                   `json:"instance_id"`
+	Version     string                 `json:"version"`     // Target chart version, empty keeps the current one
+	UserValues  map[string]interface{} `json:"user_values"` // nil keeps the instance's current overrides
+	Reset       bool                   `json:"reset"`       // Drop the current overrides when UserValues is nil
+	IsQuickMode bool                   `json:"is_quick_mode"`
+	Wait        bool                   `json:"wait"`   // Wait until all resources are ready
+	Atomic      bool                   `json:"atomic"` // Roll back if the upgrade does not become ready, implies Wait
+	TaskID      uint                   `json:"task_id,omitempty"`
+}
 
-	// 2. 获取 Admin 配置
-	meta, err := s.chartService.GetMetadata(req.ChartID, req.Version)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get chart metadata: %w", err)
-	}
+// RollbackRequest rolls an instance back to a previously recorded revision
+type RollbackRequest struct {
+	UserID     string `json:"user_id"`
+	InstanceID uint   `json:"instance_id"`
+	Revision   int    `json:"revision"` // Helm revision to roll back to, 0 means the previous one
+	TaskID     uint   `json:"task_id,omitempty"`
+}
 
-	// 3. 验证必填字段
-	if len(meta.RequiredKeys) > 0 {
-		if err := helm.ValidateRequiredKeys(req.UserValues, meta.RequiredKeys); err != nil {
-			return nil, fmt.Errorf("validation failed: %w", err)
-		}
-	}
+// ResyncRequest re-applies an instance's recorded configuration to the cluster
+type ResyncRequest struct {
+	InstanceID uint `json:"instance_id"`
+	TaskID     uint `json:"task_id,omitempty"`
+}
 
-	// 4. 三层合并 (修复 TODO)
-	chartDefaults := map[string]interface{}(chartVersion.ChartDefaultValues) // 从数据库读取
-	if chartDefaults == nil {
-		chartDefaults = make(map[string]interface{}) // 兼容旧数据
+// UninstallRequest removes a deployed instance
+type UninstallRequest struct {
+	UserID      string `json:"user_id"`
+	InstanceID  uint   `json:"instance_id"`
+	KeepHistory bool   `json:"keep_history"` // Keep Helm release history and instance revisions
+	TaskID      uint   `json:"task_id,omitempty"`
+}
+
+// Deploy orchestrates the deployment process
+func (s *DeployService) Deploy(ctx context.Context, req DeployRequest) (*model.AppInstance, error) {
+	// 0. 校验目标 namespace 归属
+	if err := s.tenancyService.CheckNamespace(req.UserID, req.ClusterID, req.Namespace); err != nil {
+		return nil, err
 	}
 
-	finalValues, err := helm.MergeValues(chartDefaults, meta.DefaultValues, req.UserValues)
+	// 1. 获取 Chart 与 Admin 配置, 校验并三层合并
+	chartVersion, finalValues, err := s.resolveValues(req.ChartID, req.Version, req.UserValues, req.IsQuickMode)
 	if err != nil {
-		return nil, fmt.Errorf("failed to merge values: %w", err)
+		return nil, err
 	}
+	reportStep(ctx, "chart_resolved", "Resolved chart %s version %s", req.ChartID, chartVersion.Version)
+	reportStep(ctx, "values_merged", "Merged and validated values")
 
-	// 5. 确定 Chart 路径 (优先本地,兼容远程)
-	chartPath := chartVersion.LocalPath
-	if chartPath == "" && len(chartVersion.URLs) > 0 {
-		// 从远程下载 (保持兼容现有同步流程)
-		chartPath, err = s.downloadChart(ctx, chartVersion.URLs[0])
-		if err != nil {
-			return nil, fmt.Errorf("failed to download chart: %w", err)
-		}
-		defer os.Remove(chartPath) // 清理临时文件
+	// 2. 确定 Chart 路径 (优先本地,兼容远程)
+	chartPath, cleanup, err := s.resolveChartPath(ctx, chartVersion)
+	if err != nil {
+		return nil, err
 	}
+	defer cleanup()
 
-	if chartPath == "" {
-		return nil, fmt.Errorf("no chart source available (neither LocalPath nor URLs)")
+	// 3. 配额校验 (CPU/内存按渲染后 manifest 的 requests 计算)
+	err = s.quotaService.Check(QuotaRequest{
+		UserID:    req.UserID,
+		ClusterID: req.ClusterID,
+		Namespace: req.Namespace,
+		ChartID:   req.ChartID,
+		Requests:  renderedRequests(ctx, req.ReleaseName, req.Namespace, chartPath, finalValues),
+	})
+	if err != nil {
+		return nil, err
 	}
+	reportStep(ctx, "quota_checked", "Quotas allow the deployment")
 
-	// 6. Helm 部署
-	helmClient, err := helm.NewClient(req.Namespace)
+	// 4. Helm 部署
+	helmClient, err := s.newHelmClient(ctx, req.ClusterID, req.Namespace)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create helm client: %w", err)
 	}
 
-	if err := helmClient.InstallChart(ctx, req.ReleaseName, chartPath, finalValues); err != nil {
+	reportStep(ctx, "install_started", "Installing release %s into namespace %s", req.ReleaseName, req.Namespace)
+	opts := helm.InstallOptions{Wait: req.Wait, Atomic: req.Atomic}
+	revision, err := helmClient.InstallChart(ctx, req.ReleaseName, chartPath, finalValues, opts)
+	if err != nil {
 		return nil, fmt.Errorf("helm deployment failed: %w", err)
 	}
+	reportStep(ctx, "install_finished", "Installed release %s revision %d", req.ReleaseName, revision)
 
-	// 7. 保存实例记录
+	// 5. 保存实例记录
 	instance := &model.AppInstance{
+		ClusterID:     req.ClusterID,
 		Name:          req.ReleaseName,
 		Namespace:     req.Namespace,
 		UserID:        req.UserID,
 		ChartID:       req.ChartID,
 		ChartVersion:  req.Version,
 		Status:        "deployed",
+		Health:        helm.HealthProgressing,
 		AppliedValues: model.JSONMap(finalValues),
+		UserValues:    model.JSONMap(req.UserValues),
+	}
+	s.recordRequests(helmClient, instance)
+	if opts.Wait || opts.Atomic {
+		if err := s.checkHealth(ctx, helmClient, instance); err != nil {
+			log.Printf("Failed to check health of release %s: %v", instance.Name, err)
+		}
 	}
 
-	if err := s.db.Create(instance).Error; err != nil {
+	// 再次校验配额并保存, 防止并发部署在安装期间共同超出配额
+	err = s.quotaService.Commit(QuotaRequest{
+		UserID:    req.UserID,
+		ClusterID: req.ClusterID,
+		Namespace: req.Namespace,
+		ChartID:   req.ChartID,
+		Requests:  instanceRequests(instance),
+	}, func(tx *gorm.DB) error {
+		if err := tx.Create(instance).Error; err != nil {
+			return err
+		}
+		return recordRevision(tx, instance, revision, "install", req.TaskID)
+	})
+	if errors.Is(err, ErrQuotaExceeded) {
+		// Another deployment used up the quota while this one was installing
+		reportStep(ctx, "uninstall_started", "Quota exceeded, uninstalling release %s", req.ReleaseName)
+		if uerr := helmClient.UninstallRelease(context.WithoutCancel(ctx), req.ReleaseName, false); uerr != nil {
+			log.Printf("Failed to uninstall release %s after exceeding a quota: %v", req.ReleaseName, uerr)
+		}
+		return nil, err
+	}
+	if err != nil {
 		return nil, fmt.Errorf("failed to save instance record: %w", err)
 	}
 
 	return instance, nil
 }
 
-// downloadChart 下载远程 Chart
-func (s *DeployService) downloadChart(ctx context.Context, url string) (string, error) {
-	resp, err := http.Get(url)
+// renderedRequests returns a QuotaRequest.Requests func computing the
+// resource requests of a chart rendered with values
+func renderedRequests(ctx context.Context, releaseName, namespace, chartPath string, values map[string]interface{}) func() (helm.ResourceRequests, error) {
+	return func() (helm.ResourceRequests, error) {
+		rendered, err := helm.RenderChart(ctx, releaseName, namespace, chartPath, values)
+		if err != nil {
+			return helm.ResourceRequests{}, err
+		}
+		return helm.ManifestRequests(rendered.Manifest), nil
+	}
+}
+
+// instanceRequests returns a QuotaRequest.Requests func reporting the
+// requests recorded on an instance
+func instanceRequests(instance *model.AppInstance) func() (helm.ResourceRequests, error) {
+	return func() (helm.ResourceRequests, error) {
+		return helm.ResourceRequests{CPUMillis: instance.CPURequest, MemoryBytes: instance.MemoryRequest}, nil
+	}
+}
+
+// PreviewResult is what a deployment would apply, rendered without touching the cluster
+type PreviewResult struct {
+	Values    map[string]interface{} `json:"values"`
+	Manifests map[string]string      `json:"manifests"`
+	Notes     string                 `json:"notes"`
+}
+
+// Preview runs the Deploy pipeline up to the Helm call and renders the chart
+// client-side instead of installing it. No AppInstance is created. The chart,
+// cluster and namespace are checked as for a deployment.
+func (s *DeployService) Preview(ctx context.Context, req DeployRequest, includeUnpublished bool) (*PreviewResult, error) {
+	if _, err := s.checkDeployTarget(req, includeUnpublished); err != nil {
+		return nil, err
+	}
+	chartVersion, finalValues, err := s.resolveValues(req.ChartID, req.Version, req.UserValues, req.IsQuickMode)
+	if err != nil {
+		return nil, err
+	}
+
+	chartPath, cleanup, err := s.resolveChartPath(ctx, chartVersion)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	rendered, err := helm.RenderChart(ctx, req.ReleaseName, req.Namespace, chartPath, finalValues)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PreviewResult{
+		Values:    finalValues,
+		Manifests: rendered.Manifests,
+		Notes:     rendered.Notes,
+	}, nil
+}
+
+// DiffResult describes how a proposed change differs from the deployed instance
+type DiffResult struct {
+	Values       helm.ValuesDiff `json:"values"`
+	ManifestDiff string          `json:"manifest_diff"` // unified diff against the deployed release
+}
+
+// Diff compares an instance's applied configuration and deployed manifests
+// with what an Upgrade using req would produce. Nothing is changed.
+func (s *DeployService) Diff(ctx context.Context, req UpgradeRequest) (*DiffResult, error) {
+	instance, err := s.GetInstance(fmt.Sprintf("%d", req.InstanceID), req.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	version := req.Version
+	if version == "" {
+		version = instance.ChartVersion
+	}
+
+	chartVersion, finalValues, err := s.resolveValues(instance.ChartID, version, upgradeValues(instance, req), req.IsQuickMode)
+	if err != nil {
+		return nil, err
+	}
+
+	chartPath, cleanup, err := s.resolveChartPath(ctx, chartVersion)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	rendered, err := helm.RenderChart(ctx, instance.Name, instance.Namespace, chartPath, finalValues)
+	if err != nil {
+		return nil, err
+	}
+
+	helmClient, err := s.newHelmClient(ctx, instance.ClusterID, instance.Namespace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create helm client: %w", err)
+	}
+
+	// Both sides include hooks, which the release manifest alone does not
+	currentManifests, err := helmClient.GetManifests(instance.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DiffResult{
+		Values:       helm.DiffValues(instance.AppliedValues, finalValues),
+		ManifestDiff: helm.DiffManifests(currentManifests, rendered.Manifests),
+	}, nil
+}
+
+// Upgrade re-applies configuration to an existing instance, optionally moving
+// it to another chart version. The instance record is only updated once Helm
+// reports success.
+func (s *DeployService) Upgrade(ctx context.Context, req UpgradeRequest) (*model.AppInstance, error) {
+	// 1. 获取实例
+	instance, err := s.GetInstance(fmt.Sprintf("%d", req.InstanceID), req.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	version := req.Version
+	if version == "" {
+		version = instance.ChartVersion
+	}
+
+	// 2. 针对目标版本重新校验并三层合并 (未指定 values 时沿用当前用户配置)
+	userValues := upgradeValues(instance, req)
+	chartVersion, finalValues, err := s.resolveValues(instance.ChartID, version, userValues, req.IsQuickMode)
+	if err != nil {
+		return nil, err
+	}
+	reportStep(ctx, "chart_resolved", "Resolved chart %s version %s", instance.ChartID, chartVersion.Version)
+	reportStep(ctx, "values_merged", "Merged and validated values")
+
+	chartPath, cleanup, err := s.resolveChartPath(ctx, chartVersion)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	// 3. 按新配置的 requests 校验配额, 实例原有的 requests 不重复计算
+	err = s.quotaService.Check(QuotaRequest{
+		UserID:     instance.UserID,
+		ClusterID:  instance.ClusterID,
+		Namespace:  instance.Namespace,
+		ChartID:    instance.ChartID,
+		InstanceID: instance.ID,
+		Requests:   renderedRequests(ctx, instance.Name, instance.Namespace, chartPath, finalValues),
+	})
+	if err != nil {
+		return nil, err
+	}
+	reportStep(ctx, "quota_checked", "Quotas allow the upgrade")
+
+	// 4. Helm 升级
+	helmClient, err := s.newHelmClient(ctx, instance.ClusterID, instance.Namespace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create helm client: %w", err)
+	}
+
+	reportStep(ctx, "upgrade_started", "Upgrading release %s", instance.Name)
+	opts := helm.InstallOptions{Wait: req.Wait, Atomic: req.Atomic}
+	revision, err := helmClient.UpgradeRelease(ctx, instance.Name, chartPath, finalValues, opts)
+	if err != nil {
+		return nil, fmt.Errorf("helm upgrade failed: %w", err)
+	}
+	reportStep(ctx, "upgrade_finished", "Upgraded release %s to revision %d", instance.Name, revision)
+
+	// 5. 成功后更新实例记录
+	instance.ChartVersion = version
+	instance.AppliedValues = model.JSONMap(finalValues)
+	instance.UserValues = model.JSONMap(userValues)
+	instance.Status = "deployed"
+	instance.Health = helm.HealthProgressing
+	s.recordRequests(helmClient, instance)
+	if opts.Wait || opts.Atomic {
+		if err := s.checkHealth(ctx, helmClient, instance); err != nil {
+			log.Printf("Failed to check health of release %s: %v", instance.Name, err)
+		}
+	}
+	if err := s.saveRevision(instance, revision, "upgrade", req.TaskID); err != nil {
+		return nil, err
+	}
+
+	return instance, nil
+}
+
+// Rollback restores an instance to the configuration of an earlier revision
+// using Helm's rollback action.
+func (s *DeployService) Rollback(ctx context.Context, req RollbackRequest) (*model.AppInstance, error) {
+	instance, err := s.GetInstance(fmt.Sprintf("%d", req.InstanceID), req.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 1. 查找目标版本记录 (默认回滚到上一个版本)
+	var target model.InstanceRevision
+	query := s.db.Where("instance_id = ?", instance.ID)
+	if req.Revision > 0 {
+		query = query.Where("revision = ?", req.Revision)
+	} else {
+		query = query.Order("revision DESC").Offset(1)
+	}
+	if err := query.First(&target).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, permanent(fmt.Errorf("revision not found"))
+		}
+		return nil, fmt.Errorf("failed to find revision: %w", err)
+	}
+
+	helmClient, err := s.newHelmClient(ctx, instance.ClusterID, instance.Namespace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create helm client: %w", err)
+	}
+
+	// 2. 按目标版本的 manifest 校验配额
+	err = s.quotaService.Check(QuotaRequest{
+		UserID:     instance.UserID,
+		ClusterID:  instance.ClusterID,
+		Namespace:  instance.Namespace,
+		ChartID:    instance.ChartID,
+		InstanceID: instance.ID,
+		Requests: func() (helm.ResourceRequests, error) {
+			manifest, err := helmClient.GetRevisionManifest(instance.Name, target.Revision)
+			if err != nil {
+				return helm.ResourceRequests{}, err
+			}
+			return helm.ManifestRequests(manifest), nil
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 3. Helm 回滚
+
+	reportStep(ctx, "rollback_started", "Rolling back release %s to revision %d", instance.Name, target.Revision)
+	revision, err := helmClient.Rollback(ctx, instance.Name, target.Revision)
+	if err != nil {
+		return nil, err
+	}
+	reportStep(ctx, "rollback_finished", "Rolled back release %s, new revision %d", instance.Name, revision)
+
+	// 4. 实例恢复为目标版本的配置
+	instance.ChartVersion = target.ChartVersion
+	instance.AppliedValues = target.AppliedValues
+	instance.UserValues = target.UserValues
+	instance.Status = "deployed"
+	instance.Health = helm.HealthProgressing
+	s.recordRequests(helmClient, instance)
+	if err := s.saveRevision(instance, revision, "rollback", req.TaskID); err != nil {
+		return nil, err
+	}
+
+	return instance, nil
+}
+
+// ListRevisions returns the revision history of an instance, newest first
+func (s *DeployService) ListRevisions(instanceID, userID string) ([]model.InstanceRevision, error) {
+	instance, err := s.GetInstance(instanceID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var revisions []model.InstanceRevision
+	if err := s.db.Where("instance_id = ?", instance.ID).Order("revision DESC").Find(&revisions).Error; err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
+
+// saveRevision persists the instance state together with its new revision entry
+func (s *DeployService) saveRevision(instance *model.AppInstance, revision int, action string, taskID uint) error {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(instance).Error; err != nil {
+			return err
+		}
+		return recordRevision(tx, instance, revision, action, taskID)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update instance record: %w", err)
+	}
+	return nil
+}
+
+func recordRevision(tx *gorm.DB, instance *model.AppInstance, revision int, action string, taskID uint) error {
+	return tx.Create(&model.InstanceRevision{
+		InstanceID:    instance.ID,
+		Revision:      revision,
+		Action:        action,
+		TaskID:        taskID,
+		ChartVersion:  instance.ChartVersion,
+		AppliedValues: instance.AppliedValues,
+		UserValues:    instance.UserValues,
+	}).Error
+}
+
+// upgradeValues returns the user values an upgrade applies: the requested
+// ones, none when resetting to the defaults, or else the instance's current
+// overrides
+func upgradeValues(instance *model.AppInstance, req UpgradeRequest) map[string]interface{} {
+	switch {
+	case req.UserValues != nil:
+		return req.UserValues
+	case req.Reset:
+		return nil
+	}
+	return instance.UserValues
+}
+
+// ValidateDeploy runs the values pipeline of Deploy without touching Helm so
+// that configuration errors can be reported before a task is queued. Only
+// admins may deploy unpublished charts.
+func (s *DeployService) ValidateDeploy(req DeployRequest, includeUnpublished bool) error {
+	clusterID, err := s.checkDeployTarget(req, includeUnpublished)
+	if err != nil {
+		return err
+	}
+	// Resource budgets need the rendered chart and are checked by Deploy
+	err = s.quotaService.Check(QuotaRequest{UserID: req.UserID, ClusterID: clusterID, Namespace: req.Namespace, ChartID: req.ChartID})
+	if err != nil {
+		return err
+	}
+	_, _, err = s.resolveValues(req.ChartID, req.Version, req.UserValues, req.IsQuickMode)
+	return err
+}
+
+// checkDeployTarget checks that the chart is published unless
+// includeUnpublished is set, that the cluster exists and that the user may
+// use the namespace on it. It returns the resolved cluster ID.
+func (s *DeployService) checkDeployTarget(req DeployRequest, includeUnpublished bool) (uint, error) {
+	var chart model.Chart
+	if err := s.db.First(&chart, "id = ?", req.ChartID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, ErrChartNotFound
+		}
+		return 0, fmt.Errorf("failed to find chart: %w", err)
+	}
+	if !chart.Published && !includeUnpublished {
+		return 0, ErrChartNotFound
+	}
+
+	clusterID, err := s.clusterService.ResolveClusterID(req.ClusterID)
+	if err != nil {
+		return 0, err
+	}
+	if err := s.tenancyService.CheckNamespace(req.UserID, clusterID, req.Namespace); err != nil {
+		return 0, err
+	}
+	return clusterID, nil
+}
+
+// ValidateUpgrade is the Upgrade counterpart of ValidateDeploy
+func (s *DeployService) ValidateUpgrade(req UpgradeRequest) error {
+	instance, err := s.GetInstance(fmt.Sprintf("%d", req.InstanceID), req.UserID)
+	if err != nil {
+		return err
+	}
+
+	version := req.Version
+	if version == "" {
+		version = instance.ChartVersion
+	}
+
+	_, _, err = s.resolveValues(instance.ChartID, version, upgradeValues(instance, req), req.IsQuickMode)
+	return err
+}
+
+// resolveValues loads the chart version and its admin configuration, enforces
+// fixed/visible/required keys on the user values, merges them and checks the
+// result against the chart schema and admin constraints.
+// Policy and schema violations are reported as *helm.ValidationError.
+func (s *DeployService) resolveValues(chartID, version string, userValues map[string]interface{}, quickMode bool) (*model.ChartVersion, map[string]interface{}, error) {
+	// 获取 ChartVersion (包含原始 values)
+	chartVersion, err := s.getChartVersion(chartID, version)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// 获取 Admin 配置
+	meta, err := s.chartService.GetMetadata(chartID, version)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get chart metadata: %w", err)
+	}
+
+	// 固定字段与不可见字段: 快速模式下 Admin 默认值全部视为固定
+	fixedKeys := []string(meta.FixedKeys)
+	if quickMode {
+		for key := range helm.FlattenValues(meta.DefaultValues) {
+			fixedKeys = append(fixedKeys, key)
+		}
+	}
+
+	if restricted := helm.RestrictedKeys(userValues, meta.VisibleKeys, fixedKeys); len(restricted) > 0 {
+		if meta.ValuesPolicy == "strip" {
+			paths := make([]string, len(restricted))
+			for i, f := range restricted {
+				paths[i] = f.Path
+			}
+			userValues = helm.RemoveKeys(userValues, paths)
+		} else {
+			return nil, nil, &helm.ValidationError{Message: "values not allowed", Fields: restricted}
+		}
+	}
+
+	// 验证必填字段
+	if len(meta.RequiredKeys) > 0 {
+		if err := helm.ValidateRequiredKeys(userValues, meta.RequiredKeys); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// 三层合并
+	chartDefaults := map[string]interface{}(chartVersion.ChartDefaultValues) // 从数据库读取
+	if chartDefaults == nil {
+		chartDefaults = make(map[string]interface{}) // 兼容旧数据
+	}
+
+	finalValues, err := helm.MergeValues(chartDefaults, meta.DefaultValues, userValues)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to merge values: %w", err)
+	}
+
+	// 按 values.schema.json 与 Admin 约束校验最终 values
+	schemaErrors, err := helm.ValidateSchema(finalValues, []byte(chartVersion.ValuesSchema))
+	if err != nil {
+		return nil, nil, err
+	}
+	constraintErrors, err := helm.ValidateConstraints(finalValues, meta.Constraints)
+	if err != nil {
+		return nil, nil, err
+	}
+	if fields := append(schemaErrors, constraintErrors...); len(fields) > 0 {
+		return nil, nil, &helm.ValidationError{Message: "values do not match schema", Fields: fields}
+	}
+
+	return chartVersion, finalValues, nil
+}
+
+// getChartVersion loads a chart version together with its original values
+func (s *DeployService) getChartVersion(chartID, version string) (*model.ChartVersion, error) {
+	var chartVersion model.ChartVersion
+	if err := s.db.Where("chart_id = ? AND version = ?", chartID, version).First(&chartVersion).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: %s %s", ErrChartVersionNotFound, chartID, version)
+		}
+		return nil, fmt.Errorf("failed to get chart version: %w", err)
+	}
+	return &chartVersion, nil
+}
+
+// resolveChartPath returns a local path to the chart archive, downloading it
+// if necessary. The returned cleanup func must always be called.
+func (s *DeployService) resolveChartPath(ctx context.Context, chartVersion *model.ChartVersion) (string, func(), error) {
+	if chartVersion.LocalPath != "" {
+		return chartVersion.LocalPath, func() {}, nil
+	}
+
+	if len(chartVersion.URLs) == 0 {
+		return "", func() {}, fmt.Errorf("no chart source available (neither LocalPath nor URLs)")
+	}
+
+	// 从远程下载 (保持兼容现有同步流程)
+	reportStep(ctx, "download_started", "Downloading chart from %s", chartVersion.URLs[0])
+	chartPath, err := s.downloadChart(ctx, chartVersion)
+	if err != nil {
+		return "", func() {}, fmt.Errorf("failed to download chart: %w", err)
+	}
+	reportStep(ctx, "download_finished", "Downloaded chart")
+
+	// 清理临时文件
+	return chartPath, func() { os.Remove(chartPath) }, nil
+}
+
+// newHelmClient creates a Helm client for a cluster whose action log is
+// forwarded to the progress receiver of ctx, if any
+func (s *DeployService) newHelmClient(ctx context.Context, clusterID uint, namespace string) (*helm.Client, error) {
+	client, err := s.clusterService.HelmClient(clusterID, namespace)
+	if err != nil {
+		return nil, err
+	}
+	if logger := helmLogger(ctx); logger != nil {
+		client.SetLogger(logger)
+	}
+	return client, nil
+}
+
+// downloadChart 下载远程 Chart, 使用所属仓库的认证和 TLS 配置
+func (s *DeployService) downloadChart(ctx context.Context, chartVersion *model.ChartVersion) (string, error) {
+	// Versions created by hand may not belong to a synced repo
+	var chartRepo *model.ChartRepo
+	var chart model.Chart
+	if err := s.db.First(&chart, chartVersion.ChartID).Error; err == nil {
+		var repo model.ChartRepo
+		if err := s.db.First(&repo, chart.RepoID).Error; err == nil {
+			chartRepo = &repo
+		}
+	}
+
+	resp, err := s.repoClient.Get(ctx, chartRepo, chartVersion.URLs[0], nil)
 	if err != nil {
 		return "", err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("status %d", resp.StatusCode)
+	}
+
 	tempFile, err := os.CreateTemp("", "chart-*.tgz")
 	if err != nil {
 		return "", err
@@ -135,39 +707,320 @@ func (s *DeployService) downloadChart(ctx context.Context, url string) (string,
 	return tempFile.Name(), nil
 }
 
-// ListInstances retrieves all instances for a user
-func (s *DeployService) ListInstances(userID string) ([]model.AppInstance, error) {
+// recordRequests stores the resource requests of the deployed release on the
+// instance so they count against quotas. The instance is not saved.
+func (s *DeployService) recordRequests(helmClient *helm.Client, instance *model.AppInstance) {
+	manifest, err := helmClient.GetManifest(instance.Name)
+	if err != nil {
+		log.Printf("Failed to get manifest of release %s: %v", instance.Name, err)
+		return
+	}
+	requests := helm.ManifestRequests(manifest)
+	instance.CPURequest = requests.CPUMillis
+	instance.MemoryRequest = requests.MemoryBytes
+	instance.RequestsRecorded = true
+}
+
+// ListInstances retrieves the instances of a user. Unless ownOnly is set, it
+// also includes every instance in the namespaces granted to the user.
+func (s *DeployService) ListInstances(userID string, ownOnly bool) ([]model.AppInstance, error) {
+	query := s.db.Where("user_id = ?", userID)
+	if !ownOnly {
+		var err error
+		if query, err = s.tenancyService.scopeInstances(s.db, userID); err != nil {
+			return nil, err
+		}
+	}
+
 	var instances []model.AppInstance
-	if err := s.db.Where("user_id = ?", userID).Find(&instances).Error; err != nil {
+	if err := query.Find(&instances).Error; err != nil {
 		return nil, err
 	}
 	return instances, nil
 }
 
-// DeleteInstance deletes an instance by ID and userID
-func (s *DeployService) DeleteInstance(instanceID, userID string) error {
+// GetInstance retrieves an instance by ID if the user owns it or it lives in
+// a namespace granted to the user, the same scope ListInstances shows
+func (s *DeployService) GetInstance(instanceID, userID string) (*model.AppInstance, error) {
+	query, err := s.tenancyService.scopeInstances(s.db.Where("id = ?", instanceID), userID)
+	if err != nil {
+		return nil, err
+	}
+
 	var instance model.AppInstance
-	if err := s.db.Where("id = ? AND user_id = ?", instanceID, userID).First(&instance).Error; err != nil {
+	if err := query.First(&instance).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
-			return fmt.Errorf("instance not found")
+			return nil, ErrInstanceNotFound
+		}
+		return nil, fmt.Errorf("failed to find instance: %w", err)
+	}
+	return &instance, nil
+}
+
+// Resync re-applies the recorded chart version and AppliedValues of an
+// instance to the cluster, reinstalling the release if it is gone. The values
+// are applied as recorded, without re-validating them.
+func (s *DeployService) Resync(ctx context.Context, req ResyncRequest) (*model.AppInstance, error) {
+	var instance model.AppInstance
+	if err := s.db.First(&instance, req.InstanceID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInstanceNotFound
 		}
-		return fmt.Errorf("failed to find instance: %w", err)
+		return nil, fmt.Errorf("failed to find instance: %w", err)
+	}
+
+	chartVersion, err := s.getChartVersion(instance.ChartID, instance.ChartVersion)
+	if err != nil {
+		return nil, err
+	}
+	reportStep(ctx, "chart_resolved", "Resolved chart %s version %s", instance.ChartID, chartVersion.Version)
+
+	chartPath, cleanup, err := s.resolveChartPath(ctx, chartVersion)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	// The recorded values may request more than the instance currently counts
+	err = s.quotaService.Check(QuotaRequest{
+		UserID:     instance.UserID,
+		ClusterID:  instance.ClusterID,
+		Namespace:  instance.Namespace,
+		ChartID:    instance.ChartID,
+		InstanceID: instance.ID,
+		Requests:   renderedRequests(ctx, instance.Name, instance.Namespace, chartPath, instance.AppliedValues),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	helmClient, err := s.newHelmClient(ctx, instance.ClusterID, instance.Namespace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create helm client: %w", err)
+	}
+
+	state, err := helmClient.ReleaseState(instance.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	var revision int
+	if state == "" || state == "uninstalled" {
+		reportStep(ctx, "install_started", "Reinstalling release %s", instance.Name)
+		revision, err = helmClient.InstallChart(ctx, instance.Name, chartPath, instance.AppliedValues, helm.InstallOptions{Replace: state != ""})
+	} else {
+		reportStep(ctx, "upgrade_started", "Re-applying values to release %s", instance.Name)
+		revision, err = helmClient.UpgradeRelease(ctx, instance.Name, chartPath, instance.AppliedValues, helm.InstallOptions{})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("helm resync failed: %w", err)
+	}
+	reportStep(ctx, "install_finished", "Release %s is at revision %d", instance.Name, revision)
+
+	instance.Status = "deployed"
+	instance.Health = helm.HealthProgressing
+	s.recordRequests(helmClient, &instance)
+	if err := s.saveRevision(&instance, revision, "resync", req.TaskID); err != nil {
+		return nil, err
+	}
+	return &instance, nil
+}
+
+// AdoptRequest takes over a Helm release installed outside the market
+type AdoptRequest struct {
+	ClusterID   uint
+	Namespace   string
+	ReleaseName string
+	UserID      string // Username of the owner of the new instance
+	ChartID     string // Catalog chart to link, empty to match by chart name
+}
+
+// AdoptResult is the adopted instance and how its chart was matched
+type AdoptResult struct {
+	Instance       *model.AppInstance `json:"instance"`
+	ChartMatched   bool               `json:"chart_matched"`   // A catalog chart of the release's chart name was linked
+	VersionMatched bool               `json:"version_matched"` // The catalog has the release's chart version
+}
+
+// Adopt creates an instance for an existing Helm release. The release's user
+// supplied values become AppliedValues. The chart is matched to the catalog
+// by name, preferring a chart that has the release's version; without a match
+// the instance is kept as unmatched (empty ChartID) and cannot be upgraded
+// until a chart is linked.
+func (s *DeployService) Adopt(ctx context.Context, req AdoptRequest) (*AdoptResult, error) {
+	// Instances are owned by username, like everything keyed by the token subject
+	if err := s.db.Where("username = ?", req.UserID).First(&model.User{}).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("owner not found")
+		}
+		return nil, fmt.Errorf("failed to get owner: %w", err)
+	}
+
+	var count int64
+	s.db.Model(&model.AppInstance{}).Where("cluster_id = ? AND namespace = ? AND name = ?", req.ClusterID, req.Namespace, req.ReleaseName).Count(&count)
+	if count > 0 {
+		return nil, fmt.Errorf("release %s/%s is already managed", req.Namespace, req.ReleaseName)
+	}
+
+	helmClient, err := s.newHelmClient(ctx, req.ClusterID, req.Namespace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create helm client: %w", err)
+	}
+	release, err := helmClient.GetRelease(req.ReleaseName)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &AdoptResult{}
+	chartID := req.ChartID
+	if chartID != "" {
+		// An explicit chart is linked even if its name differs from the
+		// release's, but is only reported as matched if it does not
+		var chart model.Chart
+		if err := s.db.First(&chart, "id = ?", chartID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, fmt.Errorf("%w: %s", ErrChartNotFound, chartID)
+			}
+			return nil, fmt.Errorf("failed to get chart: %w", err)
+		}
+		result.ChartMatched = chart.Name == release.Chart
+	} else {
+		chartID, err = s.matchChart(release.Chart, release.ChartVersion)
+		if err != nil {
+			return nil, err
+		}
+		result.ChartMatched = chartID != ""
+	}
+	if chartID != "" {
+		if _, err := s.getChartVersion(chartID, release.ChartVersion); err == nil {
+			result.VersionMatched = true
+		}
+	}
+
+	instance := &model.AppInstance{
+		ClusterID:     req.ClusterID,
+		Name:          release.Name,
+		Namespace:     req.Namespace,
+		UserID:        req.UserID,
+		ChartID:       chartID,
+		ChartVersion:  release.ChartVersion,
+		Adopted:       true,
+		ChartName:     release.Chart,
+		Status:        "deployed",
+		Health:        helm.HealthProgressing,
+		AppliedValues: model.JSONMap(release.Values),
+		UserValues:    model.JSONMap(release.Values),
+	}
+	s.recordRequests(helmClient, instance)
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(instance).Error; err != nil {
+			return err
+		}
+		return recordRevision(tx, instance, release.Revision, "adopt", 0)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to save instance record: %w", err)
+	}
+
+	result.Instance = instance
+	return result, nil
+}
+
+// matchChart finds the catalog chart a release was installed from by chart
+// name, preferring one that has the given version. It returns "" if there is
+// no chart of that name.
+func (s *DeployService) matchChart(name, version string) (string, error) {
+	var charts []model.Chart
+	if err := s.db.Where("name = ?", name).Order("id").Find(&charts).Error; err != nil {
+		return "", fmt.Errorf("failed to match chart: %w", err)
+	}
+	if len(charts) == 0 {
+		return "", nil
+	}
+
+	for _, chart := range charts {
+		var count int64
+		s.db.Model(&model.ChartVersion{}).Where("chart_id = ? AND version = ?", chart.ID, version).Count(&count)
+		if count > 0 {
+			return fmt.Sprintf("%d", chart.ID), nil
+		}
+	}
+	return fmt.Sprintf("%d", charts[0].ID), nil
+}
+
+// InstanceDetail is an instance enriched with the live state of its Helm release
+type InstanceDetail struct {
+	*model.AppInstance
+	Release      *helm.ReleaseDetail `json:"release,omitempty"`
+	ReleaseError string              `json:"release_error,omitempty"` // Why the release could not be inspected
+}
+
+// GetInstanceDetail returns an instance together with its release revision,
+// NOTES, owned objects and access endpoints. If the cluster cannot be reached
+// the stored instance is still returned, with ReleaseError set.
+func (s *DeployService) GetInstanceDetail(ctx context.Context, instanceID, userID string) (*InstanceDetail, error) {
+	instance, err := s.GetInstance(instanceID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	detail := &InstanceDetail{AppInstance: instance}
+
+	helmClient, err := s.clusterService.HelmClient(instance.ClusterID, instance.Namespace)
+	if err != nil {
+		detail.ReleaseError = fmt.Sprintf("failed to create helm client: %v", err)
+		return detail, nil
+	}
+	helmClient.SetLogger(func(string, ...interface{}) {})
+
+	release, err := helmClient.ReleaseDetail(ctx, instance.Name)
+	if err != nil {
+		detail.ReleaseError = err.Error()
+		return detail, nil
+	}
+	detail.Release = release
+	return detail, nil
+}
+
+// Uninstall removes an instance's Helm release and then its database record.
+// Unless KeepHistory is set the revision history is purged as well.
+func (s *DeployService) Uninstall(ctx context.Context, req UninstallRequest) error {
+	instance, err := s.GetInstance(fmt.Sprintf("%d", req.InstanceID), req.UserID)
+	if err != nil {
+		return err
+	}
+
+	// A retried attempt may find the instance marked as failed
+	if instance.Status != "uninstalling" {
+		s.db.Model(instance).Update("status", "uninstalling")
 	}
 
 	// Delete from Kubernetes
-	helmClient, err := helm.NewClient(instance.Namespace)
+	helmClient, err := s.newHelmClient(ctx, instance.ClusterID, instance.Namespace)
 	if err != nil {
 		return fmt.Errorf("failed to create helm client: %w", err)
 	}
 
-	if err := helmClient.UninstallRelease(instance.Name); err != nil {
+	reportStep(ctx, "uninstall_started", "Uninstalling release %s", instance.Name)
+	if err := helmClient.UninstallRelease(ctx, instance.Name, req.KeepHistory); err != nil {
+		s.db.Model(instance).Update("status", "uninstall_failed")
 		return fmt.Errorf("failed to uninstall helm release: %w", err)
 	}
+	reportStep(ctx, "uninstall_finished", "Uninstalled release %s", instance.Name)
 
-	// Delete from database
-	if err := s.db.Delete(&instance).Error; err != nil {
-		return fmt.Errorf("failed to delete instance from database: %w", err)
-	}
-
-	return nil
+	// Delete from database. Without history the row is removed for good so
+	// the release name can be deployed again.
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if !req.KeepHistory {
+			if err := tx.Where("instance_id = ?", instance.ID).Delete(&model.InstanceRevision{}).Error; err != nil {
+				return fmt.Errorf("failed to purge instance history: %w", err)
+			}
+			tx = tx.Unscoped()
+		}
+		if err := tx.Delete(instance).Error; err != nil {
+			return fmt.Errorf("failed to delete instance from database: %w", err)
+		}
+		return nil
+	})
 }
@@ -0,0 +1,44 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/your-org/app-market/internal/config"
+	"github.com/your-org/app-market/internal/model"
+)
+
+func TestPreviewChecksChartAndNamespace(t *testing.T) {
+	db := newTestDB(t)
+	tenancy := NewTenancyService(db, config.TenancyConfig{DeniedNamespaces: []string{"kube-*"}})
+	deploy := NewDeployService(db, NewChartService(db, nil), NewClusterService(db, nil), tenancy,
+		NewQuotaService(db, config.QuotaConfig{}), nil)
+
+	draft := &model.Chart{RepoID: 1, Name: "draft"}
+	if err := db.Create(draft).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&model.ChartMetadata{ChartID: "1", Version: "1.0.0", DefaultValues: model.JSONMap{"password": "admin-only"}}).Error; err != nil {
+		t.Fatal(err)
+	}
+	req := DeployRequest{UserID: "alice", ChartID: "1", Version: "1.0.0", ReleaseName: "web", Namespace: "team-a"}
+
+	// The admin defaults of an unpublished chart must not leak to users
+	if _, err := deploy.Preview(context.Background(), req, false); !errors.Is(err, ErrChartNotFound) {
+		t.Errorf("Preview of an unpublished chart: got %v, want ErrChartNotFound", err)
+	}
+
+	if err := db.Model(draft).Update("published", true).Error; err != nil {
+		t.Fatal(err)
+	}
+	req.Namespace = "kube-system"
+	if _, err := deploy.Preview(context.Background(), req, false); !errors.Is(err, ErrNamespaceForbidden) {
+		t.Errorf("Preview into a denied namespace: got %v, want ErrNamespaceForbidden", err)
+	}
+	req.Namespace = "team-a"
+	req.ClusterID = 7
+	if _, err := deploy.Preview(context.Background(), req, false); !errors.Is(err, ErrClusterNotFound) {
+		t.Errorf("Preview on an unknown cluster: got %v, want ErrClusterNotFound", err)
+	}
+}
@@ -0,0 +1,435 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/your-org/app-market/internal/config"
+	"github.com/your-org/app-market/internal/helm"
+	"github.com/your-org/app-market/internal/model"
+	"gorm.io/gorm"
+)
+
+// Drift types
+const (
+	DriftUnmanagedRelease = "unmanaged_release" // Helm release without an AppInstance
+	DriftMissingRelease   = "missing_release"   // AppInstance whose release is gone
+	DriftValuesDrift      = "values_drift"      // Live values differ from AppliedValues
+)
+
+type DriftService struct {
+	db            *gorm.DB
+	deployService *DeployService
+	taskService   *TaskService
+	cfg           config.ReconcileConfig
+}
+
+func NewDriftService(db *gorm.DB, ds *DeployService, ts *TaskService, cfg config.ReconcileConfig) *DriftService {
+	return &DriftService{
+		db:            db,
+		deployService: ds,
+		taskService:   ts,
+		cfg:           cfg,
+	}
+}
+
+// driftReconcilerLease is held by the process scanning for drift
+const driftReconcilerLease = "drift_reconciler"
+
+// StartReconciler scans for drift periodically until ctx is done. Like the
+// status reconciler it runs in every replica, but only the holder of the
+// drift reconciler lease scans.
+func (s *DriftService) StartReconciler(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	owner := processID()
+	for {
+		held, err := acquireLease(s.db, driftReconcilerLease, owner, 3*interval)
+		if err != nil {
+			log.Printf("Failed to acquire drift reconciler lease: %v", err)
+		} else if held {
+			if err := s.Scan(ctx); err != nil {
+				log.Printf("Drift scan failed: %v", err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// scanTarget is a namespace of a cluster scanned for drift
+type scanTarget struct {
+	ClusterID uint
+	Namespace string
+}
+
+// scanTargets are the namespaces holding instances plus the configured
+// namespaces of every cluster
+func (s *DriftService) scanTargets() ([]scanTarget, error) {
+	var targets []scanTarget
+	if err := s.db.Model(&model.AppInstance{}).Distinct("cluster_id", "namespace").Find(&targets).Error; err != nil {
+		return nil, err
+	}
+
+	clusterIDs, err := s.deployService.clusterService.ClusterIDs()
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[scanTarget]bool)
+	for _, t := range targets {
+		seen[t] = true
+	}
+	for _, clusterID := range clusterIDs {
+		for _, ns := range s.cfg.ManagedNamespaces {
+			t := scanTarget{ClusterID: clusterID, Namespace: ns}
+			if !seen[t] {
+				seen[t] = true
+				targets = append(targets, t)
+			}
+		}
+	}
+
+	sort.Slice(targets, func(i, j int) bool {
+		if targets[i].ClusterID != targets[j].ClusterID {
+			return targets[i].ClusterID < targets[j].ClusterID
+		}
+		return targets[i].Namespace < targets[j].Namespace
+	})
+	return targets, nil
+}
+
+// Scan compares the Helm releases of every managed namespace with the
+// instance records and stores the differences. Releases with a pending or
+// running task are skipped since they are expected to differ for a while.
+func (s *DriftService) Scan(ctx context.Context) error {
+	targets, err := s.scanTargets()
+	if err != nil {
+		return fmt.Errorf("failed to list managed namespaces: %w", err)
+	}
+
+	for _, target := range targets {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err := s.scanNamespace(target); err != nil {
+			// Leave the previous findings in place rather than reporting
+			// every instance as missing because the cluster was unreachable
+			log.Printf("Drift scan of namespace %s in cluster %d failed: %v", target.Namespace, target.ClusterID, err)
+		}
+	}
+	return nil
+}
+
+func (s *DriftService) scanNamespace(target scanTarget) error {
+	helmClient, err := s.deployService.clusterService.HelmClient(target.ClusterID, target.Namespace)
+	if err != nil {
+		return err
+	}
+	helmClient.SetLogger(func(string, ...interface{}) {})
+
+	releases, err := helmClient.ListReleases()
+	if err != nil {
+		return err
+	}
+
+	var instances []model.AppInstance
+	if err := s.db.Where("cluster_id = ? AND namespace = ?", target.ClusterID, target.Namespace).Find(&instances).Error; err != nil {
+		return err
+	}
+
+	busy, err := s.busyReleases(target)
+	if err != nil {
+		return err
+	}
+
+	byName := make(map[string]*model.AppInstance)
+	for i := range instances {
+		byName[instances[i].Name] = &instances[i]
+	}
+
+	var found []model.DriftItem
+	released := make(map[string]bool)
+	for _, release := range releases {
+		released[release.Name] = true
+		if busy[release.Name] {
+			continue
+		}
+
+		instance, ok := byName[release.Name]
+		if !ok {
+			found = append(found, releaseDrift(DriftUnmanagedRelease, target.ClusterID, release, nil))
+			continue
+		}
+		if instance.Status != "deployed" {
+			continue
+		}
+
+		diff := helm.DiffValues(normalizeValues(instance.AppliedValues), normalizeValues(release.Values))
+		if !diff.Empty() {
+			item := releaseDrift(DriftValuesDrift, target.ClusterID, release, &instance.ID)
+			item.Details = toJSONMap(diff)
+			found = append(found, item)
+		}
+	}
+
+	for _, instance := range instances {
+		if released[instance.Name] || busy[instance.Name] {
+			continue
+		}
+		if instance.Status == "pending" || instance.Status == "uninstalling" {
+			continue
+		}
+		id := instance.ID
+		found = append(found, model.DriftItem{
+			Type:        DriftMissingRelease,
+			ClusterID:   target.ClusterID,
+			Namespace:   target.Namespace,
+			ReleaseName: instance.Name,
+			InstanceID:  &id,
+		})
+	}
+
+	return s.saveFindings(target, found)
+}
+
+// busyReleases returns the releases of a namespace with a pending or running task
+func (s *DriftService) busyReleases(target scanTarget) (map[string]bool, error) {
+	prefix := lockKey(target.ClusterID, target.Namespace, "")
+
+	var keys []string
+	err := s.db.Model(&model.Task{}).
+		Where("status IN ? AND lock_key LIKE ?", []string{"pending", "running"}, prefix+"%").
+		Pluck("lock_key", &keys).Error
+	if err != nil {
+		return nil, err
+	}
+
+	busy := make(map[string]bool)
+	for _, key := range keys {
+		busy[strings.TrimPrefix(key, prefix)] = true
+	}
+	return busy, nil
+}
+
+func releaseDrift(driftType string, clusterID uint, release helm.ReleaseSummary, instanceID *uint) model.DriftItem {
+	return model.DriftItem{
+		Type:          driftType,
+		ClusterID:     clusterID,
+		Namespace:     release.Namespace,
+		ReleaseName:   release.Name,
+		InstanceID:    instanceID,
+		Revision:      release.Revision,
+		ReleaseStatus: release.Status,
+		Chart:         release.Chart,
+		ChartVersion:  release.ChartVersion,
+	}
+}
+
+// saveFindings upserts the drift found in a namespace and removes items that
+// were resolved since the last scan. An ignored item stays ignored as long as
+// the same kind of drift is found for its release.
+func (s *DriftService) saveFindings(target scanTarget, found []model.DriftItem) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var existing []model.DriftItem
+		if err := tx.Where("cluster_id = ? AND namespace = ?", target.ClusterID, target.Namespace).Find(&existing).Error; err != nil {
+			return err
+		}
+		previous := make(map[string]model.DriftItem)
+		for _, item := range existing {
+			previous[item.ReleaseName] = item
+		}
+
+		for i := range found {
+			item := &found[i]
+			if prev, ok := previous[item.ReleaseName]; ok {
+				item.ID = prev.ID
+				item.CreatedAt = prev.CreatedAt
+				item.Ignored = prev.Ignored && prev.Type == item.Type
+				delete(previous, item.ReleaseName)
+			}
+			if err := tx.Save(item).Error; err != nil {
+				return err
+			}
+		}
+
+		for _, stale := range previous {
+			if err := tx.Delete(&stale).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// normalizeValues round-trips values through JSON so values read from the
+// database and from Helm compare equal regardless of their Go types
+func normalizeValues(values map[string]interface{}) map[string]interface{} {
+	normalized := make(map[string]interface{})
+	if b, err := json.Marshal(values); err == nil {
+		json.Unmarshal(b, &normalized)
+	}
+	return normalized
+}
+
+func toJSONMap(v interface{}) model.JSONMap {
+	var m map[string]interface{}
+	if b, err := json.Marshal(v); err == nil {
+		json.Unmarshal(b, &m)
+	}
+	return model.JSONMap(m)
+}
+
+// ListDrift returns the current drift items, optionally of one type.
+// Ignored items are only included on request.
+func (s *DriftService) ListDrift(driftType string, includeIgnored bool) ([]model.DriftItem, error) {
+	items := []model.DriftItem{}
+	query := s.db.Order("cluster_id, namespace, release_name")
+	if driftType != "" {
+		query = query.Where("type = ?", driftType)
+	}
+	if !includeIgnored {
+		query = query.Where("ignored = ?", false)
+	}
+	err := query.Find(&items).Error
+	return items, err
+}
+
+// GetItem returns a drift item
+func (s *DriftService) GetItem(id uint) (*model.DriftItem, error) {
+	var item model.DriftItem
+	if err := s.db.First(&item, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("drift item not found")
+		}
+		return nil, err
+	}
+	return &item, nil
+}
+
+// ErrDriftAction is returned when an action does not apply to a drift type
+var ErrDriftAction = errors.New("action not applicable to this drift type")
+
+// AdoptInput assigns an adopted release to a user and, optionally, a chart
+type AdoptInput struct {
+	UserID  string
+	ChartID string
+}
+
+// Adopt resolves drift by accepting the cluster state: an unmanaged release
+// becomes an instance owned by input.UserID, and for values drift the live
+// values replace AppliedValues.
+func (s *DriftService) Adopt(ctx context.Context, id uint, input AdoptInput) (*model.AppInstance, error) {
+	item, err := s.GetItem(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var instance *model.AppInstance
+	switch item.Type {
+	case DriftUnmanagedRelease:
+		if input.UserID == "" {
+			return nil, fmt.Errorf("user_id is required to adopt a release")
+		}
+		result, err := s.deployService.Adopt(ctx, AdoptRequest{
+			ClusterID:   item.ClusterID,
+			Namespace:   item.Namespace,
+			ReleaseName: item.ReleaseName,
+			UserID:      input.UserID,
+			ChartID:     input.ChartID,
+		})
+		if err != nil {
+			return nil, err
+		}
+		instance = result.Instance
+	case DriftValuesDrift:
+		helmClient, err := s.deployService.clusterService.HelmClient(item.ClusterID, item.Namespace)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create helm client: %w", err)
+		}
+		release, err := helmClient.GetRelease(item.ReleaseName)
+		if err != nil {
+			return nil, err
+		}
+		instance, err = s.acceptLiveValues(*item.InstanceID, *release)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, ErrDriftAction
+	}
+
+	return instance, s.db.Delete(item).Error
+}
+
+func (s *DriftService) acceptLiveValues(instanceID uint, release helm.ReleaseSummary) (*model.AppInstance, error) {
+	var instance model.AppInstance
+	if err := s.db.First(&instance, instanceID).Error; err != nil {
+		return nil, fmt.Errorf("instance not found: %w", err)
+	}
+
+	instance.AppliedValues = model.JSONMap(release.Values)
+	instance.UserValues = model.JSONMap(release.Values)
+	if err := s.deployService.saveRevision(&instance, release.Revision, "adopt", 0); err != nil {
+		return nil, err
+	}
+	return &instance, nil
+}
+
+// Resync resolves drift by pushing the recorded configuration back to the
+// cluster: a missing release is reinstalled and drifted values are
+// re-applied. The work is queued as a task.
+func (s *DriftService) Resync(id uint, userID string) (*model.Task, error) {
+	item, err := s.GetItem(id)
+	if err != nil {
+		return nil, err
+	}
+	if item.Type != DriftMissingRelease && item.Type != DriftValuesDrift {
+		return nil, ErrDriftAction
+	}
+
+	task, err := s.taskService.EnqueueResync(userID, ResyncRequest{InstanceID: *item.InstanceID})
+	if err != nil {
+		return nil, err
+	}
+	return task, s.db.Delete(item).Error
+}
+
+// Forget resolves drift by dropping it from the market: the instance record
+// of a missing release is deleted, and an unmanaged release is ignored by
+// later scans. Nothing is changed in the cluster.
+func (s *DriftService) Forget(id uint) error {
+	item, err := s.GetItem(id)
+	if err != nil {
+		return err
+	}
+
+	switch item.Type {
+	case DriftMissingRelease:
+		return s.db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("instance_id = ?", *item.InstanceID).Delete(&model.InstanceRevision{}).Error; err != nil {
+				return err
+			}
+			// Removed for good so the release name can be deployed again
+			if err := tx.Unscoped().Delete(&model.AppInstance{}, *item.InstanceID).Error; err != nil {
+				return err
+			}
+			return tx.Delete(item).Error
+		})
+	case DriftUnmanagedRelease:
+		return s.db.Model(item).Update("ignored", true).Error
+	default:
+		return ErrDriftAction
+	}
+}
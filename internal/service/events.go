@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/your-org/app-market/internal/model"
+	"gorm.io/gorm"
+)
+
+// ProgressFunc receives progress steps ("progress") and Helm log lines ("log")
+// of a running operation
+type ProgressFunc func(kind, step, message string)
+
+type progressKey struct{}
+
+// WithProgress attaches a progress receiver to ctx
+func WithProgress(ctx context.Context, fn ProgressFunc) context.Context {
+	return context.WithValue(ctx, progressKey{}, fn)
+}
+
+// reportStep emits a progress step if ctx carries a receiver
+func reportStep(ctx context.Context, step, format string, args ...interface{}) {
+	if fn, ok := ctx.Value(progressKey{}).(ProgressFunc); ok {
+		fn("progress", step, fmt.Sprintf(format, args...))
+	}
+}
+
+// helmLogger returns a Helm action log function forwarding to the progress
+// receiver of ctx, or nil when there is none
+func helmLogger(ctx context.Context) func(format string, v ...interface{}) {
+	fn, ok := ctx.Value(progressKey{}).(ProgressFunc)
+	if !ok {
+		return nil
+	}
+	return func(format string, v ...interface{}) {
+		fn("log", "", fmt.Sprintf(format, v...))
+	}
+}
+
+// eventHub persists task events and wakes up in-process subscribers. Events
+// are always read back from the database so subscribers also see events
+// written by workers in other processes.
+type eventHub struct {
+	db *gorm.DB
+
+	mu          sync.Mutex
+	subscribers map[uint]map[chan struct{}]bool
+}
+
+func newEventHub(db *gorm.DB) *eventHub {
+	return &eventHub{
+		db:          db,
+		subscribers: make(map[uint]map[chan struct{}]bool),
+	}
+}
+
+func (h *eventHub) publish(taskID uint, kind, step, message string) {
+	event := &model.TaskEvent{TaskID: taskID, Type: kind, Step: step, Message: message}
+	if err := h.db.Create(event).Error; err != nil {
+		log.Printf("Failed to save event of task %d: %v", taskID, err)
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subscribers[taskID] {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (h *eventHub) subscribe(taskID uint) (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+
+	h.mu.Lock()
+	if h.subscribers[taskID] == nil {
+		h.subscribers[taskID] = make(map[chan struct{}]bool)
+	}
+	h.subscribers[taskID][ch] = true
+	h.mu.Unlock()
+
+	return ch, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		delete(h.subscribers[taskID], ch)
+		if len(h.subscribers[taskID]) == 0 {
+			delete(h.subscribers, taskID)
+		}
+	}
+}
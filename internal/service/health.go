@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/your-org/app-market/internal/helm"
+	"github.com/your-org/app-market/internal/model"
+)
+
+// checkHealth inspects the release resources of an instance and stores the
+// result on it. The instance is not saved.
+func (s *DeployService) checkHealth(ctx context.Context, helmClient *helm.Client, instance *model.AppInstance) error {
+	status, err := helmClient.ReleaseStatus(ctx, instance.Name)
+	if err != nil {
+		return err
+	}
+
+	resources := make(model.ResourceSummaries, len(status.Resources))
+	for i, r := range status.Resources {
+		resources[i] = model.ResourceSummary{
+			Kind:      r.Kind,
+			Name:      r.Name,
+			Namespace: r.Namespace,
+			Health:    r.Health,
+			Ready:     r.Ready,
+			Message:   r.Message,
+		}
+	}
+
+	now := time.Now()
+	instance.Health = status.Health
+	instance.Resources = resources
+	instance.HealthCheckedAt = &now
+
+	if status.Health == helm.HealthHealthy {
+		reportStep(ctx, "resources_ready", "All %d resources are ready", len(resources))
+	}
+	return nil
+}
+
+// statusReconcilerLease is held by the process refreshing instance health
+const statusReconcilerLease = "status_reconciler"
+
+// StartStatusReconciler periodically refreshes the health of all deployed
+// instances until ctx is done. Every replica runs it, but only the holder of
+// the status reconciler lease does the work; another replica takes over when
+// the holder stops renewing it.
+func (s *DeployService) StartStatusReconciler(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	owner := processID()
+	for {
+		held, err := acquireLease(s.db, statusReconcilerLease, owner, 3*interval)
+		if err != nil {
+			log.Printf("Failed to acquire status reconciler lease: %v", err)
+		} else if held {
+			s.ReconcileStatus(ctx)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ReconcileStatus refreshes the health of every deployed instance and records
+// the resource requests of instances that have none recorded yet. Instances
+// that are pending, failed or being uninstalled are left alone.
+func (s *DeployService) ReconcileStatus(ctx context.Context) {
+	var instances []model.AppInstance
+	if err := s.db.Where("status = ?", "deployed").Find(&instances).Error; err != nil {
+		log.Printf("Failed to list instances for status reconciliation: %v", err)
+		return
+	}
+
+	for i := range instances {
+		instance := &instances[i]
+
+		helmClient, err := s.clusterService.HelmClient(instance.ClusterID, instance.Namespace)
+		if err != nil {
+			log.Printf("Failed to create helm client for instance %d: %v", instance.ID, err)
+			continue
+		}
+		helmClient.SetLogger(func(string, ...interface{}) {})
+
+		if err := s.checkHealth(ctx, helmClient, instance); err != nil {
+			log.Printf("Failed to check health of instance %d: %v", instance.ID, err)
+			continue
+		}
+
+		// Only touch health columns so a concurrent upgrade is not overwritten
+		updates := map[string]interface{}{
+			"health":            instance.Health,
+			"resources":         instance.Resources,
+			"health_checked_at": instance.HealthCheckedAt,
+		}
+		// Backfill the requests of instances created before they were tracked
+		if !instance.RequestsRecorded {
+			s.recordRequests(helmClient, instance)
+			if instance.RequestsRecorded {
+				updates["cpu_request"] = instance.CPURequest
+				updates["memory_request"] = instance.MemoryRequest
+				updates["requests_recorded"] = true
+			}
+		}
+		err = s.db.Model(&model.AppInstance{}).
+			Where("id = ? AND status = ?", instance.ID, "deployed").
+			Updates(updates).Error
+		if err != nil {
+			log.Printf("Failed to update health of instance %d: %v", instance.ID, err)
+		}
+	}
+}
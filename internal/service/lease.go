@@ -0,0 +1,50 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/your-org/app-market/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// processID identifies this process as a task worker or lease holder
+func processID() string {
+	hostname, _ := os.Hostname()
+	return fmt.Sprintf("%s-%d", hostname, os.Getpid())
+}
+
+// acquireLease takes the named lease for owner, or renews it if owner already
+// holds it, until ttl from now. It reports whether owner holds the lease.
+func acquireLease(db *gorm.DB, name, owner string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	lease := &model.Lease{Name: name, Owner: owner, ExpiresAt: now.Add(ttl)}
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(lease).Error; err != nil {
+		return false, fmt.Errorf("failed to create lease %s: %w", name, err)
+	}
+
+	result := db.Model(&model.Lease{}).
+		Where("name = ? AND (owner = ? OR expires_at < ?)", name, owner, now).
+		Updates(map[string]interface{}{"owner": owner, "expires_at": now.Add(ttl)})
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to acquire lease %s: %w", name, result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// lockLease writes the named lease row within tx, which blocks other
+// transactions locking the same name until tx ends, on any replica
+func lockLease(tx *gorm.DB, name string) error {
+	lock := &model.Lease{Name: name, Owner: processID(), ExpiresAt: time.Now()}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(lock).Error; err != nil {
+		return fmt.Errorf("failed to lock %s: %w", name, err)
+	}
+	err := tx.Model(&model.Lease{}).Where("name = ?", name).
+		Updates(map[string]interface{}{"owner": lock.Owner, "expires_at": lock.ExpiresAt}).Error
+	if err != nil {
+		return fmt.Errorf("failed to lock %s: %w", name, err)
+	}
+	return nil
+}
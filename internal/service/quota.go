@@ -0,0 +1,487 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/your-org/app-market/internal/config"
+	"github.com/your-org/app-market/internal/helm"
+	"github.com/your-org/app-market/internal/model"
+	"gorm.io/gorm"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// Quota subject types in addition to SubjectUser and SubjectGroup
+const (
+	SubjectNamespace = "namespace"
+	SubjectChart     = "chart"
+)
+
+// quotaCommitLock is the lease row Commit locks to serialize quota commits
+// across replicas
+const quotaCommitLock = "quota_commit"
+
+// ErrQuotaExceeded is wrapped by QuotaExceededError
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+// QuotaExceededError reports which quota a deployment would exceed
+type QuotaExceededError struct {
+	SubjectType string `json:"subject_type"`
+	SubjectID   string `json:"subject_id"`
+	Resource    string `json:"resource"` // instances, cpu, memory
+	Used        string `json:"used"`
+	Requested   string `json:"requested"`
+	Limit       string `json:"limit"`
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("quota exceeded for %s %s: %s in use %s, requested %s, limit %s",
+		e.SubjectType, e.SubjectID, e.Resource, e.Used, e.Requested, e.Limit)
+}
+
+func (e *QuotaExceededError) Unwrap() error {
+	return ErrQuotaExceeded
+}
+
+// QuotaService enforces instance counts and CPU/memory budgets. Users and
+// namespaces without a quota get the configured defaults; groups and charts
+// are only limited by explicit quotas.
+type QuotaService struct {
+	db  *gorm.DB
+	cfg config.QuotaConfig
+}
+
+func NewQuotaService(db *gorm.DB, cfg config.QuotaConfig) *QuotaService {
+	return &QuotaService{db: db, cfg: cfg}
+}
+
+// QuotaInput sets the limits of a subject
+type QuotaInput struct {
+	SubjectType  string
+	SubjectID    string
+	MaxInstances int
+	CPU          string
+	Memory       string
+}
+
+// QuotaRequest is a deployment to check against the quotas
+type QuotaRequest struct {
+	UserID    string
+	ClusterID uint // Cluster of the namespace
+	Namespace string
+	ChartID   string
+	// InstanceID is set when an existing instance changes. Its current
+	// requests are replaced by the new ones and it does not count as an
+	// additional instance.
+	InstanceID uint
+	// Requests computes the resource requests of the deployment. It is only
+	// called if a CPU or memory budget applies; nil skips budgets.
+	Requests func() (helm.ResourceRequests, error)
+}
+
+// QuotaUsage compares the usage of a subject with its limits
+type QuotaUsage struct {
+	SubjectType  string `json:"subject_type"`
+	SubjectID    string `json:"subject_id"`
+	ClusterID    uint   `json:"cluster_id,omitempty"` // Cluster of a namespace subject
+	Default      bool   `json:"default"`              // Limits are the configured defaults
+	Instances    int64  `json:"instances"`
+	MaxInstances int    `json:"max_instances"` // 0 is unlimited
+	CPUUsed      string `json:"cpu_used"`
+	CPULimit     string `json:"cpu_limit"` // Empty is unlimited
+	MemoryUsed   string `json:"memory_used"`
+	MemoryLimit  string `json:"memory_limit"`
+}
+
+// quotaLimits are the parsed limits of a subject
+type quotaLimits struct {
+	subjectType string
+	subjectID   string
+	isDefault   bool
+
+	maxInstances int
+	cpu          string
+	cpuMillis    int64
+	memory       string
+	memoryBytes  int64
+}
+
+type quotaUsage struct {
+	Instances int64
+	CPU       int64
+	Memory    int64
+}
+
+func newQuotaLimits(subjectType, subjectID string, maxInstances int, cpu, memory string) (*quotaLimits, error) {
+	l := &quotaLimits{
+		subjectType:  subjectType,
+		subjectID:    subjectID,
+		maxInstances: maxInstances,
+		cpu:          cpu,
+		memory:       memory,
+	}
+	if maxInstances < 0 {
+		return nil, fmt.Errorf("max instances must not be negative")
+	}
+	if cpu != "" {
+		q, err := resource.ParseQuantity(cpu)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cpu quota %q: %w", cpu, err)
+		}
+		l.cpuMillis = q.MilliValue()
+	}
+	if memory != "" {
+		q, err := resource.ParseQuantity(memory)
+		if err != nil {
+			return nil, fmt.Errorf("invalid memory quota %q: %w", memory, err)
+		}
+		l.memoryBytes = q.Value()
+	}
+	return l, nil
+}
+
+// Check returns a *QuotaExceededError if the deployment would exceed the
+// quota of its user, one of the user's groups, its namespace or its chart
+func (s *QuotaService) Check(req QuotaRequest) error {
+	return s.check(s.db, req)
+}
+
+// Commit checks the quotas again and runs create in the same transaction.
+// The transaction first locks the quota commit row, so commits are serialized
+// across replicas and two deployments checked before either instance existed
+// cannot both be saved if together they exceed a quota.
+func (s *QuotaService) Commit(req QuotaRequest, create func(tx *gorm.DB) error) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockLease(tx, quotaCommitLock); err != nil {
+			return err
+		}
+		if err := s.check(tx, req); err != nil {
+			return err
+		}
+		return create(tx)
+	})
+}
+
+func (s *QuotaService) check(db *gorm.DB, req QuotaRequest) error {
+	scopes, err := s.scopes(db, req.UserID, req.Namespace, req.ChartID)
+	if err != nil {
+		return err
+	}
+
+	var requests *helm.ResourceRequests
+	for _, l := range scopes {
+		used, err := s.usage(db, l.subjectType, l.subjectID, req.ClusterID, req.InstanceID)
+		if err != nil {
+			return err
+		}
+
+		if req.InstanceID == 0 && l.maxInstances > 0 && used.Instances+1 > int64(l.maxInstances) {
+			return &QuotaExceededError{
+				SubjectType: l.subjectType,
+				SubjectID:   l.subjectID,
+				Resource:    "instances",
+				Used:        fmt.Sprint(used.Instances),
+				Requested:   "1",
+				Limit:       fmt.Sprint(l.maxInstances),
+			}
+		}
+
+		if req.Requests == nil || (l.cpu == "" && l.memory == "") {
+			continue
+		}
+		if requests == nil {
+			r, err := req.Requests()
+			if err != nil {
+				return fmt.Errorf("failed to compute resource requests: %w", err)
+			}
+			requests = &r
+		}
+
+		if l.cpu != "" && used.CPU+requests.CPUMillis > l.cpuMillis {
+			return &QuotaExceededError{
+				SubjectType: l.subjectType,
+				SubjectID:   l.subjectID,
+				Resource:    "cpu",
+				Used:        formatCPU(used.CPU),
+				Requested:   formatCPU(requests.CPUMillis),
+				Limit:       l.cpu,
+			}
+		}
+		if l.memory != "" && used.Memory+requests.MemoryBytes > l.memoryBytes {
+			return &QuotaExceededError{
+				SubjectType: l.subjectType,
+				SubjectID:   l.subjectID,
+				Resource:    "memory",
+				Used:        formatMemory(used.Memory),
+				Requested:   formatMemory(requests.MemoryBytes),
+				Limit:       l.memory,
+			}
+		}
+	}
+	return nil
+}
+
+// scopes returns the limits a deployment counts against
+func (s *QuotaService) scopes(db *gorm.DB, userID, namespace, chartID string) ([]*quotaLimits, error) {
+	var scopes []*quotaLimits
+
+	user, err := s.limitsFor(db, SubjectUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	scopes = append(scopes, user)
+
+	var groupIDs []uint
+	if err := db.Model(&model.GroupMember{}).Where("user_id = ?", userID).Pluck("group_id", &groupIDs).Error; err != nil {
+		return nil, fmt.Errorf("failed to get groups: %w", err)
+	}
+	for _, id := range groupIDs {
+		group, err := s.limitsFor(db, SubjectGroup, fmt.Sprint(id))
+		if err != nil {
+			return nil, err
+		}
+		if group != nil {
+			scopes = append(scopes, group)
+		}
+	}
+
+	if namespace != "" {
+		ns, err := s.limitsFor(db, SubjectNamespace, namespace)
+		if err != nil {
+			return nil, err
+		}
+		scopes = append(scopes, ns)
+	}
+
+	if chartID != "" {
+		chart, err := s.limitsFor(db, SubjectChart, chartID)
+		if err != nil {
+			return nil, err
+		}
+		if chart != nil {
+			scopes = append(scopes, chart)
+		}
+	}
+	return scopes, nil
+}
+
+// limitsFor returns the quota of a subject, falling back to the configured
+// defaults for users and namespaces. Groups and charts without a quota are nil.
+func (s *QuotaService) limitsFor(db *gorm.DB, subjectType, subjectID string) (*quotaLimits, error) {
+	var quota model.Quota
+	err := db.Where("subject_type = ? AND subject_id = ?", subjectType, subjectID).First(&quota).Error
+	if err == nil {
+		return newQuotaLimits(subjectType, subjectID, quota.MaxInstances, quota.CPU, quota.Memory)
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to get quota: %w", err)
+	}
+
+	var defaults config.QuotaLimits
+	switch subjectType {
+	case SubjectUser:
+		defaults = s.cfg.DefaultUser
+	case SubjectNamespace:
+		defaults = s.cfg.DefaultNamespace
+	default:
+		return nil, nil
+	}
+	l, err := newQuotaLimits(subjectType, subjectID, defaults.MaxInstances, defaults.CPU, defaults.Memory)
+	if err != nil {
+		return nil, fmt.Errorf("invalid default %s quota: %w", subjectType, err)
+	}
+	l.isDefault = true
+	return l, nil
+}
+
+// usage sums the instances and resource requests counted against a subject,
+// leaving out the instance excludeID if it is not 0. Namespace subjects only
+// count instances on clusterID.
+func (s *QuotaService) usage(db *gorm.DB, subjectType, subjectID string, clusterID, excludeID uint) (quotaUsage, error) {
+	query := db.Model(&model.AppInstance{})
+	if excludeID != 0 {
+		query = query.Where("id <> ?", excludeID)
+	}
+	switch subjectType {
+	case SubjectUser:
+		query = query.Where("user_id = ?", subjectID)
+	case SubjectGroup:
+		members := db.Model(&model.GroupMember{}).Select("user_id").Where("group_id = ?", subjectID)
+		query = query.Where("user_id IN (?)", members)
+	case SubjectNamespace:
+		query = query.Where("cluster_id = ? AND namespace = ?", clusterID, subjectID)
+	case SubjectChart:
+		query = query.Where("chart_id = ?", subjectID)
+	default:
+		return quotaUsage{}, fmt.Errorf("invalid quota subject type: %s", subjectType)
+	}
+
+	var used quotaUsage
+	err := query.Select("COUNT(*) AS instances, COALESCE(SUM(cpu_request), 0) AS cpu, COALESCE(SUM(memory_request), 0) AS memory").
+		Scan(&used).Error
+	if err != nil {
+		return quotaUsage{}, fmt.Errorf("failed to compute quota usage: %w", err)
+	}
+	return used, nil
+}
+
+func (s *QuotaService) describe(l *quotaLimits, clusterID uint) (*QuotaUsage, error) {
+	used, err := s.usage(s.db, l.subjectType, l.subjectID, clusterID, 0)
+	if err != nil {
+		return nil, err
+	}
+	if l.subjectType != SubjectNamespace {
+		clusterID = 0
+	}
+	return &QuotaUsage{
+		SubjectType:  l.subjectType,
+		SubjectID:    l.subjectID,
+		ClusterID:    clusterID,
+		Default:      l.isDefault,
+		Instances:    used.Instances,
+		MaxInstances: l.maxInstances,
+		CPUUsed:      formatCPU(used.CPU),
+		CPULimit:     l.cpu,
+		MemoryUsed:   formatMemory(used.Memory),
+		MemoryLimit:  l.memory,
+	}, nil
+}
+
+// UserUsage returns the usage and limits of a user, of the groups they belong
+// to and of the namespaces they have instances in
+func (s *QuotaService) UserUsage(userID string) ([]QuotaUsage, error) {
+	scopes, err := s.scopes(s.db, userID, "", "")
+	if err != nil {
+		return nil, err
+	}
+
+	usages := make([]QuotaUsage, 0, len(scopes))
+	for _, l := range scopes {
+		u, err := s.describe(l, 0)
+		if err != nil {
+			return nil, err
+		}
+		usages = append(usages, *u)
+	}
+
+	var namespaces []struct {
+		ClusterID uint
+		Namespace string
+	}
+	err = s.db.Model(&model.AppInstance{}).Where("user_id = ?", userID).Distinct("cluster_id", "namespace").
+		Order("cluster_id, namespace").Scan(&namespaces).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get namespaces: %w", err)
+	}
+	for _, ns := range namespaces {
+		l, err := s.limitsFor(s.db, SubjectNamespace, ns.Namespace)
+		if err != nil {
+			return nil, err
+		}
+		u, err := s.describe(l, ns.ClusterID)
+		if err != nil {
+			return nil, err
+		}
+		usages = append(usages, *u)
+	}
+	return usages, nil
+}
+
+// SubjectUsage returns the usage and limits of any subject. clusterID selects
+// the cluster of a namespace subject and is ignored for other subjects.
+func (s *QuotaService) SubjectUsage(subjectType, subjectID string, clusterID uint) (*QuotaUsage, error) {
+	l, err := s.limitsFor(s.db, subjectType, subjectID)
+	if err != nil {
+		return nil, err
+	}
+	if l == nil {
+		// Unlimited, but usage is still reported
+		l = &quotaLimits{subjectType: subjectType, subjectID: subjectID}
+	}
+	return s.describe(l, clusterID)
+}
+
+// SetQuota creates or replaces the quota of a subject
+func (s *QuotaService) SetQuota(input QuotaInput) (*model.Quota, error) {
+	switch input.SubjectType {
+	case SubjectUser, SubjectGroup, SubjectNamespace, SubjectChart:
+	default:
+		return nil, fmt.Errorf("invalid quota subject type: %s", input.SubjectType)
+	}
+	if input.SubjectID == "" {
+		return nil, fmt.Errorf("subject ID is required")
+	}
+	if _, err := newQuotaLimits(input.SubjectType, input.SubjectID, input.MaxInstances, input.CPU, input.Memory); err != nil {
+		return nil, err
+	}
+
+	var quota model.Quota
+	err := s.db.Where("subject_type = ? AND subject_id = ?", input.SubjectType, input.SubjectID).
+		FirstOrInit(&quota).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get quota: %w", err)
+	}
+	quota.SubjectType = input.SubjectType
+	quota.SubjectID = input.SubjectID
+	quota.MaxInstances = input.MaxInstances
+	quota.CPU = input.CPU
+	quota.Memory = input.Memory
+
+	if err := s.db.Save(&quota).Error; err != nil {
+		return nil, fmt.Errorf("failed to save quota: %w", err)
+	}
+	return &quota, nil
+}
+
+// GetQuota returns the explicit quota of a subject, or nil if it has none
+func (s *QuotaService) GetQuota(subjectType, subjectID string) (*model.Quota, error) {
+	var quota model.Quota
+	err := s.db.Where("subject_type = ? AND subject_id = ?", subjectType, subjectID).First(&quota).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get quota: %w", err)
+	}
+	return &quota, nil
+}
+
+// ListQuotas returns the explicit quotas, optionally of one subject type
+func (s *QuotaService) ListQuotas(subjectType string) ([]model.Quota, error) {
+	query := s.db.Model(&model.Quota{})
+	if subjectType != "" {
+		query = query.Where("subject_type = ?", subjectType)
+	}
+
+	quotas := []model.Quota{}
+	err := query.Order("subject_type, subject_id").Find(&quotas).Error
+	return quotas, err
+}
+
+// DeleteQuota removes a quota and returns it as it was; users and namespaces
+// fall back to the defaults
+func (s *QuotaService) DeleteQuota(id uint) (*model.Quota, error) {
+	var quota model.Quota
+	if err := s.db.First(&quota, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("quota not found")
+		}
+		return nil, fmt.Errorf("failed to get quota: %w", err)
+	}
+	result := s.db.Delete(&model.Quota{}, id)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to delete quota: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, fmt.Errorf("quota not found")
+	}
+	return &quota, nil
+}
+
+func formatCPU(millis int64) string {
+	return resource.NewMilliQuantity(millis, resource.DecimalSI).String()
+}
+
+func formatMemory(bytes int64) string {
+	return resource.NewQuantity(bytes, resource.BinarySI).String()
+}
@@ -0,0 +1,126 @@
+package service
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/your-org/app-market/internal/config"
+	"github.com/your-org/app-market/internal/helm"
+	"github.com/your-org/app-market/internal/model"
+	"gorm.io/gorm"
+)
+
+func TestQuotaCommitSerializesDeployments(t *testing.T) {
+	db := newTestDB(t)
+	cfg := config.QuotaConfig{DefaultUser: config.QuotaLimits{MaxInstances: 1}}
+	// One service per goroutine, as on two replicas
+	replicas := []*QuotaService{NewQuotaService(db, cfg), NewQuotaService(db, cfg)}
+
+	// Each create waits a moment for the other commit to pass its check too,
+	// which only happens if commits are not serialized
+	var checked sync.WaitGroup
+	checked.Add(2)
+	bothChecked := make(chan struct{})
+	go func() {
+		checked.Wait()
+		close(bothChecked)
+	}()
+
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = replicas[i].Commit(QuotaRequest{UserID: "alice", Namespace: "team-a"}, func(tx *gorm.DB) error {
+				checked.Done()
+				select {
+				case <-bothChecked:
+				case <-time.After(100 * time.Millisecond):
+				}
+				return tx.Create(&model.AppInstance{Name: []string{"a", "b"}[i], Namespace: "team-a", UserID: "alice"}).Error
+			})
+		}(i)
+	}
+	wg.Wait()
+
+	var saved, exceeded int
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			saved++
+		case errors.Is(err, ErrQuotaExceeded):
+			exceeded++
+		default:
+			t.Fatalf("Commit: unexpected error %v", err)
+		}
+	}
+	if saved != 1 || exceeded != 1 {
+		t.Fatalf("got %d saved and %d over quota, want 1 and 1", saved, exceeded)
+	}
+}
+
+func TestQuotaCheckReplacesRequestsOfChangedInstance(t *testing.T) {
+	db := newTestDB(t)
+	quotas := NewQuotaService(db, config.QuotaConfig{DefaultUser: config.QuotaLimits{MaxInstances: 1, CPU: "1"}})
+
+	instance := &model.AppInstance{Name: "web", Namespace: "team-a", UserID: "alice", CPURequest: 600}
+	if err := db.Create(instance).Error; err != nil {
+		t.Fatal(err)
+	}
+	requests := func(millis int64) func() (helm.ResourceRequests, error) {
+		return func() (helm.ResourceRequests, error) {
+			return helm.ResourceRequests{CPUMillis: millis}, nil
+		}
+	}
+
+	tests := []struct {
+		name       string
+		instanceID uint
+		cpuMillis  int64
+		wantErr    bool
+	}{
+		{"new instance over the instance limit", 0, 100, true},
+		{"upgrade within the budget", instance.ID, 1000, false},
+		{"upgrade over the budget", instance.ID, 1200, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := quotas.Check(QuotaRequest{
+				UserID:     "alice",
+				Namespace:  "team-a",
+				InstanceID: tt.instanceID,
+				Requests:   requests(tt.cpuMillis),
+			})
+			if tt.wantErr != errors.Is(err, ErrQuotaExceeded) {
+				t.Fatalf("Check: got %v, want quota exceeded=%v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNamespaceQuotaCountsOneCluster(t *testing.T) {
+	db := newTestDB(t)
+	quotas := NewQuotaService(db, config.QuotaConfig{DefaultNamespace: config.QuotaLimits{MaxInstances: 1}})
+
+	if err := db.Create(&model.AppInstance{Name: "web", ClusterID: 1, Namespace: "team-a", UserID: "alice"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := quotas.Check(QuotaRequest{UserID: "bob", ClusterID: 2, Namespace: "team-a"}); err != nil {
+		t.Errorf("Check on another cluster: %v", err)
+	}
+	if err := quotas.Check(QuotaRequest{UserID: "bob", ClusterID: 1, Namespace: "team-a"}); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("Check on the same cluster: got %v, want quota exceeded", err)
+	}
+
+	usage, err := quotas.SubjectUsage(SubjectNamespace, "team-a", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage.Instances != 0 {
+		t.Errorf("usage of team-a on cluster 2: got %d instances, want 0", usage.Instances)
+	}
+}
@@ -0,0 +1,206 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/your-org/app-market/internal/config"
+	"github.com/your-org/app-market/internal/model"
+	"github.com/your-org/app-market/pkg/crypto"
+)
+
+// Repository auth types
+const (
+	RepoAuthNone   = "none"
+	RepoAuthBasic  = "basic"
+	RepoAuthBearer = "bearer"
+)
+
+// RepoClient fetches indexes and chart archives from chart repositories. It
+// applies each repo's credentials and TLS settings; repos with the same TLS
+// settings share a transport.
+type RepoClient struct {
+	cipher *crypto.Cipher
+	cfg    config.RepoConfig
+
+	mu               sync.Mutex
+	defaultTransport *http.Transport
+	transports       map[[32]byte]*http.Transport // TLS settings fingerprint -> transport
+}
+
+func NewRepoClient(cipher *crypto.Cipher, cfg config.RepoConfig) *RepoClient {
+	return &RepoClient{
+		cipher:     cipher,
+		cfg:        cfg,
+		transports: make(map[[32]byte]*http.Transport),
+	}
+}
+
+// Get requests rawURL, which may be relative to the repo URL. The repo's
+// credentials and TLS settings are only used for the repo's own host. A nil
+// repo fetches the URL without credentials or custom TLS. The caller closes
+// the response body.
+func (c *RepoClient) Get(ctx context.Context, repo *model.ChartRepo, rawURL string, header http.Header) (*http.Response, error) {
+	target, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url %s: %w", rawURL, err)
+	}
+
+	var transport http.RoundTripper = c.transport()
+	if repo != nil {
+		base, err := url.Parse(strings.TrimSuffix(repo.URL, "/") + "/")
+		if err != nil {
+			return nil, fmt.Errorf("invalid repo url %s: %w", repo.URL, err)
+		}
+		target = base.ResolveReference(target)
+		repoTransport, err := c.repoTransport(repo)
+		if err != nil {
+			return nil, err
+		}
+		transport = &repoHostTransport{repoURL: repo.URL, repo: repoTransport, other: transport}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	if repo != nil && sameHost(target, repo.URL) {
+		if err := c.authorize(req, repo); err != nil {
+			return nil, err
+		}
+	}
+
+	client := &http.Client{Transport: transport, Timeout: c.cfg.Timeout}
+	return client.Do(req)
+}
+
+func sameHost(target *url.URL, repoURL string) bool {
+	base, err := url.Parse(repoURL)
+	return err == nil && base.Scheme == target.Scheme && base.Host == target.Host
+}
+
+// repoHostTransport sends requests for the repo's host, including redirects
+// back to it, with the repo's TLS settings, so a client certificate or a
+// relaxed verification never reaches a chart URL on another host
+type repoHostTransport struct {
+	repoURL string
+	repo    http.RoundTripper
+	other   http.RoundTripper
+}
+
+func (t *repoHostTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if sameHost(req.URL, t.repoURL) {
+		return t.repo.RoundTrip(req)
+	}
+	return t.other.RoundTrip(req)
+}
+
+// authorize adds the repo's credentials to a request
+func (c *RepoClient) authorize(req *http.Request, repo *model.ChartRepo) error {
+	switch repo.AuthType {
+	case RepoAuthBasic:
+		password, err := c.cipher.Decrypt(repo.Password)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt repo password: %w", err)
+		}
+		req.SetBasicAuth(repo.Username, password)
+	case RepoAuthBearer:
+		token, err := c.cipher.Decrypt(repo.BearerToken)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt repo token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return nil
+}
+
+// repoTransport returns the transport for a repo's TLS settings
+func (c *RepoClient) repoTransport(repo *model.ChartRepo) (*http.Transport, error) {
+	if repo.CACert == "" && repo.ClientCert == "" && !repo.InsecureSkipVerify {
+		return c.transport(), nil
+	}
+
+	// The key belongs to the certificate, so the certificates identify the settings
+	fingerprint := sha256.Sum256([]byte(fmt.Sprintf("%t\x00%s\x00%s", repo.InsecureSkipVerify, repo.CACert, repo.ClientCert)))
+	c.mu.Lock()
+	t, ok := c.transports[fingerprint]
+	c.mu.Unlock()
+	if ok {
+		return t, nil
+	}
+
+	key, err := c.cipher.Decrypt(repo.ClientKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt repo client key: %w", err)
+	}
+	tlsConfig, err := repoTLSConfig(repo.CACert, repo.ClientCert, key, repo.InsecureSkipVerify)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if t, ok := c.transports[fingerprint]; ok {
+		return t, nil
+	}
+	t = c.newTransport(tlsConfig)
+	c.transports[fingerprint] = t
+	return t, nil
+}
+
+// repoTLSConfig builds and validates the TLS settings of a repo
+func repoTLSConfig(caCert, clientCert, clientKey string, insecure bool) (*tls.Config, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: insecure}
+	if caCert != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM([]byte(caCert)) {
+			return nil, fmt.Errorf("invalid CA certificate")
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if clientCert != "" || clientKey != "" {
+		cert, err := tls.X509KeyPair([]byte(clientCert), []byte(clientKey))
+		if err != nil {
+			return nil, fmt.Errorf("invalid client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// transport returns the shared transport for repos without TLS settings
+func (c *RepoClient) transport() *http.Transport {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.defaultTransport == nil {
+		c.defaultTransport = c.newTransport(nil)
+	}
+	return c.defaultTransport
+}
+
+func (c *RepoClient) newTransport(tlsConfig *tls.Config) *http.Transport {
+	return &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           (&net.Dialer{Timeout: c.cfg.DialTimeout, KeepAlive: 30 * time.Second}).DialContext,
+		TLSClientConfig:       tlsConfig,
+		TLSHandshakeTimeout:   c.cfg.DialTimeout,
+		ResponseHeaderTimeout: c.cfg.ResponseHeaderTimeout,
+		MaxIdleConnsPerHost:   4,
+		IdleConnTimeout:       90 * time.Second,
+	}
+}
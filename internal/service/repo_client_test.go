@@ -0,0 +1,57 @@
+package service
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/your-org/app-market/internal/config"
+	"github.com/your-org/app-market/internal/model"
+	"github.com/your-org/app-market/pkg/crypto"
+)
+
+func TestRepoClientUsesRepoTLSOnlyForRepoHost(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	repoServer := httptest.NewTLSServer(ok)
+	defer repoServer.Close()
+	// The rejected handshake is expected, keep the server from logging it
+	otherServer := httptest.NewUnstartedServer(ok)
+	otherServer.Config.ErrorLog = log.New(io.Discard, "", 0)
+	otherServer.StartTLS()
+	defer otherServer.Close()
+
+	cipher, err := crypto.NewCipher("test-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewRepoClient(cipher, config.RepoConfig{Timeout: 5 * time.Second, DialTimeout: time.Second})
+	// The test servers use self-signed certificates, so only requests sent
+	// with the repo's relaxed verification can succeed
+	repo := &model.ChartRepo{URL: repoServer.URL, InsecureSkipVerify: true}
+
+	tests := []struct {
+		name    string
+		url     string
+		wantErr bool
+	}{
+		{"repo host", "index.yaml", false},
+		{"other host", otherServer.URL + "/charts/nginx-1.0.0.tgz", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := client.Get(context.Background(), repo, tt.url, nil)
+			if err == nil {
+				resp.Body.Close()
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Get(%s): error %v, want error %v", tt.url, err, tt.wantErr)
+			}
+		})
+	}
+}
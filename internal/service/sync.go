@@ -1,67 +1,396 @@
 package service
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"log"
+	"math/rand/v2"
 	"net/http"
 	"time"
 
+	"github.com/your-org/app-market/internal/config"
 	"github.com/your-org/app-market/internal/model"
+	"github.com/your-org/app-market/pkg/crypto"
 	"gorm.io/gorm"
 	"helm.sh/helm/v3/pkg/repo"
 	"sigs.k8s.io/yaml"
 )
 
 type SyncService struct {
-	db *gorm.DB
+	db         *gorm.DB
+	cipher     *crypto.Cipher
+	repoClient *RepoClient
+	webhooks   *WebhookService
+	cfg        config.ReconcileConfig
 }
 
-func NewSyncService(db *gorm.DB) *SyncService {
-	return &SyncService{db: db}
+func NewSyncService(db *gorm.DB, cipher *crypto.Cipher, repoClient *RepoClient, webhooks *WebhookService, cfg config.ReconcileConfig) *SyncService {
+	return &SyncService{
+		db:         db,
+		cipher:     cipher,
+		repoClient: repoClient,
+		webhooks:   webhooks,
+		cfg:        cfg,
+	}
+}
+
+var (
+	// ErrInvalidRepo is wrapped by errors about invalid repository settings
+	ErrInvalidRepo = errors.New("invalid repository")
+	// ErrRepoExists is returned when a repository name is already taken
+	ErrRepoExists = errors.New("repository name already exists")
+)
+
+// Sync statuses
+const (
+	SyncStatusSynced    = "synced"
+	SyncStatusUnchanged = "unchanged"
+	SyncStatusFailed    = "failed"
+)
+
+// RepoInput adds or updates a repository. On update, empty fields keep their
+// current value; setting AuthType replaces the credentials.
+type RepoInput struct {
+	Name         string
+	URL          string
+	SyncInterval *int // Seconds between scheduled syncs, 0 disables them
+
+	AuthType    string // none, basic, bearer
+	Username    string
+	Password    string
+	BearerToken string
+
+	CACert             string
+	ClientCert         string
+	ClientKey          string
+	InsecureSkipVerify *bool
 }
 
 // AddRepo adds a new repository to sync
-func (s *SyncService) AddRepo(name, url string) error {
+func (s *SyncService) AddRepo(input RepoInput) (*model.ChartRepo, error) {
 	repo := &model.ChartRepo{
-		Name: name,
-		URL:  url,
+		Name:     input.Name,
+		URL:      input.URL,
+		AuthType: RepoAuthNone,
+	}
+	if err := s.applySyncInterval(repo, input.SyncInterval); err != nil {
+		return nil, err
+	}
+	if err := s.applyCredentials(repo, input); err != nil {
+		return nil, err
+	}
+	if err := s.db.Create(repo).Error; err != nil {
+		return nil, s.saveRepoError(repo, err)
+	}
+	return repo, nil
+}
+
+// UpdateRepo changes a repository
+func (s *SyncService) UpdateRepo(id uint, input RepoInput) (*model.ChartRepo, error) {
+	repo, err := s.GetRepo(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if input.Name != "" {
+		repo.Name = input.Name
+	}
+	if input.URL != "" && input.URL != repo.URL {
+		repo.URL = input.URL
+		// The validators belong to the old index
+		repo.IndexETag = ""
+		repo.IndexLastModified = ""
+		repo.IndexGenerated = nil
+	}
+	if err := s.applySyncInterval(repo, input.SyncInterval); err != nil {
+		return nil, err
+	}
+	if err := s.applyCredentials(repo, input); err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Save(repo).Error; err != nil {
+		return nil, s.saveRepoError(repo, err)
+	}
+	return repo, nil
+}
+
+// saveRepoError reports a failed insert or update as ErrRepoExists if another
+// repository, possibly a deleted one, already has the name
+func (s *SyncService) saveRepoError(repo *model.ChartRepo, err error) error {
+	var taken int64
+	if s.db.Unscoped().Model(&model.ChartRepo{}).Where("name = ? AND id <> ?", repo.Name, repo.ID).Count(&taken).Error == nil && taken > 0 {
+		return fmt.Errorf("%w: %s", ErrRepoExists, repo.Name)
 	}
-	return s.db.Create(repo).Error
+	return fmt.Errorf("failed to save repo: %w", err)
 }
 
-// SyncRepo fetches the index.yaml and updates the local chart cache
-func (s *SyncService) SyncRepo(repoID uint) error {
-	var chartRepo model.ChartRepo
-	if err := s.db.First(&chartRepo, repoID).Error; err != nil {
-		return fmt.Errorf("repo not found: %w", err)
+// applySyncInterval sets the sync interval and schedules the next sync
+func (s *SyncService) applySyncInterval(repo *model.ChartRepo, interval *int) error {
+	if interval == nil {
+		return nil
+	}
+	if *interval < 0 {
+		return fmt.Errorf("%w: sync interval must not be negative", ErrInvalidRepo)
 	}
+	repo.SyncInterval = *interval
+	repo.NextSyncAt = s.nextSync(repo)
+	return nil
+}
 
-	// 1. Download index.yaml
-	indexURL := fmt.Sprintf("%s/index.yaml", chartRepo.URL)
-	resp, err := http.Get(indexURL)
+// applyCredentials validates the credentials and TLS settings of the input
+// and stores them on the repo, encrypting the secrets
+func (s *SyncService) applyCredentials(repo *model.ChartRepo, input RepoInput) error {
+	switch input.AuthType {
+	case "":
+	case RepoAuthNone:
+		repo.Username, repo.Password, repo.BearerToken = "", "", ""
+	case RepoAuthBasic:
+		if input.Username == "" || input.Password == "" {
+			return fmt.Errorf("%w: basic auth requires a username and password", ErrInvalidRepo)
+		}
+		password, err := s.cipher.Encrypt(input.Password)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt repo password: %w", err)
+		}
+		repo.Username, repo.Password, repo.BearerToken = input.Username, password, ""
+	case RepoAuthBearer:
+		if input.BearerToken == "" {
+			return fmt.Errorf("%w: bearer auth requires a token", ErrInvalidRepo)
+		}
+		token, err := s.cipher.Encrypt(input.BearerToken)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt repo token: %w", err)
+		}
+		repo.Username, repo.Password, repo.BearerToken = "", "", token
+	default:
+		return fmt.Errorf("%w: invalid auth type: %s", ErrInvalidRepo, input.AuthType)
+	}
+	if input.AuthType != "" {
+		repo.AuthType = input.AuthType
+	}
+
+	if input.InsecureSkipVerify != nil {
+		repo.InsecureSkipVerify = *input.InsecureSkipVerify
+	}
+	if input.CACert != "" {
+		repo.CACert = input.CACert
+	}
+	// The certificate and key are replaced together
+	if input.ClientCert != "" || input.ClientKey != "" {
+		key, err := s.cipher.Encrypt(input.ClientKey)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt repo client key: %w", err)
+		}
+		repo.ClientCert, repo.ClientKey = input.ClientCert, key
+	}
+	if input.CACert != "" || input.ClientCert != "" || input.ClientKey != "" {
+		if _, err := repoTLSConfig(repo.CACert, input.ClientCert, input.ClientKey, repo.InsecureSkipVerify); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidRepo, err)
+		}
+	}
+	return nil
+}
+
+// nextSync returns when a repo is due for its next scheduled sync, adding up
+// to the configured jitter so repos with the same interval spread out
+func (s *SyncService) nextSync(repo *model.ChartRepo) *time.Time {
+	if repo.SyncInterval <= 0 {
+		return nil
+	}
+	interval := time.Duration(repo.SyncInterval) * time.Second
+	if jitter := time.Duration(float64(interval) * s.cfg.RepoSyncJitter); jitter > 0 {
+		interval += rand.N(jitter)
+	}
+	next := time.Now().Add(interval)
+	return &next
+}
+
+// GetRepo returns a repository
+func (s *SyncService) GetRepo(id uint) (*model.ChartRepo, error) {
+	var repo model.ChartRepo
+	if err := s.db.First(&repo, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("repo not found")
+		}
+		return nil, fmt.Errorf("failed to get repo: %w", err)
+	}
+	return &repo, nil
+}
+
+// SyncResult is the outcome of a repo sync
+type SyncResult struct {
+	Status        string `json:"status"` // synced or unchanged
+	ChartCount    int    `json:"chart_count"`
+	VersionCount  int    `json:"version_count"`
+	AddedVersions int    `json:"added_versions"`
+}
+
+// StartScheduler syncs repos whose scheduled sync is due, checking every
+// interval until ctx is done
+func (s *SyncService) StartScheduler(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		s.syncDue(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// syncDue syncs the repos that are due, one at a time
+func (s *SyncService) syncDue(ctx context.Context) {
+	var due []model.ChartRepo
+	err := s.db.Where("sync_interval > 0 AND (next_sync_at IS NULL OR next_sync_at <= ?)", time.Now()).
+		Order("next_sync_at").Find(&due).Error
 	if err != nil {
-		return fmt.Errorf("failed to fetch index: %w", err)
+		log.Printf("Failed to list repos due for sync: %v", err)
+		return
+	}
+
+	for i := range due {
+		repo := &due[i]
+		// Move the next sync forward first so other processes skip this one
+		query := s.db.Model(&model.ChartRepo{}).Where("id = ?", repo.ID)
+		if repo.NextSyncAt == nil {
+			query = query.Where("next_sync_at IS NULL")
+		} else {
+			query = query.Where("next_sync_at = ?", *repo.NextSyncAt)
+		}
+		result := query.Update("next_sync_at", s.nextSync(repo))
+		if result.Error != nil || result.RowsAffected == 0 {
+			continue
+		}
+
+		if _, err := s.SyncRepo(ctx, repo.ID, false); err != nil {
+			log.Printf("Scheduled sync of repo %s failed: %v", repo.Name, err)
+		}
+	}
+}
+
+// SyncRepo fetches the index.yaml and updates the local chart cache. Unless
+// force is set, an index that has not changed since the last sync is skipped.
+// The outcome is stored on the repo and webhooks are notified when the sync
+// fails.
+func (s *SyncService) SyncRepo(ctx context.Context, repoID uint, force bool) (*SyncResult, error) {
+	chartRepo, err := s.GetRepo(repoID)
+	if err != nil {
+		return nil, err
+	}
+
+	result, validators, err := s.syncRepo(ctx, chartRepo, force)
+
+	now := time.Now()
+	updates := map[string]interface{}{
+		"last_sync_at":    &now,
+		"last_sync_error": "",
+		"next_sync_at":    s.nextSync(chartRepo),
+	}
+	if err != nil {
+		updates["last_sync_status"] = SyncStatusFailed
+		updates["last_sync_error"] = err.Error()
+	} else {
+		updates["last_sync_status"] = result.Status
+		updates["added_versions"] = result.AddedVersions
+		if result.Status == SyncStatusSynced {
+			updates["chart_count"] = result.ChartCount
+			updates["version_count"] = result.VersionCount
+			updates["index_etag"] = validators.etag
+			updates["index_last_modified"] = validators.lastModified
+			updates["index_generated"] = validators.generated
+		}
+	}
+	if dbErr := s.db.Model(&model.ChartRepo{}).Where("id = ?", repoID).Updates(updates).Error; dbErr != nil {
+		log.Printf("Failed to save sync status of repo %d: %v", repoID, dbErr)
+	}
+
+	if err != nil {
+		s.webhooks.Emit(EventRepoSyncFailed, map[string]interface{}{
+			"repo_id": repoID,
+			"name":    chartRepo.Name,
+			"error":   err.Error(),
+		})
+		return nil, err
+	}
+	return result, nil
+}
+
+// indexValidators identify a fetched index so the next sync can skip it
+type indexValidators struct {
+	etag         string
+	lastModified string
+	generated    *time.Time
+}
+
+func (s *SyncService) syncRepo(ctx context.Context, chartRepo *model.ChartRepo, force bool) (*SyncResult, *indexValidators, error) {
+	unchanged := &SyncResult{
+		Status:       SyncStatusUnchanged,
+		ChartCount:   chartRepo.ChartCount,
+		VersionCount: chartRepo.VersionCount,
+	}
+
+	// 1. Download index.yaml, unless the server reports it unchanged
+	header := http.Header{}
+	if !force {
+		if chartRepo.IndexETag != "" {
+			header.Set("If-None-Match", chartRepo.IndexETag)
+		}
+		if chartRepo.IndexLastModified != "" {
+			header.Set("If-Modified-Since", chartRepo.IndexLastModified)
+		}
+	}
+	resp, err := s.repoClient.Get(ctx, chartRepo, "index.yaml", header)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch index: %w", err)
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotModified {
+		return unchanged, nil, nil
+	}
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("failed to fetch index: status %d", resp.StatusCode)
+		return nil, nil, fmt.Errorf("failed to fetch index: status %d", resp.StatusCode)
 	}
 
 	data, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return fmt.Errorf("failed to read index: %w", err)
+		return nil, nil, fmt.Errorf("failed to read index: %w", err)
 	}
 
 	// 2. Parse index.yaml
 	var indexFile repo.IndexFile
 	if err := yaml.Unmarshal(data, &indexFile); err != nil {
-		return fmt.Errorf("failed to parse index: %w", err)
+		return nil, nil, fmt.Errorf("failed to parse index: %w", err)
+	}
+
+	// Servers without validators still regenerate the timestamp on change
+	if !force && !indexFile.Generated.IsZero() && chartRepo.IndexGenerated != nil &&
+		indexFile.Generated.Equal(*chartRepo.IndexGenerated) {
+		return unchanged, nil, nil
 	}
 
+	validators := &indexValidators{
+		etag:         resp.Header.Get("ETag"),
+		lastModified: resp.Header.Get("Last-Modified"),
+	}
+	if !indexFile.Generated.IsZero() {
+		generated := indexFile.Generated
+		validators.generated = &generated
+	}
+	result := &SyncResult{Status: SyncStatusSynced, ChartCount: len(indexFile.Entries)}
+
 	// 3. Update Database (Transaction)
-	return s.db.Transaction(func(tx *gorm.DB) error {
+	err = s.db.Transaction(func(tx *gorm.DB) error {
 		for name, versions := range indexFile.Entries {
+			result.VersionCount += len(versions)
+
 			// Find or Create Chart
 			var chart model.Chart
 			if err := tx.Where("repo_id = ? AND name = ?", chartRepo.ID, name).FirstOrCreate(&chart, model.Chart{
@@ -92,12 +421,17 @@ func (s *SyncService) SyncRepo(repoID uint) error {
 				if err := tx.Create(&newVersion).Error; err != nil {
 					return err
 				}
+				result.AddedVersions++
 			}
 		}
 
 		// Update Repo Timestamp
-		return tx.Model(&chartRepo).Update("updated_at", time.Now()).Error
+		return tx.Model(chartRepo).Update("updated_at", time.Now()).Error
 	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return result, validators, nil
 }
 
 // ListRepos returns all configured repositories
@@ -0,0 +1,34 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/your-org/app-market/internal/config"
+)
+
+func TestAddRepoErrors(t *testing.T) {
+	db := newTestDB(t)
+	syncer := NewSyncService(db, nil, nil, nil, config.ReconcileConfig{})
+	if _, err := syncer.AddRepo(RepoInput{Name: "bitnami", URL: "https://charts.bitnami.com/bitnami"}); err != nil {
+		t.Fatalf("AddRepo: %v", err)
+	}
+
+	negative := -1
+	tests := []struct {
+		name    string
+		input   RepoInput
+		wantErr error
+	}{
+		{"duplicate name", RepoInput{Name: "bitnami", URL: "https://example.com/charts"}, ErrRepoExists},
+		{"negative sync interval", RepoInput{Name: "other", URL: "https://example.com/charts", SyncInterval: &negative}, ErrInvalidRepo},
+		{"basic auth without password", RepoInput{Name: "other", URL: "https://example.com/charts", AuthType: "basic", Username: "robot"}, ErrInvalidRepo},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := syncer.AddRepo(tt.input); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("AddRepo: got %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -3,117 +3,778 @@ package service
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/your-org/app-market/internal/config"
+	"github.com/your-org/app-market/internal/helm"
 	"github.com/your-org/app-market/internal/model"
 	"gorm.io/gorm"
 )
 
 type TaskService struct {
-	db            *gorm.DB
-	deployService *DeployService
-	taskQueue     chan uint
+	db              *gorm.DB
+	deployService   *DeployService
+	approvalService *ApprovalService
+	webhooks        *WebhookService
+	cfg             config.TaskConfig
+	workerID        string
+	wake            chan struct{}
+	events          *eventHub
+
+	mu      sync.Mutex
+	active  map[int]*ActiveTask         // worker index -> task it is running
+	cancels map[uint]context.CancelFunc // task ID -> cancel of its running context
+}
+
+// ActiveTask is a task currently executed by a worker of this process
+type ActiveTask struct {
+	Worker    int       `json:"worker"`
+	TaskID    uint      `json:"task_id"`
+	Type      string    `json:"type"`
+	LockKey   string    `json:"lock_key"`
+	StartedAt time.Time `json:"started_at"`
 }
 
-func NewTaskService(db *gorm.DB, ds *DeployService) *TaskService {
+// PoolStats reports worker pool utilisation
+type PoolStats struct {
+	Workers int          `json:"workers"`
+	Busy    int          `json:"busy"`
+	Pending int64        `json:"pending"` // queued tasks across all processes
+	Running int64        `json:"running"` // running tasks across all processes
+	Active  []ActiveTask `json:"active"`
+}
+
+func NewTaskService(db *gorm.DB, ds *DeployService, as *ApprovalService, ws *WebhookService, cfg config.TaskConfig) *TaskService {
 	ts := &TaskService{
-		db:            db,
-		deployService: ds,
-		taskQueue:     make(chan uint, 100),
+		db:              db,
+		deployService:   ds,
+		approvalService: as,
+		webhooks:        ws,
+		cfg:             cfg,
+		workerID:        processID(),
+		wake:            make(chan struct{}, cfg.Workers),
+		events:          newEventHub(db),
+		active:          make(map[int]*ActiveTask),
+		cancels:         make(map[uint]context.CancelFunc),
 	}
-	go ts.StartWorker()
+	go ts.StartWorkers()
 	return ts
 }
 
-func (s *TaskService) StartWorker() {
-	for taskID := range s.taskQueue {
-		s.processTask(taskID)
+// StartWorkers recovers orphaned tasks and starts the configured number of
+// workers. Tasks sharing a lock key (namespace/release) run one at a time in
+// enqueue order, unrelated tasks run in parallel.
+func (s *TaskService) StartWorkers() {
+	if n := s.recoverTasks(); n > 0 {
+		log.Printf("Recovered %d orphaned tasks", n)
+	}
+
+	for i := 0; i < s.cfg.Workers; i++ {
+		go s.runWorker(i)
+	}
+}
+
+// runWorker claims tasks from the database until none are left, then waits
+// for a new enqueue or the next poll tick. Tasks whose lease expired (e.g. the
+// process running them died) are picked up again like pending ones.
+func (s *TaskService) runWorker(worker int) {
+	ticker := time.NewTicker(s.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			task, err := s.claimNext()
+			if err != nil {
+				log.Printf("Worker %d failed to claim task: %v", worker, err)
+				break
+			}
+			if task == nil {
+				break
+			}
+
+			s.setActive(worker, task)
+			s.processTask(task)
+			s.setActive(worker, nil)
+		}
+
+		select {
+		case <-s.wake:
+		case <-ticker.C:
+		}
 	}
 }
 
-func (s *TaskService) processTask(taskID uint) {
-	var task model.Task
-	if err := s.db.First(&task, taskID).Error; err != nil {
-		log.Printf("Failed to load task %d: %v", taskID, err)
+// recoverTasks returns running tasks with an expired or missing lease to the
+// pending state so they are retried instead of being orphaned forever.
+func (s *TaskService) recoverTasks() int64 {
+	result := s.db.Model(&model.Task{}).
+		Where("status = ? AND (lease_expires_at IS NULL OR lease_expires_at < ?)", "running", time.Now()).
+		Updates(map[string]interface{}{"status": "pending", "lease_owner": ""})
+	if result.Error != nil {
+		log.Printf("Failed to recover tasks: %v", result.Error)
+	}
+	return result.RowsAffected
+}
+
+// runnableCondition matches tasks a worker may claim: pending ones that are
+// due and running ones whose lease expired, provided no earlier unfinished
+// task holds the same lock key.
+const runnableCondition = `((status = 'pending' AND (next_run_at IS NULL OR next_run_at <= ?))
+		OR (status = 'running' AND lease_expires_at < ?))
+	AND NOT EXISTS (
+		SELECT 1 FROM tasks AS prior
+		WHERE prior.lock_key = tasks.lock_key AND prior.lock_key <> ''
+		AND prior.id < tasks.id AND prior.status IN ('pending', 'running')
+		AND prior.deleted_at IS NULL
+	)`
+
+// claimNext atomically takes the oldest runnable task and leases it to this
+// worker. It returns nil when there is nothing to do.
+func (s *TaskService) claimNext() (*model.Task, error) {
+	now := time.Now()
+
+	var candidates []model.Task
+	if err := s.db.Where(runnableCondition, now, now).Order("id").Limit(s.cfg.Workers + 1).Find(&candidates).Error; err != nil {
+		return nil, err
+	}
+
+	for _, candidate := range candidates {
+		leaseExpiresAt := now.Add(s.cfg.LeaseDuration)
+		result := s.db.Model(&model.Task{}).
+			Where("id = ?", candidate.ID).
+			Where(runnableCondition, now, now).
+			Updates(map[string]interface{}{
+				"status":           "running",
+				"lease_owner":      s.workerID,
+				"lease_expires_at": leaseExpiresAt,
+				"heartbeat_at":     now,
+				"attempts":         gorm.Expr("attempts + 1"),
+			})
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected == 0 {
+			continue // claimed by another worker in the meantime
+		}
+
+		var task model.Task
+		if err := s.db.First(&task, candidate.ID).Error; err != nil {
+			return nil, err
+		}
+		return &task, nil
+	}
+
+	return nil, nil
+}
+
+func (s *TaskService) setActive(worker int, task *model.Task) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if task == nil {
+		delete(s.active, worker)
 		return
 	}
+	s.active[worker] = &ActiveTask{
+		Worker:    worker,
+		TaskID:    task.ID,
+		Type:      task.Type,
+		LockKey:   task.LockKey,
+		StartedAt: time.Now(),
+	}
+}
+
+// PoolStats returns the utilisation of this process' worker pool together
+// with the global queue depth
+func (s *TaskService) PoolStats() (*PoolStats, error) {
+	stats := &PoolStats{Workers: s.cfg.Workers, Active: []ActiveTask{}}
+
+	s.mu.Lock()
+	for _, task := range s.active {
+		stats.Active = append(stats.Active, *task)
+	}
+	s.mu.Unlock()
+	sort.Slice(stats.Active, func(i, j int) bool { return stats.Active[i].Worker < stats.Active[j].Worker })
+	stats.Busy = len(stats.Active)
+
+	if err := s.db.Model(&model.Task{}).Where("status = ?", "pending").Count(&stats.Pending).Error; err != nil {
+		return nil, err
+	}
+	if err := s.db.Model(&model.Task{}).Where("status = ?", "running").Count(&stats.Running).Error; err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// heartbeat extends the lease of a running task until stop is closed. It also
+// picks up cancel requests made through another process and cancels the task.
+func (s *TaskService) heartbeat(taskID uint, cancel context.CancelFunc, stop <-chan struct{}) {
+	ticker := time.NewTicker(s.cfg.LeaseDuration / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			err := s.db.Model(&model.Task{}).
+				Where("id = ? AND lease_owner = ?", taskID, s.workerID).
+				Updates(map[string]interface{}{
+					"lease_expires_at": now.Add(s.cfg.LeaseDuration),
+					"heartbeat_at":     now,
+				}).Error
+			if err != nil {
+				log.Printf("Failed to heartbeat task %d: %v", taskID, err)
+				continue
+			}
+
+			var task model.Task
+			if err := s.db.Select("cancel_requested").First(&task, taskID).Error; err == nil && task.CancelRequested {
+				cancel()
+			}
+		}
+	}
+}
+
+func (s *TaskService) processTask(task *model.Task) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.cfg.TimeoutFor(task.Type))
+	defer cancel()
+	ctx = WithProgress(ctx, func(kind, step, message string) {
+		s.events.publish(task.ID, kind, step, message)
+	})
+
+	s.mu.Lock()
+	s.cancels[task.ID] = cancel
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.cancels, task.ID)
+		s.mu.Unlock()
+	}()
 
-	// Update Status to Running
-	task.Status = "running"
-	s.db.Save(&task)
+	stop := make(chan struct{})
+	go s.heartbeat(task.ID, cancel, stop)
 
+	s.events.publish(task.ID, "status", "running", fmt.Sprintf("Attempt %d started", task.Attempts))
+
+	startedAt := time.Now()
 	var err error
-	if task.Type == "deploy" {
-		err = s.handleDeploy(task)
-	} else {
-		err = fmt.Errorf("unknown task type: %s", task.Type)
+	switch task.Type {
+	case "deploy":
+		err = s.handleDeploy(ctx, *task)
+	case "upgrade":
+		err = s.handleUpgrade(ctx, *task)
+	case "rollback":
+		err = s.handleRollback(ctx, *task)
+	case "uninstall":
+		err = s.handleUninstall(ctx, *task)
+	case "resync":
+		err = s.handleResync(ctx, *task)
+	default:
+		err = permanent(fmt.Errorf("unknown task type: %s", task.Type))
 	}
+	close(stop)
 
 	// Update Status based on result
+	updates := map[string]interface{}{
+		"status":           "completed",
+		"result":           "Success",
+		"lease_owner":      "",
+		"lease_expires_at": nil,
+	}
 	if err != nil {
-		task.Status = "failed"
-		task.Result = err.Error()
+		var current model.Task
+		s.db.Select("cancel_requested").First(&current, task.ID)
+
+		policy := s.cfg.RetryFor(task.Type)
+		attemptLog := append(task.AttemptLog, model.TaskAttempt{
+			Attempt:    task.Attempts,
+			Error:      err.Error(),
+			StartedAt:  startedAt,
+			FinishedAt: time.Now(),
+		})
+		updates["attempt_log"] = attemptLog
+		updates["result"] = err.Error()
+
+		switch {
+		case current.CancelRequested:
+			updates["status"] = "cancelled"
+			updates["result"] = "Cancelled: " + err.Error()
+		case isRetryable(err) && task.Attempts < policy.MaxAttempts:
+			updates["status"] = "pending"
+			updates["next_run_at"] = time.Now().Add(policy.Backoff(task.Attempts))
+		default:
+			updates["status"] = "failed"
+		}
+	}
+
+	result := s.db.Model(&model.Task{}).Where("id = ? AND lease_owner = ?", task.ID, s.workerID).Updates(updates)
+	if result.Error != nil {
+		log.Printf("Failed to save result of task %d: %v", task.ID, result.Error)
+	} else if result.RowsAffected == 0 {
+		log.Printf("Lost lease on task %d before it finished", task.ID)
 	} else {
-		task.Status = "completed"
-		task.Result = "Success"
+		s.events.publish(task.ID, "status", updates["status"].(string), updates["result"].(string))
+		s.notify(task, updates["status"].(string), updates["result"].(string))
 	}
-	s.db.Save(&task)
 }
 
-func (s *TaskService) handleDeploy(task model.Task) error {
-	// 1. Unmarshal payload to DeployRequest
-	// We need to marshal it back to bytes first because JSONMap is map[string]interface{}
-	payloadBytes, err := json.Marshal(task.Payload)
-	if err != nil {
-		return fmt.Errorf("failed to marshal payload: %w", err)
+// notify emits the webhook events for a task that finished
+func (s *TaskService) notify(task *model.Task, status, result string) {
+	// Reload for the instance a deploy task was linked to
+	var current model.Task
+	if err := s.db.First(&current, task.ID).Error; err == nil {
+		task = &current
+	}
+
+	clusterID, namespace, releaseName := releaseOf(task.LockKey)
+	data := map[string]interface{}{
+		"task_id":      task.ID,
+		"type":         task.Type,
+		"status":       status,
+		"result":       result,
+		"user_id":      task.UserID,
+		"chart_id":     task.ChartID,
+		"instance_id":  task.InstanceID,
+		"cluster_id":   clusterID,
+		"namespace":    namespace,
+		"release_name": releaseName,
+	}
+	switch status {
+	case "completed":
+		s.webhooks.Emit(EventTaskCompleted, data)
+		if task.Type == "uninstall" {
+			s.webhooks.Emit(EventInstanceDeleted, data)
+		}
+	case "failed":
+		s.webhooks.Emit(EventTaskFailed, data)
+	}
+}
+
+// permanentError marks a failure that will recur on every attempt
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// permanent marks err as not worth retrying
+func permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+// isRetryable reports whether a failed attempt may succeed when run again.
+// Invalid configuration, missing instances, charts or clusters, forbidden
+// namespaces and exceeded quotas fail the same way every time.
+func isRetryable(err error) bool {
+	var verr *helm.ValidationError
+	var perr *permanentError
+	switch {
+	case errors.As(err, &verr), errors.As(err, &perr),
+		errors.Is(err, ErrNamespaceForbidden),
+		errors.Is(err, ErrQuotaExceeded),
+		errors.Is(err, ErrInstanceNotFound),
+		errors.Is(err, ErrChartVersionNotFound),
+		errors.Is(err, ErrClusterNotFound):
+		return false
+	}
+	// Helm reports a release name taken by another release only as text
+	return !strings.Contains(err.Error(), "cannot re-use a name that is still in use")
+}
+
+// CancelTask cancels a task. Pending tasks and tasks awaiting approval are
+// cancelled right away, running ones have their context cancelled and end up
+// "cancelled" once the handler returns.
+func (s *TaskService) CancelTask(id uint) (*model.Task, error) {
+	result := s.db.Model(&model.Task{}).
+		Where("id = ? AND status IN ?", id, []string{"pending", "awaiting_approval"}).
+		Updates(map[string]interface{}{"status": "cancelled", "result": "Cancelled", "cancel_requested": true})
+	if result.Error != nil {
+		return nil, result.Error
 	}
+	if result.RowsAffected > 0 {
+		err := s.db.Model(&model.Approval{}).Where("task_id = ? AND status = ?", id, "pending").Update("status", "cancelled").Error
+		if err != nil {
+			log.Printf("Failed to cancel approval of task %d: %v", id, err)
+		}
+		s.events.publish(id, "status", "cancelled", "Cancelled")
+	}
+
+	if result.RowsAffected == 0 {
+		result = s.db.Model(&model.Task{}).
+			Where("id = ? AND status = ?", id, "running").
+			Update("cancel_requested", true)
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil, fmt.Errorf("task is not pending or running")
+		}
+
+		// Cancel immediately if the task runs in this process, otherwise the
+		// owning worker notices on its next heartbeat
+		s.mu.Lock()
+		if cancel, ok := s.cancels[id]; ok {
+			cancel()
+		}
+		s.mu.Unlock()
+	}
+
+	return s.GetTask(id)
+}
 
+func (s *TaskService) handleDeploy(ctx context.Context, task model.Task) error {
+	// 1. Unmarshal payload to DeployRequest
 	var req DeployRequest
-	if err := json.Unmarshal(payloadBytes, &req); err != nil {
-		return fmt.Errorf("failed to unmarshal deploy request: %w", err)
+	if err := decodePayload(task, &req); err != nil {
+		return permanent(fmt.Errorf("failed to unmarshal deploy request: %w", err))
 	}
+	req.TaskID = task.ID
 
 	// 2. Call Deploy Service
-	// Create a background context with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
-	defer cancel()
+	instance, err := s.deployService.Deploy(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	// 3. Link the task to the instance it created
+	if err := s.db.Model(&model.Task{}).Where("id = ?", task.ID).Update("instance_id", instance.ID).Error; err != nil {
+		log.Printf("Failed to link task %d to instance %d: %v", task.ID, instance.ID, err)
+	}
+	return nil
+}
+
+func (s *TaskService) handleUpgrade(ctx context.Context, task model.Task) error {
+	var req UpgradeRequest
+	if err := decodePayload(task, &req); err != nil {
+		return permanent(fmt.Errorf("failed to unmarshal upgrade request: %w", err))
+	}
+	req.TaskID = task.ID
+
+	_, err := s.deployService.Upgrade(ctx, req)
+	return err
+}
+
+func (s *TaskService) handleRollback(ctx context.Context, task model.Task) error {
+	var req RollbackRequest
+	if err := decodePayload(task, &req); err != nil {
+		return permanent(fmt.Errorf("failed to unmarshal rollback request: %w", err))
+	}
+	req.TaskID = task.ID
 
-	_, err = s.deployService.Deploy(ctx, req)
+	_, err := s.deployService.Rollback(ctx, req)
 	return err
 }
 
-// EnqueueDeploy creates a task and queues it
+func (s *TaskService) handleUninstall(ctx context.Context, task model.Task) error {
+	var req UninstallRequest
+	if err := decodePayload(task, &req); err != nil {
+		return permanent(fmt.Errorf("failed to unmarshal uninstall request: %w", err))
+	}
+	req.TaskID = task.ID
+
+	return s.deployService.Uninstall(ctx, req)
+}
+
+func (s *TaskService) handleResync(ctx context.Context, task model.Task) error {
+	var req ResyncRequest
+	if err := decodePayload(task, &req); err != nil {
+		return permanent(fmt.Errorf("failed to unmarshal resync request: %w", err))
+	}
+	req.TaskID = task.ID
+
+	_, err := s.deployService.Resync(ctx, req)
+	return err
+}
+
+// decodePayload converts the stored task payload back into a request struct.
+// We need to marshal it back to bytes first because JSONMap is map[string]interface{}
+func decodePayload(task model.Task, out interface{}) error {
+	payloadBytes, err := json.Marshal(task.Payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %w", err)
+	}
+	return json.Unmarshal(payloadBytes, out)
+}
+
+// EnqueueDeploy creates a task and queues it. The target cluster is resolved
+// now so the task keeps deploying there even if the default changes.
+// Like the other tasks changing a release, deployments covered by an approval
+// policy are held as "awaiting_approval" until an approver releases them.
 func (s *TaskService) EnqueueDeploy(userID string, req DeployRequest) (*model.Task, error) {
-	// Convert request to map for JSONMap storage
-	payloadBytes, err := json.Marshal(req)
+	clusterID, err := s.deployService.clusterService.ResolveClusterID(req.ClusterID)
 	if err != nil {
 		return nil, err
 	}
-	var payload map[string]interface{}
-	json.Unmarshal(payloadBytes, &payload)
+	req.ClusterID = clusterID
 
 	task := &model.Task{
-		Type:      "deploy",
-		Status:    "pending",
-		UserID:    userID,
-		Payload:   model.JSONMap(payload),
-		CreatedAt: time.Now(),
+		Type:    "deploy",
+		UserID:  userID,
+		LockKey: lockKey(req.ClusterID, req.Namespace, req.ReleaseName),
+		ChartID: req.ChartID,
+	}
+	return s.enqueueOrHold(task, req)
+}
+
+// EnqueueUpgrade creates an upgrade task for an existing instance and queues
+// it, or holds it for approval
+func (s *TaskService) EnqueueUpgrade(userID string, req UpgradeRequest) (*model.Task, error) {
+	task, err := s.instanceTask("upgrade", userID, req.InstanceID)
+	if err != nil {
+		return nil, err
+	}
+	return s.enqueueOrHold(task, req)
+}
+
+// EnqueueRollback creates a rollback task for an existing instance and queues
+// it, or holds it for approval
+func (s *TaskService) EnqueueRollback(userID string, req RollbackRequest) (*model.Task, error) {
+	task, err := s.instanceTask("rollback", userID, req.InstanceID)
+	if err != nil {
+		return nil, err
+	}
+	return s.enqueueOrHold(task, req)
+}
+
+// ErrUninstallQueued is returned when an instance already has an uninstall
+// pending or running
+var ErrUninstallQueued = errors.New("instance is already being uninstalled")
+
+// EnqueueUninstall marks the instance as uninstalling and queues its removal.
+// An uninstall held for approval leaves the instance alone until approved.
+func (s *TaskService) EnqueueUninstall(userID string, req UninstallRequest) (*model.Task, error) {
+	task, err := s.instanceTask("uninstall", userID, req.InstanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	clusterID, namespace, _ := releaseOf(task.LockKey)
+	policies, err := s.approvalService.policiesFor(clusterID, task.ChartID, namespace)
+	if err != nil {
+		return nil, err
+	}
+	if len(policies) > 0 {
+		return s.holdForApproval(task, req)
 	}
 
+	payload, err := toPayload(req)
+	if err != nil {
+		return nil, err
+	}
+	task.Status = "pending"
+	task.Payload = payload
+	task.CreatedAt = time.Now()
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		var queued int64
+		err := tx.Model(&model.Task{}).
+			Where("instance_id = ? AND type = ? AND status IN ?", req.InstanceID, "uninstall", []string{"pending", "running"}).
+			Count(&queued).Error
+		if err != nil {
+			return err
+		}
+		if queued > 0 {
+			return ErrUninstallQueued
+		}
+		return tx.Create(task).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.wakeWorker()
+	s.markUninstalling(req.InstanceID)
+	return task, nil
+}
+
+func (s *TaskService) markUninstalling(instanceID uint) {
+	if err := s.db.Model(&model.AppInstance{}).Where("id = ?", instanceID).Update("status", "uninstalling").Error; err != nil {
+		log.Printf("Failed to mark instance %d as uninstalling: %v", instanceID, err)
+	}
+}
+
+// EnqueueResync queues re-applying an instance's recorded configuration, or
+// holds it for approval
+func (s *TaskService) EnqueueResync(userID string, req ResyncRequest) (*model.Task, error) {
+	task, err := s.instanceTask("resync", userID, req.InstanceID)
+	if err != nil {
+		return nil, err
+	}
+	return s.enqueueOrHold(task, req)
+}
+
+// lockKey identifies the release a task operates on; tasks with the same key
+// are never run concurrently
+func lockKey(clusterID uint, namespace, releaseName string) string {
+	return fmt.Sprintf("%d/%s/%s", clusterID, namespace, releaseName)
+}
+
+// releaseOf splits a lock key back into the release it identifies. Neither
+// namespaces nor release names can contain a slash.
+func releaseOf(key string) (clusterID uint, namespace, releaseName string) {
+	parts := strings.SplitN(key, "/", 3)
+	if len(parts) != 3 {
+		return 0, "", ""
+	}
+	if id, err := strconv.ParseUint(parts[0], 10, 64); err == nil {
+		clusterID = uint(id)
+	}
+	return clusterID, parts[1], parts[2]
+}
+
+// instanceTask prepares a task operating on an existing instance
+func (s *TaskService) instanceTask(taskType, userID string, instanceID uint) (*model.Task, error) {
+	var instance model.AppInstance
+	if err := s.db.First(&instance, instanceID).Error; err != nil {
+		return nil, fmt.Errorf("instance not found: %w", err)
+	}
+	return &model.Task{
+		Type:       taskType,
+		UserID:     userID,
+		LockKey:    lockKey(instance.ClusterID, instance.Namespace, instance.Name),
+		ChartID:    instance.ChartID,
+		InstanceID: &instance.ID,
+	}, nil
+}
+
+func (s *TaskService) enqueue(task *model.Task, req interface{}) (*model.Task, error) {
+	payload, err := toPayload(req)
+	if err != nil {
+		return nil, err
+	}
+
+	task.Status = "pending"
+	task.Payload = payload
+	task.CreatedAt = time.Now()
+
 	if err := s.db.Create(task).Error; err != nil {
 		return nil, err
 	}
 
-	// Push to queue (non-blocking if full, though unlikely with buffer 100)
+	// Once the row exists the task is durable; wake a worker so it does not
+	// have to wait for the next poll
+	s.wakeWorker()
+
+	return task, nil
+}
+
+// wakeWorker nudges an idle worker (non-blocking, idle workers poll anyway)
+func (s *TaskService) wakeWorker() {
 	select {
-	case s.taskQueue <- task.ID:
+	case s.wake <- struct{}{}:
 	default:
-		return nil, fmt.Errorf("task queue is full")
 	}
+}
 
-	return task, nil
+// toPayload converts a request to a map for JSONMap storage
+func toPayload(req interface{}) (model.JSONMap, error) {
+	payloadBytes, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	var payload map[string]interface{}
+	json.Unmarshal(payloadBytes, &payload)
+	return model.JSONMap(payload), nil
+}
+
+// TaskFilter selects tasks for ListTasks. Zero values match everything.
+type TaskFilter struct {
+	UserID     string
+	Status     string
+	Type       string
+	ChartID    string
+	InstanceID uint
+	From       *time.Time // created at or after
+	To         *time.Time // created before
+	Sort       string     // created_at, updated_at, status, type; defaults to created_at
+	Order      string     // asc or desc; defaults to desc
+	Page       int
+	Limit      int
+}
+
+// TaskListOutput is a page of tasks
+type TaskListOutput struct {
+	Tasks []model.Task `json:"tasks"`
+	Total int64        `json:"total"`
+	Page  int          `json:"page"`
+	Limit int          `json:"limit"`
+}
+
+var taskSortColumns = map[string]bool{
+	"id":         true,
+	"created_at": true,
+	"updated_at": true,
+	"status":     true,
+	"type":       true,
+}
+
+// ListTasks returns a page of tasks matching the filter
+func (s *TaskService) ListTasks(filter TaskFilter) (*TaskListOutput, error) {
+	if filter.Page < 1 {
+		filter.Page = 1
+	}
+	if filter.Limit < 1 || filter.Limit > 100 {
+		filter.Limit = 20
+	}
+
+	sortField := filter.Sort
+	if sortField == "" {
+		sortField = "created_at"
+	}
+	if !taskSortColumns[sortField] {
+		return nil, fmt.Errorf("invalid sort field: %s", sortField)
+	}
+	order := strings.ToLower(filter.Order)
+	if order == "" {
+		order = "desc"
+	}
+	if order != "asc" && order != "desc" {
+		return nil, fmt.Errorf("invalid sort order: %s", filter.Order)
+	}
+
+	query := s.db.Model(&model.Task{})
+	if filter.UserID != "" {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.Type != "" {
+		query = query.Where("type = ?", filter.Type)
+	}
+	if filter.ChartID != "" {
+		query = query.Where("chart_id = ?", filter.ChartID)
+	}
+	if filter.InstanceID != 0 {
+		query = query.Where("instance_id = ?", filter.InstanceID)
+	}
+	if filter.From != nil {
+		query = query.Where("created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("created_at < ?", *filter.To)
+	}
+
+	output := &TaskListOutput{Tasks: []model.Task{}, Page: filter.Page, Limit: filter.Limit}
+	if err := query.Count(&output.Total).Error; err != nil {
+		return nil, fmt.Errorf("failed to count tasks: %w", err)
+	}
+
+	err := query.Order(sortField + " " + order).Order("id " + order).
+		Offset((filter.Page - 1) * filter.Limit).Limit(filter.Limit).
+		Find(&output.Tasks).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tasks: %w", err)
+	}
+	return output, nil
 }
 
 // GetTask retrieves a task
@@ -122,3 +783,21 @@ func (s *TaskService) GetTask(id uint) (*model.Task, error) {
 	err := s.db.First(&task, id).Error
 	return &task, err
 }
+
+// IsTerminal reports whether a task has finished and will not run again
+func IsTerminal(task *model.Task) bool {
+	return task.Status == "completed" || task.Status == "failed" || task.Status == "cancelled" || task.Status == "rejected"
+}
+
+// ListEvents returns the events of a task with an ID greater than afterID, oldest first
+func (s *TaskService) ListEvents(taskID, afterID uint) ([]model.TaskEvent, error) {
+	var events []model.TaskEvent
+	err := s.db.Where("task_id = ? AND id > ?", taskID, afterID).Order("id").Find(&events).Error
+	return events, err
+}
+
+// SubscribeEvents returns a channel signalled whenever a worker of this process
+// publishes an event for the task. The returned func must be called to unsubscribe.
+func (s *TaskService) SubscribeEvents(taskID uint) (<-chan struct{}, func()) {
+	return s.events.subscribe(taskID)
+}
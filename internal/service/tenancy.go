@@ -0,0 +1,325 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/your-org/app-market/internal/config"
+	"github.com/your-org/app-market/internal/helm"
+	"github.com/your-org/app-market/internal/model"
+	"gorm.io/gorm"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// Grant subject types
+const (
+	SubjectUser  = "user"
+	SubjectGroup = "group"
+)
+
+// ErrNamespaceForbidden is returned when a user may not deploy into a namespace
+var ErrNamespaceForbidden = errors.New("namespace not allowed")
+
+// namespacePrefix is a DNS-1123 label that may still be continued
+var namespacePrefix = regexp.MustCompile(`^[a-z0-9][-a-z0-9]*$`)
+
+// TenancyService decides which namespaces users own. Namespaces are granted
+// to users or groups by name or prefix; admins may deploy anywhere except
+// into the denied system namespaces.
+type TenancyService struct {
+	db  *gorm.DB
+	cfg config.TenancyConfig
+}
+
+func NewTenancyService(db *gorm.DB, cfg config.TenancyConfig) *TenancyService {
+	return &TenancyService{db: db, cfg: cfg}
+}
+
+// GrantInput assigns a namespace or namespace prefix to a user or group
+type GrantInput struct {
+	ClusterID   *uint
+	Namespace   string
+	Prefix      bool
+	SubjectType string
+	SubjectID   string
+}
+
+// NamespaceAccess lists the namespaces a user may deploy into
+type NamespaceAccess struct {
+	Unrestricted bool                   `json:"unrestricted"` // Admins may use every namespace that is not denied
+	Grants       []model.NamespaceGrant `json:"grants"`
+	Denied       []string               `json:"denied"`
+}
+
+// CheckNamespace returns ErrNamespaceForbidden unless the user may deploy
+// into the namespace of the cluster
+func (s *TenancyService) CheckNamespace(userID string, clusterID uint, namespace string) error {
+	if errs := validation.IsDNS1123Label(namespace); len(errs) > 0 {
+		return &helm.ValidationError{
+			Message: "invalid namespace",
+			Fields:  []helm.FieldError{{Path: "namespace", Message: strings.Join(errs, "; ")}},
+		}
+	}
+	if s.isDenied(namespace) {
+		return fmt.Errorf("%w: %s is a protected system namespace", ErrNamespaceForbidden, namespace)
+	}
+
+	role, err := s.userRole(userID)
+	if err != nil {
+		return err
+	}
+	if role == "admin" {
+		return nil
+	}
+
+	grants, err := s.grantsFor(userID)
+	if err != nil {
+		return err
+	}
+	for i := range grants {
+		if grants[i].Matches(clusterID, namespace) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s has not been assigned to you", ErrNamespaceForbidden, namespace)
+}
+
+// Access returns the namespaces a user may deploy into
+func (s *TenancyService) Access(userID string) (*NamespaceAccess, error) {
+	role, err := s.userRole(userID)
+	if err != nil {
+		return nil, err
+	}
+	grants, err := s.grantsFor(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	denied := s.cfg.DeniedNamespaces
+	if denied == nil {
+		denied = []string{}
+	}
+	return &NamespaceAccess{Unrestricted: role == "admin", Grants: grants, Denied: denied}, nil
+}
+
+// scopeInstances restricts an AppInstance query to the instances a user owns
+// or that live in namespaces granted to them
+func (s *TenancyService) scopeInstances(query *gorm.DB, userID string) (*gorm.DB, error) {
+	grants, err := s.grantsFor(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	cond := s.db.Where("user_id = ?", userID)
+	for _, g := range grants {
+		match := s.db
+		if g.Prefix {
+			// Grant namespaces are validated, so they contain no LIKE wildcards
+			match = match.Where("namespace LIKE ?", g.Namespace+"%")
+		} else {
+			match = match.Where("namespace = ?", g.Namespace)
+		}
+		if g.ClusterID != nil {
+			match = match.Where("cluster_id = ?", *g.ClusterID)
+		}
+		cond = cond.Or(match)
+	}
+	return query.Where(cond), nil
+}
+
+func (s *TenancyService) isDenied(namespace string) bool {
+	for _, denied := range s.cfg.DeniedNamespaces {
+		if prefix, ok := strings.CutSuffix(denied, "*"); ok {
+			if strings.HasPrefix(namespace, prefix) {
+				return true
+			}
+		} else if namespace == denied {
+			return true
+		}
+	}
+	return false
+}
+
+// userRole returns the role of a user. Users are identified by their
+// username everywhere, which is also the subject of their JWT.
+func (s *TenancyService) userRole(userID string) (string, error) {
+	var user model.User
+	if err := s.db.Select("role").First(&user, "username = ?", userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", fmt.Errorf("user not found")
+		}
+		return "", fmt.Errorf("failed to get user: %w", err)
+	}
+	return user.Role, nil
+}
+
+// grantsFor returns the grants of a user and of the groups they belong to
+func (s *TenancyService) grantsFor(userID string) ([]model.NamespaceGrant, error) {
+	var groupIDs []uint
+	if err := s.db.Model(&model.GroupMember{}).Where("user_id = ?", userID).Pluck("group_id", &groupIDs).Error; err != nil {
+		return nil, fmt.Errorf("failed to get groups: %w", err)
+	}
+	groups := make([]string, len(groupIDs))
+	for i, id := range groupIDs {
+		groups[i] = strconv.FormatUint(uint64(id), 10)
+	}
+
+	grants := []model.NamespaceGrant{}
+	query := s.db.Where("subject_type = ? AND subject_id = ?", SubjectUser, userID)
+	if len(groups) > 0 {
+		query = query.Or("subject_type = ? AND subject_id IN ?", SubjectGroup, groups)
+	}
+	if err := query.Order("namespace").Find(&grants).Error; err != nil {
+		return nil, fmt.Errorf("failed to get namespace grants: %w", err)
+	}
+	return grants, nil
+}
+
+// CreateGroup creates an empty group
+func (s *TenancyService) CreateGroup(name, description string) (*model.Group, error) {
+	group := &model.Group{Name: name, Description: description, Members: []model.GroupMember{}}
+	if err := s.db.Create(group).Error; err != nil {
+		return nil, fmt.Errorf("failed to create group: %w", err)
+	}
+	return group, nil
+}
+
+// ListGroups returns all groups with their members
+func (s *TenancyService) ListGroups() ([]model.Group, error) {
+	groups := []model.Group{}
+	err := s.db.Preload("Members").Order("name").Find(&groups).Error
+	return groups, err
+}
+
+// DeleteGroup removes a group together with its members and grants and
+// returns the group as it was
+func (s *TenancyService) DeleteGroup(id uint) (*model.Group, error) {
+	var group model.Group
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Preload("Members").First(&group, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("group not found")
+			}
+			return fmt.Errorf("failed to get group: %w", err)
+		}
+		if err := tx.Delete(&model.Group{}, id).Error; err != nil {
+			return fmt.Errorf("failed to delete group: %w", err)
+		}
+		if err := tx.Where("group_id = ?", id).Delete(&model.GroupMember{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("subject_type = ? AND subject_id = ?", SubjectGroup, strconv.FormatUint(uint64(id), 10)).
+			Delete(&model.NamespaceGrant{}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
+// AddGroupMember puts a user into a group
+func (s *TenancyService) AddGroupMember(groupID uint, userID string) (*model.GroupMember, error) {
+	if err := s.db.First(&model.Group{}, groupID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("group not found")
+		}
+		return nil, fmt.Errorf("failed to get group: %w", err)
+	}
+	if _, err := s.userRole(userID); err != nil {
+		return nil, err
+	}
+
+	member := &model.GroupMember{GroupID: groupID, UserID: userID}
+	if err := s.db.Where(member).FirstOrCreate(member).Error; err != nil {
+		return nil, fmt.Errorf("failed to add group member: %w", err)
+	}
+	return member, nil
+}
+
+// RemoveGroupMember takes a user out of a group
+func (s *TenancyService) RemoveGroupMember(groupID uint, userID string) error {
+	result := s.db.Where("group_id = ? AND user_id = ?", groupID, userID).Delete(&model.GroupMember{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to remove group member: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("user is not a member of the group")
+	}
+	return nil
+}
+
+// CreateGrant assigns a namespace or namespace prefix to a user or group
+func (s *TenancyService) CreateGrant(input GrantInput) (*model.NamespaceGrant, error) {
+	if input.Prefix {
+		if len(input.Namespace) > validation.DNS1123LabelMaxLength || !namespacePrefix.MatchString(input.Namespace) {
+			return nil, fmt.Errorf("invalid namespace prefix %q", input.Namespace)
+		}
+	} else if errs := validation.IsDNS1123Label(input.Namespace); len(errs) > 0 {
+		return nil, fmt.Errorf("invalid namespace %q: %s", input.Namespace, strings.Join(errs, "; "))
+	}
+
+	switch input.SubjectType {
+	case SubjectUser:
+		if _, err := s.userRole(input.SubjectID); err != nil {
+			return nil, err
+		}
+	case SubjectGroup:
+		if err := s.db.First(&model.Group{}, "id = ?", input.SubjectID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, fmt.Errorf("group not found")
+			}
+			return nil, fmt.Errorf("failed to get group: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("invalid subject type: %s", input.SubjectType)
+	}
+
+	grant := &model.NamespaceGrant{
+		ClusterID:   input.ClusterID,
+		Namespace:   input.Namespace,
+		Prefix:      input.Prefix,
+		SubjectType: input.SubjectType,
+		SubjectID:   input.SubjectID,
+	}
+	if err := s.db.Create(grant).Error; err != nil {
+		return nil, fmt.Errorf("failed to create namespace grant: %w", err)
+	}
+	return grant, nil
+}
+
+// ListGrants returns namespace grants, optionally only those of one subject
+func (s *TenancyService) ListGrants(subjectType, subjectID string) ([]model.NamespaceGrant, error) {
+	query := s.db.Model(&model.NamespaceGrant{})
+	if subjectType != "" {
+		query = query.Where("subject_type = ?", subjectType)
+	}
+	if subjectID != "" {
+		query = query.Where("subject_id = ?", subjectID)
+	}
+
+	grants := []model.NamespaceGrant{}
+	err := query.Order("namespace").Find(&grants).Error
+	return grants, err
+}
+
+// DeleteGrant revokes a namespace grant and returns it as it was
+func (s *TenancyService) DeleteGrant(id uint) (*model.NamespaceGrant, error) {
+	var grant model.NamespaceGrant
+	if err := s.db.First(&grant, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("namespace grant not found")
+		}
+		return nil, fmt.Errorf("failed to get namespace grant: %w", err)
+	}
+	result := s.db.Delete(&model.NamespaceGrant{}, id)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to delete namespace grant: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, fmt.Errorf("namespace grant not found")
+	}
+	return &grant, nil
+}
@@ -0,0 +1,139 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/your-org/app-market/internal/config"
+	"github.com/your-org/app-market/internal/model"
+	"github.com/your-org/app-market/internal/repository"
+	"gorm.io/gorm"
+)
+
+// newTestDB returns a migrated in-memory database private to the test
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := fmt.Sprintf("file:%s?mode=memory&cache=shared", strings.ReplaceAll(t.Name(), "/", "_"))
+	db, err := repository.NewDB(config.DatabaseConfig{Driver: "sqlite", DSN: dsn})
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+func TestDeployAsNonAdminThroughGroupGrant(t *testing.T) {
+	db := newTestDB(t)
+	tenancy := NewTenancyService(db, config.TenancyConfig{DeniedNamespaces: []string{"kube-*"}})
+	deploy := NewDeployService(db, NewChartService(db, nil), NewClusterService(db, nil), tenancy,
+		NewQuotaService(db, config.QuotaConfig{}), nil)
+
+	if err := db.Create(&model.User{Username: "alice", Password: "x", Role: "user"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&model.Chart{RepoID: 1, Name: "nginx", Published: true}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&model.ChartVersion{ChartID: 1, Version: "1.0.0"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	group, err := tenancy.CreateGroup("team-a", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tenancy.AddGroupMember(group.ID, "alice"); err != nil {
+		t.Fatalf("AddGroupMember: %v", err)
+	}
+	if _, err := tenancy.CreateGrant(GrantInput{
+		Namespace:   "team-a-",
+		Prefix:      true,
+		SubjectType: SubjectGroup,
+		SubjectID:   fmt.Sprint(group.ID),
+	}); err != nil {
+		t.Fatalf("CreateGrant: %v", err)
+	}
+
+	tests := []struct {
+		namespace string
+		wantErr   error
+	}{
+		{"team-a-dev", nil},
+		{"team-b", ErrNamespaceForbidden},
+		{"kube-system", ErrNamespaceForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.namespace, func(t *testing.T) {
+			err := deploy.ValidateDeploy(DeployRequest{
+				UserID:      "alice",
+				ChartID:     "1",
+				Version:     "1.0.0",
+				ReleaseName: "web",
+				Namespace:   tt.namespace,
+			}, false)
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("ValidateDeploy: unexpected error %v", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ValidateDeploy: got %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGetInstanceFollowsNamespaceGrants(t *testing.T) {
+	db := newTestDB(t)
+	tenancy := NewTenancyService(db, config.TenancyConfig{})
+	deploy := NewDeployService(db, NewChartService(db, nil), NewClusterService(db, nil), tenancy,
+		NewQuotaService(db, config.QuotaConfig{}), nil)
+
+	for _, name := range []string{"alice", "bob", "carol"} {
+		if err := db.Create(&model.User{Username: name, Password: "x", Role: "user"}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := tenancy.CreateGrant(GrantInput{Namespace: "team-a", SubjectType: SubjectUser, SubjectID: "alice"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tenancy.CreateGrant(GrantInput{Namespace: "team-a", SubjectType: SubjectUser, SubjectID: "bob"}); err != nil {
+		t.Fatal(err)
+	}
+	instance := &model.AppInstance{Name: "web", Namespace: "team-a", UserID: "bob"}
+	if err := db.Create(instance).Error; err != nil {
+		t.Fatal(err)
+	}
+	id := fmt.Sprint(instance.ID)
+
+	tests := []struct {
+		user    string
+		visible bool
+	}{
+		{"bob", true},    // owner
+		{"alice", true},  // teammate through the namespace grant
+		{"carol", false}, // no grant
+	}
+	for _, tt := range tests {
+		t.Run(tt.user, func(t *testing.T) {
+			listed, err := deploy.ListInstances(tt.user, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := len(listed) == 1; got != tt.visible {
+				t.Errorf("ListInstances: listed %d instances, want visible=%v", len(listed), tt.visible)
+			}
+
+			_, err = deploy.GetInstance(id, tt.user)
+			switch {
+			case tt.visible && err != nil:
+				t.Errorf("GetInstance: unexpected error %v", err)
+			case !tt.visible && !errors.Is(err, ErrInstanceNotFound):
+				t.Errorf("GetInstance: got %v, want ErrInstanceNotFound", err)
+			}
+		})
+	}
+}
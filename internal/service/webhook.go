@@ -0,0 +1,458 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/your-org/app-market/internal/config"
+	"github.com/your-org/app-market/internal/model"
+	"github.com/your-org/app-market/pkg/crypto"
+	"gorm.io/gorm"
+)
+
+// Webhook events
+const (
+	EventTaskCompleted   = "task.completed"
+	EventTaskFailed      = "task.failed"
+	EventInstanceDeleted = "instance.deleted"
+	EventChartPublished  = "chart.published"
+	EventRepoSyncFailed  = "repo.sync_failed"
+	// EventPing is only sent by TestWebhook
+	EventPing = "ping"
+)
+
+// WebhookEvents lists the events a webhook can subscribe to
+var WebhookEvents = []string{EventTaskCompleted, EventTaskFailed, EventInstanceDeleted, EventChartPublished, EventRepoSyncFailed}
+
+// Headers sent with every delivery. The signature is the hex encoded
+// HMAC-SHA256 of the request body keyed with the webhook secret.
+const (
+	WebhookEventHeader     = "X-AppMarket-Event"
+	WebhookDeliveryHeader  = "X-AppMarket-Delivery"
+	WebhookSignatureHeader = "X-AppMarket-Signature"
+)
+
+const (
+	// webhookBatch is the number of due deliveries sent per poll
+	webhookBatch = 20
+	// webhookResponseLimit caps the response body kept in the delivery log
+	webhookResponseLimit = 1024
+)
+
+// WebhookService manages webhook endpoints and delivers events to them.
+// Deliveries are stored before they are sent so they survive restarts.
+type WebhookService struct {
+	db     *gorm.DB
+	cipher *crypto.Cipher
+	cfg    config.WebhookConfig
+	client *http.Client
+	wake   chan struct{}
+}
+
+func NewWebhookService(db *gorm.DB, cipher *crypto.Cipher, cfg config.WebhookConfig) *WebhookService {
+	return &WebhookService{
+		db:     db,
+		cipher: cipher,
+		cfg:    cfg,
+		client: &http.Client{Timeout: cfg.Timeout},
+		wake:   make(chan struct{}, 1),
+	}
+}
+
+// WebhookInput registers or updates a webhook. On update, empty fields keep
+// their current value and a non-empty Secret replaces the current one.
+type WebhookInput struct {
+	Name    string
+	URL     string
+	Events  []string
+	Secret  string
+	Enabled *bool
+}
+
+// CreateWebhook registers a webhook and returns it with its signing secret,
+// which is generated if none is given. The secret is stored encrypted and not
+// returned again.
+func (s *WebhookService) CreateWebhook(input WebhookInput) (*model.Webhook, string, error) {
+	if input.Name == "" {
+		return nil, "", fmt.Errorf("webhook name is required")
+	}
+	webhook := &model.Webhook{Name: input.Name, Enabled: true}
+	if input.Enabled != nil {
+		webhook.Enabled = *input.Enabled
+	}
+	if err := applyWebhookTarget(webhook, input.URL, input.Events); err != nil {
+		return nil, "", err
+	}
+
+	secret := input.Secret
+	if secret == "" {
+		buf := make([]byte, 32)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, "", fmt.Errorf("failed to generate secret: %w", err)
+		}
+		secret = hex.EncodeToString(buf)
+	}
+	if err := s.setSecret(webhook, secret); err != nil {
+		return nil, "", err
+	}
+
+	if err := s.db.Create(webhook).Error; err != nil {
+		return nil, "", fmt.Errorf("failed to create webhook: %w", err)
+	}
+	return webhook, secret, nil
+}
+
+// UpdateWebhook changes a webhook
+func (s *WebhookService) UpdateWebhook(id uint, input WebhookInput) (*model.Webhook, error) {
+	webhook, err := s.GetWebhook(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if input.Name != "" {
+		webhook.Name = input.Name
+	}
+	if input.Enabled != nil {
+		webhook.Enabled = *input.Enabled
+	}
+	target, events := webhook.URL, []string(webhook.Events)
+	if input.URL != "" {
+		target = input.URL
+	}
+	if input.Events != nil {
+		events = input.Events
+	}
+	if err := applyWebhookTarget(webhook, target, events); err != nil {
+		return nil, err
+	}
+	if input.Secret != "" {
+		if err := s.setSecret(webhook, input.Secret); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.db.Save(webhook).Error; err != nil {
+		return nil, fmt.Errorf("failed to update webhook: %w", err)
+	}
+	return webhook, nil
+}
+
+func applyWebhookTarget(webhook *model.Webhook, target string, events []string) error {
+	u, err := url.Parse(target)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid webhook url: %s", target)
+	}
+	if len(events) == 0 {
+		return fmt.Errorf("at least one event is required")
+	}
+	for _, event := range events {
+		if event != "*" && !slices.Contains(WebhookEvents, event) {
+			return fmt.Errorf("unknown webhook event: %s", event)
+		}
+	}
+	webhook.URL = target
+	webhook.Events = model.StringArray(events)
+	return nil
+}
+
+func (s *WebhookService) setSecret(webhook *model.Webhook, secret string) error {
+	encrypted, err := s.cipher.Encrypt(secret)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt webhook secret: %w", err)
+	}
+	webhook.Secret = encrypted
+	return nil
+}
+
+// DeleteWebhook removes a webhook. Its pending deliveries are dropped when due.
+func (s *WebhookService) DeleteWebhook(id uint) error {
+	result := s.db.Delete(&model.Webhook{}, id)
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete webhook: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("webhook not found")
+	}
+	return nil
+}
+
+// ListWebhooks returns all registered webhooks
+func (s *WebhookService) ListWebhooks() ([]model.Webhook, error) {
+	webhooks := []model.Webhook{}
+	err := s.db.Order("name").Find(&webhooks).Error
+	return webhooks, err
+}
+
+// GetWebhook returns a registered webhook
+func (s *WebhookService) GetWebhook(id uint) (*model.Webhook, error) {
+	var webhook model.Webhook
+	if err := s.db.First(&webhook, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("webhook not found")
+		}
+		return nil, fmt.Errorf("failed to get webhook: %w", err)
+	}
+	return &webhook, nil
+}
+
+// DeliveryListOutput is a page of webhook deliveries
+type DeliveryListOutput struct {
+	Deliveries []model.WebhookDelivery `json:"deliveries"`
+	Total      int64                   `json:"total"`
+	Page       int                     `json:"page"`
+	Limit      int                     `json:"limit"`
+}
+
+// ListDeliveries returns a page of a webhook's deliveries, newest first. An
+// empty status matches all deliveries.
+func (s *WebhookService) ListDeliveries(webhookID uint, status string, page, limit int) (*DeliveryListOutput, error) {
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	query := s.db.Model(&model.WebhookDelivery{}).Where("webhook_id = ?", webhookID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	output := &DeliveryListOutput{Deliveries: []model.WebhookDelivery{}, Page: page, Limit: limit}
+	if err := query.Count(&output.Total).Error; err != nil {
+		return nil, fmt.Errorf("failed to count deliveries: %w", err)
+	}
+	err := query.Order("id DESC").Offset((page - 1) * limit).Limit(limit).Find(&output.Deliveries).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deliveries: %w", err)
+	}
+	return output, nil
+}
+
+// Redeliver queues a new delivery of an earlier delivery's payload. The
+// original stays in the log unchanged.
+func (s *WebhookService) Redeliver(deliveryID uint) (*model.WebhookDelivery, error) {
+	var original model.WebhookDelivery
+	if err := s.db.First(&original, deliveryID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("delivery not found")
+		}
+		return nil, fmt.Errorf("failed to get delivery: %w", err)
+	}
+	webhook, err := s.GetWebhook(original.WebhookID)
+	if err != nil {
+		return nil, err
+	}
+	return s.enqueue(webhook.ID, original.Event, original.Payload)
+}
+
+// TestWebhook queues a ping delivery to a webhook regardless of its event filter
+func (s *WebhookService) TestWebhook(id uint) (*model.WebhookDelivery, error) {
+	webhook, err := s.GetWebhook(id)
+	if err != nil {
+		return nil, err
+	}
+	return s.enqueue(webhook.ID, EventPing, webhookPayload(EventPing, map[string]interface{}{"webhook_id": webhook.ID}))
+}
+
+// Emit queues an event for every enabled webhook subscribed to it. Failures
+// are logged; emitting never fails the action that caused the event.
+func (s *WebhookService) Emit(event string, data interface{}) {
+	var webhooks []model.Webhook
+	if err := s.db.Where("enabled = ?", true).Find(&webhooks).Error; err != nil {
+		log.Printf("Failed to load webhooks for event %s: %v", event, err)
+		return
+	}
+
+	var payload model.JSONMap
+	for _, webhook := range webhooks {
+		if !slices.Contains(webhook.Events, event) && !slices.Contains(webhook.Events, "*") {
+			continue
+		}
+		if payload == nil {
+			payload = webhookPayload(event, data)
+		}
+		if _, err := s.enqueue(webhook.ID, event, payload); err != nil {
+			log.Printf("Failed to queue event %s for webhook %d: %v", event, webhook.ID, err)
+		}
+	}
+}
+
+func webhookPayload(event string, data interface{}) model.JSONMap {
+	return model.JSONMap{
+		"event":     event,
+		"timestamp": time.Now().UTC().Format(time.RFC3339),
+		"data":      data,
+	}
+}
+
+func (s *WebhookService) enqueue(webhookID uint, event string, payload model.JSONMap) (*model.WebhookDelivery, error) {
+	now := time.Now()
+	delivery := &model.WebhookDelivery{
+		WebhookID:     webhookID,
+		Event:         event,
+		Payload:       payload,
+		Status:        "pending",
+		NextAttemptAt: &now,
+	}
+	if err := s.db.Create(delivery).Error; err != nil {
+		return nil, fmt.Errorf("failed to queue delivery: %w", err)
+	}
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	return delivery, nil
+}
+
+// StartDispatcher sends due deliveries until ctx is cancelled
+func (s *WebhookService) StartDispatcher(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		s.dispatchDue(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.wake:
+		}
+	}
+}
+
+// dispatchDue sends a batch of due deliveries concurrently
+func (s *WebhookService) dispatchDue(ctx context.Context) {
+	var due []model.WebhookDelivery
+	err := s.db.Where("status = ? AND next_attempt_at <= ?", "pending", time.Now()).
+		Order("next_attempt_at").Limit(webhookBatch).Find(&due).Error
+	if err != nil {
+		log.Printf("Failed to load due webhook deliveries: %v", err)
+		return
+	}
+
+	var wg sync.WaitGroup
+	for i := range due {
+		if !s.claim(&due[i]) {
+			continue
+		}
+		wg.Add(1)
+		go func(d *model.WebhookDelivery) {
+			defer wg.Done()
+			s.attempt(ctx, d)
+		}(&due[i])
+	}
+	wg.Wait()
+}
+
+// claim counts the attempt and pushes the delivery past the attempt's
+// timeout, so other processes skip it and it is retried if this one dies
+func (s *WebhookService) claim(d *model.WebhookDelivery) bool {
+	next := time.Now().Add(s.cfg.Timeout + s.cfg.PollInterval)
+	result := s.db.Model(&model.WebhookDelivery{}).
+		Where("id = ? AND status = ? AND attempts = ?", d.ID, "pending", d.Attempts).
+		Updates(map[string]interface{}{"attempts": d.Attempts + 1, "next_attempt_at": next})
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false
+	}
+	d.Attempts++
+	return true
+}
+
+// attempt sends a claimed delivery once and records the outcome
+func (s *WebhookService) attempt(ctx context.Context, d *model.WebhookDelivery) {
+	updates := map[string]interface{}{}
+
+	statusCode, response, err := s.send(ctx, d)
+	updates["status_code"] = statusCode
+	updates["response"] = response
+	if err == nil {
+		now := time.Now()
+		updates["status"] = "delivered"
+		updates["error"] = ""
+		updates["delivered_at"] = &now
+		updates["next_attempt_at"] = nil
+	} else {
+		updates["error"] = err.Error()
+		if d.Attempts >= s.cfg.Retry.MaxAttempts || errors.Is(err, errWebhookGone) {
+			updates["status"] = "failed"
+			updates["next_attempt_at"] = nil
+		} else {
+			updates["next_attempt_at"] = time.Now().Add(s.cfg.Retry.Backoff(d.Attempts))
+		}
+	}
+
+	if err := s.db.Model(&model.WebhookDelivery{}).Where("id = ?", d.ID).Updates(updates).Error; err != nil {
+		log.Printf("Failed to save result of webhook delivery %d: %v", d.ID, err)
+	}
+}
+
+var errWebhookGone = errors.New("webhook was deleted or disabled")
+
+// send posts the signed payload and returns the response status and the
+// start of its body. Non-2xx responses are errors.
+func (s *WebhookService) send(ctx context.Context, d *model.WebhookDelivery) (int, string, error) {
+	var webhook model.Webhook
+	if err := s.db.First(&webhook, d.WebhookID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, "", errWebhookGone
+		}
+		return 0, "", err
+	}
+	if !webhook.Enabled && d.Event != EventPing {
+		return 0, "", errWebhookGone
+	}
+	secret, err := s.cipher.Decrypt(webhook.Secret)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to decrypt webhook secret: %w", err)
+	}
+
+	body, err := json.Marshal(d.Payload)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to marshal payload: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "app-market-webhook")
+	req.Header.Set(WebhookEventHeader, d.Event)
+	req.Header.Set(WebhookDeliveryHeader, fmt.Sprintf("%d", d.ID))
+	req.Header.Set(WebhookSignatureHeader, "sha256="+SignWebhook(secret, body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, webhookResponseLimit))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, string(data), fmt.Errorf("endpoint returned status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, string(data), nil
+}
+
+// SignWebhook returns the hex encoded HMAC-SHA256 of body, as sent in the
+// signature header without its "sha256=" prefix
+func SignWebhook(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
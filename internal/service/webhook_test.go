@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/your-org/app-market/internal/config"
+	"github.com/your-org/app-market/internal/model"
+	"github.com/your-org/app-market/pkg/crypto"
+)
+
+func TestSignWebhook(t *testing.T) {
+	tests := []struct {
+		secret string
+		body   string
+		want   string
+	}{
+		// RFC 4231 test case 2
+		{"Jefe", "what do ya want for nothing?", "5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843"},
+		{"key", "The quick brown fox jumps over the lazy dog", "f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8"},
+	}
+	for _, tt := range tests {
+		if got := SignWebhook(tt.secret, []byte(tt.body)); got != tt.want {
+			t.Errorf("SignWebhook(%q, %q) = %s, want %s", tt.secret, tt.body, got, tt.want)
+		}
+	}
+}
+
+// webhookReceiver records deliveries and answers them with the given statuses
+// in turn, rejecting any whose signature does not match the secret
+type webhookReceiver struct {
+	t        *testing.T
+	secret   string
+	mu       sync.Mutex
+	statuses []int
+	received int
+}
+
+func (r *webhookReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	mac := hmac.New(sha256.New, []byte(r.secret))
+	mac.Write(body)
+	if want := "sha256=" + hex.EncodeToString(mac.Sum(nil)); req.Header.Get(WebhookSignatureHeader) != want {
+		r.t.Errorf("signature header %q, want %q", req.Header.Get(WebhookSignatureHeader), want)
+	}
+	if req.Header.Get(WebhookEventHeader) != EventTaskFailed {
+		r.t.Errorf("event header %q, want %q", req.Header.Get(WebhookEventHeader), EventTaskFailed)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	w.WriteHeader(r.statuses[r.received])
+	r.received++
+}
+
+func TestWebhookAttemptRetriesWithBackoff(t *testing.T) {
+	retry := config.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Minute, MaxBackoff: 10 * time.Minute}
+
+	tests := []struct {
+		name     string
+		disabled bool
+		statuses []int    // Responses of the endpoint
+		want     []string // Delivery status after each attempt
+	}{
+		{"delivered after retries", false, []int{500, 502, 200}, []string{"pending", "pending", "delivered"}},
+		{"failed after the last attempt", false, []int{500, 500, 500}, []string{"pending", "pending", "failed"}},
+		{"failed at once for a disabled webhook", true, nil, []string{"failed"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+			cipher, err := crypto.NewCipher("test-key")
+			if err != nil {
+				t.Fatal(err)
+			}
+			webhooks := NewWebhookService(db, cipher, config.WebhookConfig{Timeout: 5 * time.Second, PollInterval: time.Second, Retry: retry})
+
+			receiver := &webhookReceiver{t: t, secret: "s3cret", statuses: tt.statuses}
+			server := httptest.NewServer(receiver)
+			defer server.Close()
+
+			enabled := !tt.disabled
+			webhook, _, err := webhooks.CreateWebhook(WebhookInput{
+				Name:    "ci",
+				URL:     server.URL,
+				Events:  []string{EventTaskFailed},
+				Secret:  receiver.secret,
+				Enabled: &enabled,
+			})
+			if err != nil {
+				t.Fatalf("CreateWebhook: %v", err)
+			}
+			queued, err := webhooks.enqueue(webhook.ID, EventTaskFailed, webhookPayload(EventTaskFailed, map[string]interface{}{"task_id": 1}))
+			if err != nil {
+				t.Fatalf("enqueue: %v", err)
+			}
+
+			for i, want := range tt.want {
+				var d model.WebhookDelivery
+				if err := db.First(&d, queued.ID).Error; err != nil {
+					t.Fatal(err)
+				}
+				if !webhooks.claim(&d) {
+					t.Fatalf("attempt %d: delivery not claimed", i+1)
+				}
+				start := time.Now()
+				webhooks.attempt(context.Background(), &d)
+				end := time.Now()
+
+				var got model.WebhookDelivery
+				if err := db.First(&got, queued.ID).Error; err != nil {
+					t.Fatal(err)
+				}
+				if got.Status != want || got.Attempts != i+1 {
+					t.Fatalf("attempt %d: status %s after %d attempts, want %s after %d", i+1, got.Status, got.Attempts, want, i+1)
+				}
+				if want != "pending" {
+					if got.NextAttemptAt != nil {
+						t.Errorf("attempt %d: next attempt scheduled at %v for a %s delivery", i+1, got.NextAttemptAt, want)
+					}
+					continue
+				}
+				backoff := retry.Backoff(got.Attempts)
+				if got.NextAttemptAt == nil || got.NextAttemptAt.Before(start.Add(backoff)) || got.NextAttemptAt.After(end.Add(backoff)) {
+					t.Errorf("attempt %d: next attempt at %v, want %v after the attempt", i+1, got.NextAttemptAt, backoff)
+				}
+			}
+			if receiver.received != len(tt.statuses) {
+				t.Errorf("endpoint received %d deliveries, want %d", receiver.received, len(tt.statuses))
+			}
+		})
+	}
+}
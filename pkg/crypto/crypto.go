@@ -0,0 +1,85 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+)
+
+// Cipher encrypts secrets at rest with AES-256-GCM and authenticates data
+// with HMAC-SHA256
+type Cipher struct {
+	aead   cipher.AEAD
+	macKey []byte
+}
+
+// NewCipher derives an AES-256 key and a separate HMAC key from the
+// configured secret
+func NewCipher(secret string) (*Cipher, error) {
+	if secret == "" {
+		return nil, errors.New("encryption key is empty")
+	}
+
+	key := sha256.Sum256([]byte(secret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	macKey := sha256.Sum256([]byte("mac:" + secret))
+	return &Cipher{aead: aead, macKey: macKey[:]}, nil
+}
+
+// MAC returns the hex encoded HMAC-SHA256 of data. Unlike a plain hash it
+// cannot be recomputed, or brute-forced, without the secret.
+func (c *Cipher) MAC(data []byte) string {
+	mac := hmac.New(sha256.New, c.macKey)
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Encrypt returns the base64 encoded nonce and ciphertext of plaintext.
+// An empty plaintext encrypts to an empty string.
+func (c *Cipher) Encrypt(plaintext string) (string, error) {
+	if plaintext == "" {
+		return "", nil
+	}
+
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	sealed := c.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt reverses Encrypt
+func (c *Cipher) Decrypt(encoded string) (string, error) {
+	if encoded == "" {
+		return "", nil
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < c.aead.NonceSize() {
+		return "", errors.New("ciphertext too short")
+	}
+
+	nonce, ciphertext := sealed[:c.aead.NonceSize()], sealed[c.aead.NonceSize():]
+	plaintext, err := c.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", errors.New("failed to decrypt: wrong key or corrupted data")
+	}
+	return string(plaintext), nil
+}
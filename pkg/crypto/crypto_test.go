@@ -0,0 +1,73 @@
+package crypto
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCipherRoundTrip(t *testing.T) {
+	c, err := NewCipher("test-key")
+	if err != nil {
+		t.Fatalf("NewCipher: %v", err)
+	}
+
+	kubeconfig := "apiVersion: v1\nkind: Config\nusers:\n  - name: admin\n    user:\n      token: s3cret\n"
+	encrypted, err := c.Encrypt(kubeconfig)
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	if strings.Contains(encrypted, "s3cret") {
+		t.Fatalf("Encrypt leaked the plaintext: %s", encrypted)
+	}
+	decrypted, err := c.Decrypt(encrypted)
+	if err != nil {
+		t.Fatalf("Decrypt: %v", err)
+	}
+	if decrypted != kubeconfig {
+		t.Errorf("Decrypt(Encrypt(x)) = %q, want %q", decrypted, kubeconfig)
+	}
+
+	// Every encryption uses a fresh nonce
+	if again, _ := c.Encrypt(kubeconfig); again == encrypted {
+		t.Error("Encrypt returned the same ciphertext twice")
+	}
+	// Empty secrets stay empty so unset fields need no key
+	if encrypted, _ := c.Encrypt(""); encrypted != "" {
+		t.Errorf("Encrypt(\"\") = %q, want empty", encrypted)
+	}
+}
+
+func TestCipherRejectsWrongKey(t *testing.T) {
+	c, _ := NewCipher("test-key")
+	other, _ := NewCipher("other-key")
+
+	encrypted, err := c.Encrypt("s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := other.Decrypt(encrypted); err == nil {
+		t.Errorf("Decrypt with the wrong key = %q, want error", got)
+	}
+	if _, err := c.Decrypt("AAAA"); err == nil {
+		t.Error("Decrypt of a truncated ciphertext: want error")
+	}
+	if _, err := NewCipher(""); err == nil {
+		t.Error("NewCipher without a key: want error")
+	}
+}
+
+func TestMACDependsOnKey(t *testing.T) {
+	c, _ := NewCipher("test-key")
+	other, _ := NewCipher("other-key")
+
+	data := []byte(`{"password":"s3cret"}`)
+	if c.MAC(data) != c.MAC(data) {
+		t.Error("MAC of the same data differs")
+	}
+	if c.MAC(data) == other.MAC(data) {
+		t.Error("MAC is the same under another key")
+	}
+	if c.MAC(data) == c.MAC([]byte(`{"password":"other"}`)) {
+		t.Error("MAC of different data is the same")
+	}
+}